@@ -14,6 +14,7 @@ import (
 
 	"github.com/EnzzoHosaki/rps-maestro/internal/api"
 	"github.com/EnzzoHosaki/rps-maestro/internal/config"
+	"github.com/EnzzoHosaki/rps-maestro/internal/leader"
 	"github.com/EnzzoHosaki/rps-maestro/internal/logger"
 	"github.com/EnzzoHosaki/rps-maestro/internal/queue"
 	"github.com/EnzzoHosaki/rps-maestro/internal/repository"
@@ -82,6 +83,10 @@ func main() {
 	jobRepo := repo.GetJobRepository()
 	jobLogRepo := repo.GetJobLogRepository()
 	scheduleRepo := repo.GetScheduleRepository()
+	scheduleEventRepo := repo.GetScheduleEventRepository()
+	calendarRepo := repo.GetCalendarRepository()
+	blackoutRepo := repo.GetBlackoutWindowRepository()
+	pipelineRepo := repo.GetPipelineRepository()
 
 	if err := queueClient.ConsumeDLQ(ctx, func(jobID, reason string) {
 		log.Warn().Str("job_id", jobID).Str("reason", reason).Msg("job dead-lettered")
@@ -89,16 +94,28 @@ func main() {
 		log.Error().Err(err).Msg("erro ao iniciar consumidor da DLQ")
 	}
 
-	retryWorker := retry.New(jobRepo, automationRepo, queueClient)
+	// Liderança entre réplicas: todas sobem scheduler e retry worker, mas só a
+	// dona do lease cria jobs de cron e re-enfileira stuck jobs.
+	elector := leader.New(repo.GetLeaderLeaseRepository(), time.Duration(cfg.Leader.LeaseTTL)*time.Second)
+
+	retryWorker := retry.New(jobRepo, jobLogRepo, automationRepo, queueClient, elector)
 	go retryWorker.Start(ctx)
 
-	sched := scheduler.New(scheduleRepo, automationRepo, jobRepo, queueClient)
+	sched := scheduler.New(scheduleRepo, automationRepo, jobRepo, scheduleEventRepo, calendarRepo, blackoutRepo, pipelineRepo, queueClient, elector)
+	// Ao assumir a liderança, recarrega: o Reload da réplica líder recupera os
+	// disparos perdidos enquanto ninguém liderava (misfire_policy).
+	elector.OnElected(func(ctx context.Context) {
+		if err := sched.Reload(ctx); err != nil {
+			log.Error().Err(err).Msg("erro ao recarregar agendamentos ao assumir a liderança")
+		}
+	})
+	go elector.Run(ctx)
 	sched.Start(ctx)
 
 	server := api.NewServer(
 		cfg.Server, cfg.JWT, cfg.Worker,
-		userRepo, automationRepo, jobRepo, jobLogRepo, scheduleRepo,
-		queueClient, sched,
+		userRepo, automationRepo, jobRepo, jobLogRepo, scheduleRepo, scheduleEventRepo, calendarRepo,
+		blackoutRepo, pipelineRepo, queueClient, sched, elector,
 	)
 
 	// Sobe o HTTP numa goroutine; o main bloqueia no sinal de shutdown.
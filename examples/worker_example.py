@@ -152,12 +152,12 @@ def main() -> None:
     connection = pika.BlockingConnection(pika.URLParameters(RABBITMQ_URL))
     channel = connection.channel()
 
-    # A fila precisa ser declarada com o MESMO dead-letter-exchange do Maestro,
-    # senão o broker rejeita com PRECONDITION_FAILED (406).
+    # A fila precisa ser declarada com os MESMOS argumentos do Maestro
+    # (queue.QueueArgs), senão o broker rejeita com PRECONDITION_FAILED (406).
     channel.queue_declare(
         queue=QUEUE_NAME,
         durable=True,
-        arguments={"x-dead-letter-exchange": "maestro.dlx"},
+        arguments={"x-dead-letter-exchange": "maestro.dlx", "x-max-priority": 10},
     )
     channel.basic_qos(prefetch_count=1)
     channel.basic_consume(queue=QUEUE_NAME, on_message_callback=process_message)
@@ -2,13 +2,19 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+	"github.com/EnzzoHosaki/rps-maestro/internal/paramschema"
 	"github.com/EnzzoHosaki/rps-maestro/internal/queue"
+	"github.com/EnzzoHosaki/rps-maestro/internal/ratelimit"
 	"github.com/EnzzoHosaki/rps-maestro/internal/repository"
+	"github.com/EnzzoHosaki/rps-maestro/internal/retry"
 	"github.com/gin-gonic/gin"
 )
 
@@ -23,9 +29,57 @@ func validateAutomationPayload(a *models.Automation) string {
 	if strings.TrimSpace(a.ScriptPath) == "" {
 		return "script_path é obrigatório"
 	}
+	if a.RetryPolicy != nil {
+		if err := retry.Normalize(a.RetryPolicy); err != nil {
+			return "retryPolicy inválida: " + err.Error()
+		}
+	}
+	if a.MaxDurationMinutes != nil && (*a.MaxDurationMinutes < 1 || *a.MaxDurationMinutes > maxDurationMinutesLimit) {
+		return "maxDurationMinutes deve estar entre 1 e " + strconv.Itoa(maxDurationMinutesLimit)
+	}
+	if a.MaxConcurrency != nil && *a.MaxConcurrency < 1 {
+		return "maxConcurrency deve ser pelo menos 1"
+	}
+	if a.ResourceKey != nil {
+		key := strings.TrimSpace(*a.ResourceKey)
+		if key == "" {
+			a.ResourceKey = nil
+		} else {
+			a.ResourceKey = &key
+		}
+	}
+	if a.ResourceConcurrency != nil {
+		if a.ResourceKey == nil {
+			return "resourceConcurrency exige resourceKey"
+		}
+		if *a.ResourceConcurrency < 1 {
+			return "resourceConcurrency deve ser pelo menos 1"
+		}
+	}
+	if a.RateLimit != nil {
+		if err := ratelimit.Validate(a.RateLimit); err != nil {
+			return "rateLimit inválido: " + err.Error()
+		}
+	}
+	for trigger, priority := range a.Priorities {
+		if !jobTriggers[trigger] {
+			return "priorities: trigger inválido " + strconv.Quote(trigger) + " (use manual, schedule, retry ou api)"
+		}
+		if priority < 0 || priority > models.MaxPriority {
+			return "priorities: prioridade deve estar entre 0 e " + strconv.Itoa(models.MaxPriority)
+		}
+	}
+	if a.RetryFailed != nil {
+		if err := retry.NormalizeFailedMapping(a.RetryFailed); err != nil {
+			return "retryFailed inválido: " + err.Error()
+		}
+	}
 	return ""
 }
 
+// maxDurationMinutesLimit: uma semana, o mesmo teto do CHECK da coluna.
+const maxDurationMinutesLimit = 7 * 24 * 60
+
 type AutomationHandler struct {
 	automationRepo repository.AutomationRepository
 	jobRepo        repository.JobRepository
@@ -178,6 +232,15 @@ func (h *AutomationHandler) GetLastParamsForUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"parameters": params})
 }
 
+// ExecuteAutomation cria um job com o corpo como parâmetros e o publica na
+// fila. Com ?runAt=<RFC3339> (futuro) a execução é adiada: o job fica em
+// status scheduled e o dispatcher do scheduler o publica nesse instante.
+// Com header Idempotency-Key, repetir a chave devolve o job original (ver
+// createJob). Com ?fanOut=<parâmetro lista> (ex.: lojas) cria um job pai e um
+// filho por item da lista (ver startFanOut); não combina com runAt.
+// Os parâmetros recebem o defaultParams nos campos ausentes e são validados
+// contra o parameterSchema antes de criar o job (ver prepareParams); corpo
+// vazio vale como {}.
 func (h *AutomationHandler) ExecuteAutomation(c *gin.Context) {
 	idParam := c.Param("id")
 	automationID, err := strconv.Atoi(idParam)
@@ -186,6 +249,28 @@ func (h *AutomationHandler) ExecuteAutomation(c *gin.Context) {
 		return
 	}
 
+	idempotencyKey, msg := parseIdempotencyKey(c)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var runAt *time.Time
+	if v := c.Query("runAt"); v != "" {
+		t, msg := parseRunAt(v)
+		if msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		runAt = &t
+	}
+
+	fanOutParam := strings.TrimSpace(c.Query("fanOut"))
+	if fanOutParam != "" && runAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fanOut não pode ser combinado com runAt"})
+		return
+	}
+
 	automation, err := h.automationRepo.GetByID(c.Request.Context(), automationID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Automação não encontrada"})
@@ -193,8 +278,13 @@ func (h *AutomationHandler) ExecuteAutomation(c *gin.Context) {
 	}
 
 	var params map[string]interface{}
-	if err := c.ShouldBindJSON(&params); err != nil {
-		params = make(map[string]interface{})
+	if err := c.ShouldBindJSON(&params); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parâmetros inválidos: o corpo deve ser um objeto JSON (" + err.Error() + ")"})
+		return
+	}
+	params, ok := prepareParams(c, automation, params, paramschema.Options{})
+	if !ok {
+		return
 	}
 
 	paramsJSON, err := json.Marshal(params)
@@ -210,23 +300,57 @@ func (h *AutomationHandler) ExecuteAutomation(c *gin.Context) {
 		}
 	}
 
+	trigger := models.TriggerManual
+	if userID == nil {
+		trigger = models.TriggerAPI
+	}
+
 	job := &models.Job{
-		AutomationID: automationID,
-		UserID:       userID,
-		Status:       "pending",
-		Parameters:   paramsJSON,
+		AutomationID:   automationID,
+		UserID:         userID,
+		Status:         "pending",
+		Parameters:     paramsJSON,
+		Trigger:        trigger,
+		IdempotencyKey: idempotencyKey,
+	}
+	if runAt != nil {
+		job.Status = "scheduled"
+		job.RunAt = runAt
+	}
+	if fanOutParam != "" {
+		startFanOut(c, h.jobRepo, h.automationRepo, h.queueClient, automation, job, params, fanOutParam)
+		return
 	}
 
-	if err := h.jobRepo.Create(c.Request.Context(), job); err != nil {
+	job, created, err := createJob(c.Request.Context(), h.jobRepo, job)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar job: " + err.Error()})
 		return
 	}
+	if !created {
+		replayJob(c, h.jobRepo, h.automationRepo, job, automationID)
+		return
+	}
+
+	if runAt != nil {
+		// Nada vai pra fila agora: o dispatcher publica quando run_at vencer.
+		c.JSON(http.StatusAccepted, job)
+		return
+	}
+
+	if job.Held {
+		// Limite de concorrência: o dispatcher publica quando houver vaga.
+		fillQueuePositions(c.Request.Context(), h.jobRepo, h.automationRepo, job)
+		c.JSON(http.StatusAccepted, job)
+		return
+	}
 
 	queueMsg := queue.JobMessage{
 		JobID:        job.ID.String(),
 		AutomationID: automationID,
 		ScriptPath:   automation.ScriptPath,
 		Parameters:   params,
+		Priority:     job.Priority,
 	}
 
 	queueName := automation.QueueName
@@ -248,3 +372,16 @@ func (h *AutomationHandler) ExecuteAutomation(c *gin.Context) {
 
 	c.JSON(http.StatusAccepted, job)
 }
+
+// parseRunAt valida o horário de uma execução adiada: RFC3339 e no futuro.
+// Devolve a mensagem de erro pro cliente ("" quando válido).
+func parseRunAt(v string) (time.Time, string) {
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, "runAt inválido (use RFC3339, ex.: 2026-05-20T02:00:00-03:00)"
+	}
+	if !t.After(time.Now()) {
+		return time.Time{}, "runAt deve estar no futuro"
+	}
+	return t, ""
+}
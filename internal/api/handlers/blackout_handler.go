@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+	"github.com/EnzzoHosaki/rps-maestro/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// maxBlackoutMinutes limita a duração de uma janela de bloqueio a 31 dias —
+// pausa mais longa que isso é caso de desabilitar o agendamento.
+const maxBlackoutMinutes = 31 * 24 * 60
+
+// BlackoutHandler gerencia as janelas de bloqueio recorrentes, cadastradas
+// num agendamento (/schedules/:id/blackouts) ou numa automação
+// (/automations/:id/blackouts, valem pra todos os agendamentos dela). Não
+// precisa de Reload: o scheduler consulta as janelas a cada disparo.
+type BlackoutHandler struct {
+	blackoutRepo   repository.BlackoutWindowRepository
+	scheduleRepo   repository.ScheduleRepository
+	automationRepo repository.AutomationRepository
+}
+
+func NewBlackoutHandler(
+	blackoutRepo repository.BlackoutWindowRepository,
+	scheduleRepo repository.ScheduleRepository,
+	automationRepo repository.AutomationRepository,
+) *BlackoutHandler {
+	return &BlackoutHandler{
+		blackoutRepo:   blackoutRepo,
+		scheduleRepo:   scheduleRepo,
+		automationRepo: automationRepo,
+	}
+}
+
+// blackoutPayload é o corpo de create/update. Exemplo — fechamento do mês:
+// a partir do último dia útil às 18:00, por 38 horas:
+//
+//	{ "name": "Fechamento", "cronExpression": "0 18 LBD * *", "durationMinutes": 2280 }
+type blackoutPayload struct {
+	Name            string  `json:"name" binding:"required"`
+	CronExpression  string  `json:"cronExpression" binding:"required"`
+	DurationMinutes int     `json:"durationMinutes" binding:"required"`
+	Timezone        *string `json:"timezone"`
+	IsEnabled       *bool   `json:"isEnabled"`
+}
+
+// toModel valida o payload e monta a janela (sem alvo). Retorna "" quando
+// válido.
+func (p blackoutPayload) toModel() (models.BlackoutWindow, string) {
+	w := models.BlackoutWindow{
+		Name:            strings.TrimSpace(p.Name),
+		CronExpression:  strings.TrimSpace(p.CronExpression),
+		DurationMinutes: p.DurationMinutes,
+		IsEnabled:       true,
+	}
+	if p.IsEnabled != nil {
+		w.IsEnabled = *p.IsEnabled
+	}
+	if w.Name == "" {
+		return w, "name é obrigatório"
+	}
+
+	tz, msg := normalizeTimezone(p.Timezone)
+	if msg != "" {
+		return w, msg
+	}
+	w.Timezone = tz
+	var tzName string
+	if tz != nil {
+		tzName = *tz
+	}
+	if err := validateCron(w.CronExpression, tzName); err != nil {
+		return w, "Expressão cron inválida: " + err.Error()
+	}
+	if w.DurationMinutes < 1 || w.DurationMinutes > maxBlackoutMinutes {
+		return w, "durationMinutes deve estar entre 1 e 44640 (31 dias)"
+	}
+	return w, ""
+}
+
+func (h *BlackoutHandler) ListScheduleBlackouts(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+	if _, err := h.scheduleRepo.GetByID(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agendamento não encontrado"})
+		return
+	}
+
+	windows, err := h.blackoutRepo.ListBySchedule(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar janelas de bloqueio: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, windows)
+}
+
+func (h *BlackoutHandler) CreateScheduleBlackout(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+	if _, err := h.scheduleRepo.GetByID(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agendamento não encontrado"})
+		return
+	}
+	h.create(c, &id, nil)
+}
+
+func (h *BlackoutHandler) ListAutomationBlackouts(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+	if _, err := h.automationRepo.GetByID(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Automação não encontrada"})
+		return
+	}
+
+	windows, err := h.blackoutRepo.ListByAutomation(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar janelas de bloqueio: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, windows)
+}
+
+func (h *BlackoutHandler) CreateAutomationBlackout(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+	if _, err := h.automationRepo.GetByID(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Automação não encontrada"})
+		return
+	}
+	h.create(c, nil, &id)
+}
+
+func (h *BlackoutHandler) create(c *gin.Context, scheduleID, automationID *int) {
+	var req blackoutPayload
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos: " + err.Error()})
+		return
+	}
+	w, msg := req.toModel()
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	w.ScheduleID = scheduleID
+	w.AutomationID = automationID
+
+	if err := h.blackoutRepo.Create(c.Request.Context(), &w); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar janela de bloqueio: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, w)
+}
+
+func (h *BlackoutHandler) UpdateBlackout(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req blackoutPayload
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos: " + err.Error()})
+		return
+	}
+	w, msg := req.toModel()
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if _, err := h.blackoutRepo.GetByID(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Janela de bloqueio não encontrada"})
+		return
+	}
+
+	w.ID = id
+	if err := h.blackoutRepo.Update(c.Request.Context(), &w); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar janela de bloqueio: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, w)
+}
+
+func (h *BlackoutHandler) DeleteBlackout(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	if err := h.blackoutRepo.Delete(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao deletar janela de bloqueio: " + err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/calendar"
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+	"github.com/EnzzoHosaki/rps-maestro/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// CalendarHandler não recarrega o scheduler: as escritas do repositório
+// notificam todas as réplicas (inclusive esta) pelo mesmo canal das mudanças
+// de agendamento.
+type CalendarHandler struct {
+	calendarRepo repository.CalendarRepository
+}
+
+func NewCalendarHandler(calendarRepo repository.CalendarRepository) *CalendarHandler {
+	return &CalendarHandler{
+		calendarRepo: calendarRepo,
+	}
+}
+
+type calendarPayload struct {
+	Name            string  `json:"name" binding:"required"`
+	Description     *string `json:"description"`
+	IncludeNational *bool   `json:"includeNational"`
+}
+
+func (p calendarPayload) toModel() models.Calendar {
+	cal := models.Calendar{
+		Name:            strings.TrimSpace(p.Name),
+		Description:     p.Description,
+		IncludeNational: true,
+	}
+	if p.IncludeNational != nil {
+		cal.IncludeNational = *p.IncludeNational
+	}
+	return cal
+}
+
+func (h *CalendarHandler) CreateCalendar(c *gin.Context) {
+	var req calendarPayload
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos: " + err.Error()})
+		return
+	}
+
+	cal := req.toModel()
+	if err := h.calendarRepo.Create(c.Request.Context(), &cal); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar calendário: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, cal)
+}
+
+func (h *CalendarHandler) GetAllCalendars(c *gin.Context) {
+	cals, err := h.calendarRepo.GetAll(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar calendários: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, cals)
+}
+
+// GetCalendarByID devolve o calendário com as datas cadastradas e, em
+// effectiveHolidays, todos os feriados que valem no ano (?year=, padrão o
+// corrente) — nacionais calculados + cadastrados + recorrentes.
+func (h *CalendarHandler) GetCalendarByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	year := time.Now().Year()
+	if v := c.Query("year"); v != "" {
+		y, err := strconv.Atoi(v)
+		if err != nil || y < 1900 || y > 2200 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "year inválido"})
+			return
+		}
+		year = y
+	}
+
+	cal, err := h.calendarRepo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendário não encontrado"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"calendar":          cal,
+		"year":              year,
+		"effectiveHolidays": calendar.New(*cal, cal.Holidays).Holidays(year),
+	})
+}
+
+func (h *CalendarHandler) UpdateCalendar(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req calendarPayload
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos: " + err.Error()})
+		return
+	}
+
+	cal := req.toModel()
+	cal.ID = id
+	if err := h.calendarRepo.Update(c.Request.Context(), &cal); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar calendário: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, cal)
+}
+
+func (h *CalendarHandler) DeleteCalendar(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	if err := h.calendarRepo.Delete(c.Request.Context(), id); err != nil {
+		if errors.Is(err, repository.ErrCalendarInUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Calendário em uso por agendamento(s) — troque o calendário deles antes de deletar"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao deletar calendário: " + err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// AddHoliday cadastra uma data no calendário. Payload:
+//
+//	{ "date": "2026-07-09", "name": "Revolução Constitucionalista", "recurring": true }
+//
+// recurring=true vale todo ano no mesmo dia/mês. Cadastrar uma data que já
+// existe no calendário substitui o nome/recorrência.
+func (h *CalendarHandler) AddHoliday(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req struct {
+		Date      string `json:"date" binding:"required"`
+		Name      string `json:"name" binding:"required"`
+		Recurring bool   `json:"recurring"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos: " + err.Error()})
+		return
+	}
+	if _, err := time.Parse("2006-01-02", req.Date); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data inválida: use o formato YYYY-MM-DD"})
+		return
+	}
+
+	if _, err := h.calendarRepo.GetByID(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendário não encontrado"})
+		return
+	}
+
+	holiday := models.CalendarHoliday{
+		CalendarID: id,
+		Date:       req.Date,
+		Name:       strings.TrimSpace(req.Name),
+		Recurring:  req.Recurring,
+	}
+	if err := h.calendarRepo.AddHoliday(c.Request.Context(), &holiday); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao cadastrar feriado: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, holiday)
+}
+
+func (h *CalendarHandler) DeleteHoliday(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+	holidayID, err := strconv.Atoi(c.Param("holidayId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID do feriado inválido"})
+		return
+	}
+
+	if err := h.calendarRepo.DeleteHoliday(c.Request.Context(), id, holidayID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
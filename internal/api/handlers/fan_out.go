@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/fanout"
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+	"github.com/EnzzoHosaki/rps-maestro/internal/queue"
+	"github.com/EnzzoHosaki/rps-maestro/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// startFanOut cria o job pai (já montado pelo caller, sem status) e um filho
+// por item de params[param], publica os filhos que não ficaram retidos e
+// responde com o pai e os filhos. Usado pelo ExecuteAutomation e pelo
+// RetryJob de um pai. Filho que não chegou ao broker vira failed, como no
+// caminho de job único; só responde erro se nenhum chegou.
+func startFanOut(
+	c *gin.Context,
+	jobRepo repository.JobRepository,
+	automationRepo repository.AutomationRepository,
+	queueClient *queue.RabbitMQClient,
+	automation *models.Automation,
+	parent *models.Job,
+	params map[string]interface{},
+	param string,
+) {
+	ctx := c.Request.Context()
+
+	items, err := fanout.Split(params, param)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "fanOut inválido: " + err.Error()})
+		return
+	}
+
+	children := make([]models.Job, 0, len(items))
+	for _, item := range items {
+		childParams, err := json.Marshal(item.Params)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Erro ao processar parâmetros: " + err.Error()})
+			return
+		}
+		key := item.Key
+		children = append(children, models.Job{
+			AutomationID: parent.AutomationID,
+			UserID:       parent.UserID,
+			Status:       "pending",
+			Parameters:   childParams,
+			Trigger:      parent.Trigger,
+			FanOutItem:   &key,
+		})
+	}
+	parent.Status = "running"
+	parent.FanOutParam = &param
+
+	parent, created, err := jobRepo.CreateFanOut(ctx, parent, children, idempotencyTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar job: " + err.Error()})
+		return
+	}
+	if !created {
+		replayJob(c, jobRepo, automationRepo, parent, automation.ID)
+		return
+	}
+
+	published, failed := 0, 0
+	var publishErr error
+	for i := range parent.Children {
+		child := &parent.Children[i]
+		if child.Held {
+			continue
+		}
+		if err := publishJob(ctx, queueClient, automation, child, items[i].Params); err != nil {
+			log.Printf("[job_handler] fan-out %s: %v", parent.ID, err)
+			failResult, _ := json.Marshal(map[string]string{"error": "Falha ao enfileirar o job no broker: " + err.Error()})
+			_ = jobRepo.SetResult(ctx, child.ID, failResult)
+			_ = jobRepo.UpdateStatus(ctx, child.ID, "failed")
+			child.Status = "failed"
+			failed++
+			publishErr = err
+			continue
+		}
+		published++
+	}
+	if failed > 0 {
+		if err := jobRepo.RefreshParent(ctx, parent.ID); err != nil {
+			log.Printf("[job_handler] %v", err)
+		}
+		if published == 0 && failed == len(parent.Children) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao enfileirar job: " + publishErr.Error()})
+			return
+		}
+		if fresh, err := jobRepo.GetByID(ctx, parent.ID); err == nil {
+			fresh.Children = parent.Children
+			parent = fresh
+		}
+	}
+
+	refs := make([]*models.Job, len(parent.Children))
+	for i := range parent.Children {
+		refs[i] = &parent.Children[i]
+	}
+	fillQueuePositions(ctx, jobRepo, automationRepo, refs...)
+	c.JSON(http.StatusAccepted, parent)
+}
+
+// publishJob enfileira o job na fila da automação.
+func publishJob(ctx context.Context, queueClient *queue.RabbitMQClient, automation *models.Automation, job *models.Job, params map[string]interface{}) error {
+	queueName := automation.QueueName
+	if queueName == "" {
+		queueName = "automation_jobs"
+	}
+	msg := queue.JobMessage{
+		JobID:        job.ID.String(),
+		AutomationID: automation.ID,
+		ScriptPath:   automation.ScriptPath,
+		Parameters:   params,
+		Priority:     job.Priority,
+	}
+	if err := queueClient.PublishJob(ctx, queueName, msg); err != nil {
+		return fmt.Errorf("erro ao enfileirar job %s: %w", job.ID, err)
+	}
+	return nil
+}
+
+// fillChildren carrega os filhos de um pai de fan-out, com a posição na fila
+// dos retidos. Falha aqui não derruba a resposta.
+func fillChildren(ctx context.Context, jobRepo repository.JobRepository, automationRepo repository.AutomationRepository, job *models.Job) {
+	if !job.IsFanOutParent() {
+		return
+	}
+	children, err := jobRepo.ListChildren(ctx, job.ID)
+	if err != nil {
+		log.Printf("[job_handler] %v", err)
+		return
+	}
+	refs := make([]*models.Job, len(children))
+	for i := range children {
+		refs[i] = &children[i]
+	}
+	fillQueuePositions(ctx, jobRepo, automationRepo, refs...)
+	job.Children = children
+}
+
+// refreshFanOut reagrega o pai do fan-out a que o job pertence — ele mesmo,
+// se for o pai.
+func refreshFanOut(ctx context.Context, jobRepo repository.JobRepository, job *models.Job) {
+	parentID := job.ParentJobID
+	if job.IsFanOutParent() {
+		parentID = &job.ID
+	}
+	if parentID == nil {
+		return
+	}
+	if err := jobRepo.RefreshParent(ctx, *parentID); err != nil {
+		log.Printf("[job_handler] %v", err)
+	}
+}
@@ -1,16 +1,23 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+	"github.com/EnzzoHosaki/rps-maestro/internal/paramschema"
 	"github.com/EnzzoHosaki/rps-maestro/internal/queue"
+	"github.com/EnzzoHosaki/rps-maestro/internal/ratelimit"
 	"github.com/EnzzoHosaki/rps-maestro/internal/repository"
+	"github.com/EnzzoHosaki/rps-maestro/internal/retry"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -20,10 +27,27 @@ import (
 var terminalJobStatuses = map[string]bool{
 	"completed":             true,
 	"completed_no_invoices": true,
+	"partial":               true,
 	"failed":                true,
 	"canceled":              true,
 }
 
+// jobTriggers são os valores aceitos no filtro ?trigger= (jobs.trigger).
+var jobTriggers = map[string]bool{
+	models.TriggerManual:   true,
+	models.TriggerSchedule: true,
+	models.TriggerRetry:    true,
+	models.TriggerAPI:      true,
+}
+
+const (
+	// idempotencyTTL é por quanto tempo um Idempotency-Key devolve o job
+	// original em vez de criar outro.
+	idempotencyTTL = 24 * time.Hour
+	// maxIdempotencyKeyLen acompanha o VARCHAR(255) de jobs.idempotency_key.
+	maxIdempotencyKeyLen = 255
+)
+
 const (
 	// sseLogPollInterval é o intervalo entre consultas no banco de logs novos.
 	sseLogPollInterval = 1 * time.Second
@@ -59,6 +83,90 @@ func NewJobHandler(
 	}
 }
 
+// parseIdempotencyKey lê o header Idempotency-Key. Devolve nil sem header e a
+// mensagem de erro pro cliente ("" quando válido).
+func parseIdempotencyKey(c *gin.Context) (*string, string) {
+	key := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+	if key == "" {
+		return nil, ""
+	}
+	if len(key) > maxIdempotencyKeyLen {
+		return nil, "Idempotency-Key muito longo (máximo " + strconv.Itoa(maxIdempotencyKeyLen) + " caracteres)"
+	}
+	return &key, ""
+}
+
+// createJob cria o job; com IdempotencyKey, uma chave já usada dentro do
+// idempotencyTTL devolve o job original e created=false — aí o caller não
+// publica nada e responde com replayJob.
+func createJob(ctx context.Context, jobRepo repository.JobRepository, job *models.Job) (*models.Job, bool, error) {
+	if job.IdempotencyKey == nil {
+		if err := jobRepo.Create(ctx, job); err != nil {
+			return nil, false, err
+		}
+		return job, true, nil
+	}
+	return jobRepo.CreateIdempotent(ctx, job, idempotencyTTL)
+}
+
+// replayJob responde a uma requisição repetida com o job criado pela
+// original. Chave reaproveitada pra outra automação é erro do cliente.
+func replayJob(c *gin.Context, jobRepo repository.JobRepository, automationRepo repository.AutomationRepository, job *models.Job, automationID int) {
+	if job.AutomationID != automationID {
+		c.JSON(http.StatusConflict, gin.H{"error": "Idempotency-Key já usado para um job de outra automação"})
+		return
+	}
+	fillQueuePositions(c.Request.Context(), jobRepo, automationRepo, job)
+	fillChildren(c.Request.Context(), jobRepo, automationRepo, job)
+	c.Header("Idempotent-Replayed", "true")
+	c.JSON(http.StatusAccepted, job)
+}
+
+// fillQueuePositions preenche QueuePosition dos jobs retidos pelo dispatcher e,
+// se a automação tem limite de taxa, EstimatedReleaseAt. Falha aqui não derruba
+// a resposta — as duas são informativas.
+func fillQueuePositions(ctx context.Context, jobRepo repository.JobRepository, automationRepo repository.AutomationRepository, jobs ...*models.Job) {
+	var ids []uuid.UUID
+	for _, j := range jobs {
+		if j.Held {
+			ids = append(ids, j.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	positions, err := jobRepo.GetQueuePositions(ctx, ids)
+	if err != nil {
+		log.Printf("[job_handler] %v", err)
+		return
+	}
+	now := time.Now()
+	automations := make(map[int]*models.Automation)
+	for _, j := range jobs {
+		pos, ok := positions[j.ID]
+		if !ok {
+			continue
+		}
+		j.QueuePosition = &pos
+
+		automation, ok := automations[j.AutomationID]
+		if !ok {
+			automation, _ = automationRepo.GetByID(ctx, j.AutomationID)
+			automations[j.AutomationID] = automation
+		}
+		if automation == nil || automation.RateLimit == nil {
+			continue
+		}
+		bucket, err := automationRepo.GetRateBucket(ctx, automation.ID)
+		if err != nil {
+			log.Printf("[job_handler] %v", err)
+			continue
+		}
+		releaseAt := ratelimit.ReleaseAt(bucket, automation.RateLimit, now, pos)
+		j.EstimatedReleaseAt = &releaseAt
+	}
+}
+
 func (h *JobHandler) GetJobByID(c *gin.Context) {
 	jobID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -72,6 +180,8 @@ func (h *JobHandler) GetJobByID(c *gin.Context) {
 		return
 	}
 
+	fillQueuePositions(c.Request.Context(), h.jobRepo, h.automationRepo, job)
+	fillChildren(c.Request.Context(), h.jobRepo, h.automationRepo, job)
 	c.JSON(http.StatusOK, job)
 }
 
@@ -94,15 +204,20 @@ func (h *JobHandler) GetJobLogs(c *gin.Context) {
 // ListJobs retorna jobs paginados com filtros opcionais por query string.
 //
 // Query params suportados:
-//   - status:        pending | running | completed | completed_no_invoices | failed | canceled
+//   - status:        scheduled | pending | running | completed | completed_no_invoices | partial | failed | canceled
 //   - automation_id: int
 //   - user_id:       int
+//   - schedule_id:   int (jobs criados por esse agendamento)
+//   - trigger:       manual | schedule | retry | api
+//   - held:          true | false (retidos pelo limite de concorrência)
+//   - parent_id:     uuid (filhos desse job pai de fan-out)
 //   - since:         RFC3339 (jobs criados a partir desta data)
 //   - until:         RFC3339 (jobs criados até esta data)
 //   - limit:         1..200, default 50
 //   - offset:        default 0
 //
-// Resposta: { "items": [Job], "total": int, "limit": int, "offset": int }
+// Resposta: { "items": [Job], "total": int, "limit": int, "offset": int }.
+// Jobs retidos vêm com queuePosition.
 func (h *JobHandler) ListJobs(c *gin.Context) {
 	filter := models.JobListFilter{}
 
@@ -125,6 +240,37 @@ func (h *JobHandler) ListJobs(c *gin.Context) {
 		}
 		filter.UserID = &id
 	}
+	if v := c.Query("schedule_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "schedule_id inválido"})
+			return
+		}
+		filter.ScheduleID = &id
+	}
+	if trigger := c.Query("trigger"); trigger != "" {
+		if !jobTriggers[trigger] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "trigger inválido (use manual, schedule, retry ou api)"})
+			return
+		}
+		filter.Trigger = &trigger
+	}
+	if v := c.Query("held"); v != "" {
+		held, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "held inválido (use true ou false)"})
+			return
+		}
+		filter.Held = &held
+	}
+	if v := c.Query("parent_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "parent_id inválido"})
+			return
+		}
+		filter.ParentJobID = &id
+	}
 	if v := c.Query("since"); v != "" {
 		t, err := time.Parse(time.RFC3339, v)
 		if err != nil {
@@ -164,6 +310,12 @@ func (h *JobHandler) ListJobs(c *gin.Context) {
 		return
 	}
 
+	refs := make([]*models.Job, len(jobs))
+	for i := range jobs {
+		refs[i] = &jobs[i]
+	}
+	fillQueuePositions(c.Request.Context(), h.jobRepo, h.automationRepo, refs...)
+
 	limit := filter.Limit
 	if limit <= 0 {
 		limit = 50
@@ -183,6 +335,9 @@ func (h *JobHandler) ListJobs(c *gin.Context) {
 // chegará a sair pra o worker). Para jobs em running apenas marcamos
 // cancellation_requested_at — o worker decide quando parar (ver
 // GET /worker/jobs/:id/cancellation no WorkerHandler).
+//
+// No pai de um fan-out o cancelamento vale pra todos os filhos em aberto; num
+// filho, só pra ele. Nos dois casos o status do pai é reagregado.
 func (h *JobHandler) CancelJob(c *gin.Context) {
 	jobID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -200,12 +355,98 @@ func (h *JobHandler) CancelJob(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar job: " + err.Error()})
 		return
 	}
+	if job.IsFanOutParent() || job.ParentJobID != nil {
+		refreshFanOut(c.Request.Context(), h.jobRepo, job)
+		if fresh, err := h.jobRepo.GetByID(c.Request.Context(), jobID); err == nil {
+			job = fresh
+		}
+		fillChildren(c.Request.Context(), h.jobRepo, h.automationRepo, job)
+	}
 	c.JSON(http.StatusAccepted, job)
 }
 
+// RescheduleJob muda o horário de um job adiado (status scheduled, criado com
+// runAt). Body: {"runAt": "<RFC3339 no futuro>"}. Job já publicado, cancelado
+// ou inexistente → 409.
+func (h *JobHandler) RescheduleJob(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req struct {
+		RunAt string `json:"runAt" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos: " + err.Error()})
+		return
+	}
+	runAt, msg := parseRunAt(req.RunAt)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := h.jobRepo.Reschedule(c.Request.Context(), jobID, runAt); err != nil {
+		if errors.Is(err, repository.ErrJobNotScheduled) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao reagendar job: " + err.Error()})
+		return
+	}
+
+	job, err := h.jobRepo.GetByID(c.Request.Context(), jobID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar job: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// GetJobLineage devolve a cadeia de tentativas do job: a primeira execução e
+// todas as reexecuções ligadas por retryOf (Reexecutar, com ou sem patch, e
+// Reexecutar só as falhas), em ordem de criação. O retry automático da
+// retryPolicy reaproveita o mesmo job e não aparece como tentativa separada.
+//
+//	{ "rootId": "...", "jobs": [Job, ...] }
+func (h *JobHandler) GetJobLineage(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	jobs, err := h.jobRepo.GetLineage(c.Request.Context(), jobID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar linhagem do job: " + err.Error()})
+		return
+	}
+	if len(jobs) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job não encontrado"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rootId": jobs[0].ID, "jobs": jobs})
+}
+
 // RetryJob cria um NOVO job clonando os parâmetros do job original e o
 // publica na fila. O job original mantém seu status histórico ('failed',
-// 'canceled', etc.) — nada nele é alterado.
+// 'canceled', etc.) — nada nele é alterado; o novo aponta pra ele em retryOf.
+// Aceita Idempotency-Key como o ExecuteAutomation.
+//
+// Body opcional: JSON merge patch (RFC 7396) sobre os parâmetros originais —
+// chave com null sai, o resto substitui:
+//
+//	{ "start_date": "01/06/2026", "stores": [4814] }
+//
+// Com patch, o resultado é validado contra o parameterSchema da automação
+// (400 com os erros por campo em "fields").
+//
+// Fan-out: retentar um filho cria a nova tentativa sob o mesmo pai (mesmo
+// item), e o pai volta a agregar a partir dela; retentar o pai cria um fan-out
+// novo com os parâmetros do pai — já com o patch, então dá pra trocar a lista
+// dividida ({"stores": [4814]} refaz só essa loja).
 func (h *JobHandler) RetryJob(c *gin.Context) {
 	jobID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -213,6 +454,18 @@ func (h *JobHandler) RetryJob(c *gin.Context) {
 		return
 	}
 
+	idempotencyKey, msg := parseIdempotencyKey(c)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var patch map[string]interface{}
+	if err := c.ShouldBindJSON(&patch); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Patch de parâmetros inválido: " + err.Error()})
+		return
+	}
+
 	original, err := h.jobRepo.GetByID(c.Request.Context(), jobID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Job original não encontrado"})
@@ -232,17 +485,6 @@ func (h *JobHandler) RetryJob(c *gin.Context) {
 		}
 	}
 
-	newJob := &models.Job{
-		AutomationID: original.AutomationID,
-		UserID:       userID,
-		Status:       "pending",
-		Parameters:   original.Parameters,
-	}
-	if err := h.jobRepo.Create(c.Request.Context(), newJob); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar job: " + err.Error()})
-		return
-	}
-
 	// JobMessage espera map[string]interface{}; o worker recebe via JSON, então
 	// a serialização precisa preservar a forma original dos parâmetros.
 	var paramsMap map[string]interface{}
@@ -252,12 +494,152 @@ func (h *JobHandler) RetryJob(c *gin.Context) {
 	if paramsMap == nil {
 		paramsMap = map[string]interface{}{}
 	}
+	paramsJSON := original.Parameters
+	if patch != nil {
+		paramsMap = paramschema.MergePatch(paramsMap, patch)
+		if !validateParams(c, automation, paramsMap, paramschema.Options{}) {
+			return
+		}
+		if paramsJSON, err = json.Marshal(paramsMap); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Erro ao processar parâmetros: " + err.Error()})
+			return
+		}
+	}
+
+	newJob := &models.Job{
+		AutomationID:   original.AutomationID,
+		UserID:         userID,
+		Status:         "pending",
+		Parameters:     paramsJSON,
+		Trigger:        models.TriggerRetry,
+		IdempotencyKey: idempotencyKey,
+		ParentJobID:    original.ParentJobID,
+		FanOutItem:     original.FanOutItem,
+		// Retry de passo de pipeline fica na mesma execução e vira o job do
+		// passo — a execução reabre e segue pelas arestas dele.
+		PipelineRunID: original.PipelineRunID,
+		PipelineStep:  original.PipelineStep,
+		RetryOf:       &original.ID,
+	}
+	if original.IsFanOutParent() {
+		startFanOut(c, h.jobRepo, h.automationRepo, h.queueClient, automation, newJob, paramsMap, *original.FanOutParam)
+		return
+	}
+	h.submitRetry(c, automation, newJob, paramsMap)
+}
+
+// RetryFailedJob cria um job novo só com os itens que falharam no original:
+// os valores de result.summary.failed[] viram a lista do parâmetro declarado
+// em automation.retryFailed, e o resto dos parâmetros é copiado. O job novo
+// aponta pro original em retryOf. Job em aberto, automação sem retryFailed ou
+// result sem item falho são rejeitados. Aceita Idempotency-Key como o
+// RetryJob.
+func (h *JobHandler) RetryFailedJob(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	idempotencyKey, msg := parseIdempotencyKey(c)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	original, err := h.jobRepo.GetByID(c.Request.Context(), jobID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job original não encontrado"})
+		return
+	}
+	if !terminalJobStatuses[original.Status] {
+		c.JSON(http.StatusConflict, gin.H{"error": "Job ainda não terminou (status " + original.Status + ")"})
+		return
+	}
+	if original.IsFanOutParent() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Job pai de fan-out: reexecute os filhos que falharam"})
+		return
+	}
+
+	automation, err := h.automationRepo.GetByID(c.Request.Context(), original.AutomationID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Automação não encontrada"})
+		return
+	}
+	if automation.RetryFailed == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A automação não declara retryFailed — use POST /jobs/:id/retry"})
+		return
+	}
+
+	var userID *int
+	if uid, ok := c.Get("user_id"); ok {
+		if id, ok := uid.(int); ok {
+			userID = &id
+		}
+	}
+
+	var originalParams map[string]interface{}
+	if len(original.Parameters) > 0 {
+		_ = json.Unmarshal(original.Parameters, &originalParams)
+	}
+	paramsMap, _, err := retry.FailedParams(originalParams, original.Result, automation.RetryFailed)
+	if err != nil {
+		if errors.Is(err, retry.ErrNothingToRetry) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Nada a retentar: " + err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Erro ao montar parâmetros: " + err.Error()})
+		return
+	}
+	paramsJSON, err := json.Marshal(paramsMap)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Erro ao processar parâmetros: " + err.Error()})
+		return
+	}
+
+	newJob := &models.Job{
+		AutomationID:   original.AutomationID,
+		UserID:         userID,
+		Status:         "pending",
+		Parameters:     paramsJSON,
+		Trigger:        models.TriggerRetry,
+		IdempotencyKey: idempotencyKey,
+		ParentJobID:    original.ParentJobID,
+		FanOutItem:     original.FanOutItem,
+		PipelineRunID:  original.PipelineRunID,
+		PipelineStep:   original.PipelineStep,
+		RetryOf:        &original.ID,
+	}
+	h.submitRetry(c, automation, newJob, paramsMap)
+}
+
+// submitRetry cria o job de uma reexecução e o publica, ou responde com o job
+// já criado pela mesma Idempotency-Key.
+func (h *JobHandler) submitRetry(c *gin.Context, automation *models.Automation, newJob *models.Job, paramsMap map[string]interface{}) {
+	newJob, created, err := createJob(c.Request.Context(), h.jobRepo, newJob)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar job: " + err.Error()})
+		return
+	}
+	if !created {
+		replayJob(c, h.jobRepo, h.automationRepo, newJob, automation.ID)
+		return
+	}
+	refreshFanOut(c.Request.Context(), h.jobRepo, newJob)
+
+	if newJob.Held {
+		// Limite de concorrência: o dispatcher publica quando houver vaga.
+		fillQueuePositions(c.Request.Context(), h.jobRepo, h.automationRepo, newJob)
+		c.JSON(http.StatusAccepted, newJob)
+		return
+	}
 
 	queueMsg := queue.JobMessage{
 		JobID:        newJob.ID.String(),
-		AutomationID: original.AutomationID,
+		AutomationID: automation.ID,
 		ScriptPath:   automation.ScriptPath,
 		Parameters:   paramsMap,
+		Priority:     newJob.Priority,
 	}
 
 	queueName := automation.QueueName
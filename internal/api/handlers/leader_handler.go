@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// LeadershipReporter é implementado pelo leader.Elector.
+type LeadershipReporter interface {
+	Holder() string
+	IsLeader() bool
+	LeaderSince() time.Time
+	Lease(ctx context.Context) (*models.LeaderLease, error)
+}
+
+type LeaderHandler struct {
+	elector LeadershipReporter
+}
+
+func NewLeaderHandler(elector LeadershipReporter) *LeaderHandler {
+	return &LeaderHandler{elector: elector}
+}
+
+// GetLeader mostra o estado de liderança visto por ESTA réplica e o lease como
+// está no banco (quem é o líder de fato). Resposta:
+//
+//	{
+//	  "instance":    "a1b2c3:1",   // esta réplica
+//	  "isLeader":    true,
+//	  "leaderSince": "2026-06-17T10:00:00Z",  // omitido em standby
+//	  "lease": { "holder": "a1b2c3:1", "acquiredAt": ..., "renewedAt": ..., "expiresAt": ... },
+//	  "leaseExpired": false
+//	}
+//
+// lease vem null se nenhuma réplica pegou o lease ainda. leaseExpired=true
+// indica líder morto cujo lease ainda não foi tomado por um standby.
+func (h *LeaderHandler) GetLeader(c *gin.Context) {
+	lease, err := h.elector.Lease(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar lease de liderança: " + err.Error()})
+		return
+	}
+
+	resp := gin.H{
+		"instance":     h.elector.Holder(),
+		"isLeader":     h.elector.IsLeader(),
+		"lease":        lease,
+		"leaseExpired": lease != nil && time.Now().After(lease.ExpiresAt),
+	}
+	if since := h.elector.LeaderSince(); !since.IsZero() {
+		resp["leaderSince"] = since
+	}
+	c.JSON(http.StatusOK, resp)
+}
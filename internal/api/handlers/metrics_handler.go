@@ -100,7 +100,7 @@ const recentRuns = 12
 
 // GetAutomationHealth retorna a saúde agregada por automação no período pedido
 // (?range=24h|7d|30d, default 24h): total/sucesso/falha/cancelado, taxa de
-// sucesso, duração p50/p95, split manual×agendado, e os últimos status.
+// sucesso, duração p50/p95, split por origem (manual/agendado/retry/api), e os últimos status.
 func (h *MetricsHandler) GetAutomationHealth(c *gin.Context) {
 	spec, ok := parseRange(c)
 	if !ok {
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+	"github.com/EnzzoHosaki/rps-maestro/internal/paramschema"
+	"github.com/gin-gonic/gin"
+)
+
+// validateParams confere params contra o parameterSchema da automação e, se
+// algo não passar, responde 400 com os erros por campo em "fields" e devolve
+// false.
+func validateParams(c *gin.Context, automation *models.Automation, params map[string]interface{}, opts paramschema.Options) bool {
+	schema, err := paramschema.Parse(automation.ParameterSchema)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Automação com " + err.Error()})
+		return false
+	}
+	if errs := paramschema.Validate(schema, params, opts); errs != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parâmetros inválidos: " + errs.Error(), "fields": errs})
+		return false
+	}
+	return true
+}
+
+// prepareParams aplica o defaultParams da automação nos parâmetros ausentes e
+// valida o resultado (ver validateParams). Devolve os parâmetros completos e
+// false se já respondeu com erro.
+func prepareParams(c *gin.Context, automation *models.Automation, params map[string]interface{}, opts paramschema.Options) (map[string]interface{}, bool) {
+	full, err := paramschema.ApplyDefaults(params, automation.DefaultParams)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Automação com " + err.Error()})
+		return nil, false
+	}
+	if !validateParams(c, automation, full, opts) {
+		return nil, false
+	}
+	return full, true
+}
@@ -0,0 +1,355 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+	"github.com/EnzzoHosaki/rps-maestro/internal/pipeline"
+	"github.com/EnzzoHosaki/rps-maestro/internal/repository"
+	"github.com/EnzzoHosaki/rps-maestro/internal/scheduler"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PipelineRuntime é implementado pelo scheduler: cria a execução com os
+// passos iniciais; os seguintes saem pelo avanço da réplica líder.
+type PipelineRuntime interface {
+	StartPipelineRun(ctx context.Context, p *models.Pipeline, userID *int, overrides map[string]map[string]interface{}) (*models.PipelineRun, error)
+}
+
+type PipelineHandler struct {
+	pipelineRepo   repository.PipelineRepository
+	automationRepo repository.AutomationRepository
+	jobRepo        repository.JobRepository
+	runtime        PipelineRuntime
+}
+
+func NewPipelineHandler(
+	pipelineRepo repository.PipelineRepository,
+	automationRepo repository.AutomationRepository,
+	jobRepo repository.JobRepository,
+	runtime PipelineRuntime,
+) *PipelineHandler {
+	return &PipelineHandler{
+		pipelineRepo:   pipelineRepo,
+		automationRepo: automationRepo,
+		jobRepo:        jobRepo,
+		runtime:        runtime,
+	}
+}
+
+type pipelinePayload struct {
+	Name        string                `json:"name" binding:"required"`
+	Description *string               `json:"description"`
+	Steps       []models.PipelineStep `json:"steps" binding:"required"`
+	Edges       []models.PipelineEdge `json:"edges"`
+}
+
+// toModel valida o DAG e confere que as automações dos passos existem.
+func (h *PipelineHandler) toModel(ctx context.Context, req pipelinePayload) (models.Pipeline, error) {
+	p := models.Pipeline{
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		Steps:       req.Steps,
+		Edges:       req.Edges,
+	}
+	if p.Edges == nil {
+		p.Edges = []models.PipelineEdge{}
+	}
+	if err := pipeline.Validate(p.Steps, p.Edges); err != nil {
+		return p, err
+	}
+	for _, s := range p.Steps {
+		if _, err := h.automationRepo.GetByID(ctx, s.AutomationID); err != nil {
+			return p, fmt.Errorf("automação %d do passo %q não encontrada", s.AutomationID, s.Key)
+		}
+	}
+	return p, nil
+}
+
+// CreatePipeline cadastra um pipeline. Body:
+//
+//	{
+//	  "name": "XML do mês",
+//	  "steps": [
+//	    {"key": "download", "automationId": 1, "parameters": {"competencia": "2026-05"}},
+//	    {"key": "import", "automationId": 2, "inputs": {"pasta": "download.summary.pasta"}},
+//	    {"key": "alerta", "automationId": 3}
+//	  ],
+//	  "edges": [
+//	    {"from": "download", "to": "import", "on": "on_success"},
+//	    {"from": "import", "to": "alerta", "on": "on_failure"}
+//	  ]
+//	}
+func (h *PipelineHandler) CreatePipeline(c *gin.Context) {
+	var req pipelinePayload
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos: " + err.Error()})
+		return
+	}
+
+	p, err := h.toModel(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Pipeline inválido: " + err.Error()})
+		return
+	}
+	if err := h.pipelineRepo.Create(c.Request.Context(), &p); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar pipeline: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, p)
+}
+
+func (h *PipelineHandler) GetAllPipelines(c *gin.Context) {
+	pipelines, err := h.pipelineRepo.GetAll(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar pipelines: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, pipelines)
+}
+
+func (h *PipelineHandler) GetPipelineByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	p, err := h.pipelineRepo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pipeline não encontrado"})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// UpdatePipeline troca a definição; execuções em andamento seguem a nova
+// definição a partir do próximo passo.
+func (h *PipelineHandler) UpdatePipeline(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req pipelinePayload
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos: " + err.Error()})
+		return
+	}
+
+	p, err := h.toModel(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Pipeline inválido: " + err.Error()})
+		return
+	}
+	p.ID = id
+	if err := h.pipelineRepo.Update(c.Request.Context(), &p); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar pipeline: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+func (h *PipelineHandler) DeletePipeline(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	if err := h.pipelineRepo.Delete(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao deletar pipeline: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Pipeline deletado com sucesso"})
+}
+
+// RunPipeline inicia uma execução. Body opcional, com parâmetros por passo
+// que sobrescrevem os da definição:
+//
+//	{ "parameters": { "download": {"competencia": "2026-06"} } }
+func (h *PipelineHandler) RunPipeline(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req struct {
+		Parameters map[string]map[string]interface{} `json:"parameters"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos: " + err.Error()})
+		return
+	}
+
+	p, err := h.pipelineRepo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pipeline não encontrado"})
+		return
+	}
+	for key := range req.Parameters {
+		if !hasPipelineStep(p, key) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("parameters: passo %q não existe no pipeline", key)})
+			return
+		}
+	}
+
+	var userID *int
+	if uid, ok := c.Get("user_id"); ok {
+		if n, ok := uid.(int); ok {
+			userID = &n
+		}
+	}
+
+	run, err := h.runtime.StartPipelineRun(c.Request.Context(), p, userID, req.Parameters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao iniciar pipeline: " + err.Error()})
+		return
+	}
+	h.fillRunSteps(c.Request.Context(), p, run)
+	c.JSON(http.StatusAccepted, run)
+}
+
+// GetPipelineRuns devolve o histórico de execuções (?limit=, padrão 20,
+// máximo 200), mais recentes primeiro.
+func (h *PipelineHandler) GetPipelineRuns(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	limit := 20
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 200 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit deve estar entre 1 e 200"})
+			return
+		}
+		limit = n
+	}
+
+	if _, err := h.pipelineRepo.GetByID(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pipeline não encontrado"})
+		return
+	}
+	runs, err := h.pipelineRepo.ListRuns(c.Request.Context(), id, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao listar execuções do pipeline: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+// GetPipelineRun devolve a execução com o estado de cada passo e o job mais
+// recente dele.
+func (h *PipelineHandler) GetPipelineRun(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	run, err := h.pipelineRepo.GetRun(c.Request.Context(), runID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Execução não encontrada"})
+		return
+	}
+	p, err := h.pipelineRepo.GetByID(c.Request.Context(), run.PipelineID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar pipeline: " + err.Error()})
+		return
+	}
+	h.fillRunSteps(c.Request.Context(), p, run)
+	c.JSON(http.StatusOK, run)
+}
+
+// CancelPipelineRun cancela a execução: nenhum passo novo sai e os jobs em
+// aberto recebem pedido de cancelamento. Execução já terminada → 409.
+func (h *PipelineHandler) CancelPipelineRun(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	if err := h.pipelineRepo.CancelRun(c.Request.Context(), runID); err != nil {
+		if errors.Is(err, repository.ErrPipelineRunNotRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao cancelar execução: " + err.Error()})
+		return
+	}
+
+	jobs, err := h.pipelineRepo.ListRunJobs(c.Request.Context(), runID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar jobs da execução: " + err.Error()})
+		return
+	}
+	for _, j := range jobs {
+		if terminalJobStatuses[j.Status] {
+			continue
+		}
+		if err := h.jobRepo.RequestCancellation(c.Request.Context(), j.ID); err != nil {
+			log.Printf("[pipeline_handler] execução %s: %v", runID, err)
+		}
+	}
+
+	run, err := h.pipelineRepo.GetRun(c.Request.Context(), runID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar execução: " + err.Error()})
+		return
+	}
+	if p, err := h.pipelineRepo.GetByID(c.Request.Context(), run.PipelineID); err == nil {
+		h.fillRunSteps(c.Request.Context(), p, run)
+	}
+	c.JSON(http.StatusAccepted, run)
+}
+
+// fillRunSteps preenche run.Steps, na ordem da definição. Passo sem job numa
+// execução cancelada aparece como canceled. Falha aqui não derruba a
+// resposta.
+func (h *PipelineHandler) fillRunSteps(ctx context.Context, p *models.Pipeline, run *models.PipelineRun) {
+	jobs, err := h.pipelineRepo.ListRunJobs(ctx, run.ID)
+	if err != nil {
+		log.Printf("[pipeline_handler] %v", err)
+		return
+	}
+	latest, _ := scheduler.LatestStepJobs(jobs)
+	byStep := make(map[string]*models.Job, len(jobs))
+	for i := range jobs {
+		if jobs[i].PipelineStep != nil {
+			byStep[*jobs[i].PipelineStep] = &jobs[i]
+		}
+	}
+
+	plan := pipeline.Evaluate(p.Steps, p.Edges, latest)
+	run.Steps = make([]models.PipelineRunStep, 0, len(p.Steps))
+	for _, s := range p.Steps {
+		step := models.PipelineRunStep{Key: s.Key, Status: plan.States[s.Key], Job: byStep[s.Key]}
+		if step.Job == nil && run.Status == "canceled" {
+			step.Status = "canceled"
+		}
+		run.Steps = append(run.Steps, step)
+	}
+}
+
+func hasPipelineStep(p *models.Pipeline, key string) bool {
+	for _, s := range p.Steps {
+		if s.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -2,41 +2,300 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/EnzzoHosaki/rps-maestro/internal/fanout"
 	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+	"github.com/EnzzoHosaki/rps-maestro/internal/paramschema"
 	"github.com/EnzzoHosaki/rps-maestro/internal/repository"
 	"github.com/EnzzoHosaki/rps-maestro/internal/scheduler"
 	"github.com/gin-gonic/gin"
 )
 
 // validateCron rejeita expressões cron inválidas antes de persistir, usando o
-// mesmo parser do scheduler (scheduler.ParseSchedule — 5 campos padrão + `L`
-// pro último dia do mês). Sem isso, uma expressão inválida seria salva e só
-// falharia silenciosamente no Reload, deixando o agendamento cadastrado mas
-// nunca executado.
-func validateCron(expr string) error {
-	_, err := scheduler.ParseSchedule(expr)
-	return err
+// mesmo parser do scheduler (scheduler.ParseScheduleTZ — 5 ou 6 campos, com a
+// sintaxe estendida `L`, `W`, `#`). O erro do parser já diz qual campo está
+// errado. Sem isso, uma expressão inválida seria salva e só falharia
+// silenciosamente no Reload, deixando o agendamento cadastrado mas nunca
+// executado — pelo mesmo motivo, expressão válida que nunca dispara (30 de
+// fevereiro) também é rejeitada.
+func validateCron(expr, tzName string) error {
+	sched, err := scheduler.ParseScheduleTZ(expr, tzName)
+	if err != nil {
+		return err
+	}
+	if sched.Next(time.Now()).IsZero() {
+		return errors.New("a expressão nunca dispara (ex.: dia 30 em fevereiro)")
+	}
+	return nil
+}
+
+// validateInterval valida um agendamento kind=interval: âncora e intervalo
+// obrigatórios, janela ativa opcional ("" vira nil). cronExpression é
+// ignorada e gravada vazia. Como em validateCron, uma grade que nunca cai na
+// janela ativa é rejeitada.
+func validateInterval(s *models.Schedule, tzName string) string {
+	s.CronExpression = ""
+	if s.IntervalAnchor == nil {
+		return "intervalAnchor é obrigatório para kind=interval"
+	}
+	if s.IntervalMinutes == nil || *s.IntervalMinutes < 1 || *s.IntervalMinutes > maxIntervalMinutes {
+		return "intervalMinutes deve estar entre 1 e 44640 (31 dias)"
+	}
+	s.ActiveFrom = trimmedOrNil(s.ActiveFrom)
+	s.ActiveTo = trimmedOrNil(s.ActiveTo)
+
+	sched, err := scheduler.NewIntervalSchedule(*s.IntervalAnchor, *s.IntervalMinutes, s.ActiveFrom, s.ActiveTo, tzName)
+	if err != nil {
+		return "Intervalo inválido: " + err.Error()
+	}
+	if sched.Next(time.Now()).IsZero() {
+		return "Intervalo inválido: nenhum disparo cai dentro da janela activeFrom–activeTo"
+	}
+	return ""
+}
+
+// maxIntervalMinutes limita o passo de um agendamento de intervalo a 31 dias,
+// como a coluna interval_minutes.
+const maxIntervalMinutes = 31 * 24 * 60
+
+func trimmedOrNil(v *string) *string {
+	if v == nil {
+		return nil
+	}
+	t := strings.TrimSpace(*v)
+	if t == "" {
+		return nil
+	}
+	return &t
+}
+
+// validateTimezone normaliza o timezone do agendamento ("" vira nil = fuso
+// padrão do processo) e confere o nome contra o tz database.
+func validateTimezone(s *models.Schedule) string {
+	tz, msg := normalizeTimezone(s.Timezone)
+	if msg != "" {
+		return msg
+	}
+	s.Timezone = tz
+	return ""
+}
+
+// normalizeTimezone apara o nome ("" vira nil) e o confere contra o tz
+// database. "Local" é rejeitado: dependeria do TZ do container, justamente o
+// que um fuso explícito evita.
+func normalizeTimezone(tz *string) (*string, string) {
+	if tz == nil {
+		return nil, ""
+	}
+	name := strings.TrimSpace(*tz)
+	if name == "" {
+		return nil, ""
+	}
+	if _, err := time.LoadLocation(name); err != nil || name == "Local" {
+		return nil, "Fuso horário inválido: " + name + " (use um nome IANA, ex.: America/Manaus)"
+	}
+	return &name, ""
+}
+
+// maxMisfireLookbackMinutes limita a janela de recuperação de disparos
+// perdidos a 31 dias — mais que isso é restaurar backup, não misfire.
+const maxMisfireLookbackMinutes = 31 * 24 * 60
+
+// validateSchedulePayload normaliza e valida os campos de um agendamento em
+// create/update. Retorna "" quando válido (mesmo contrato de
+// validateAutomationPayload).
+func validateSchedulePayload(s *models.Schedule) string {
+	if msg := validateTimezone(s); msg != "" {
+		return msg
+	}
+	var tzName string
+	if s.Timezone != nil {
+		tzName = *s.Timezone
+	}
+	switch s.Kind {
+	case "", models.ScheduleKindCron:
+		s.Kind = models.ScheduleKindCron
+		if s.IntervalAnchor != nil || s.IntervalMinutes != nil || s.ActiveFrom != nil || s.ActiveTo != nil {
+			return "intervalAnchor, intervalMinutes, activeFrom e activeTo só valem com kind=interval"
+		}
+		if err := validateCron(s.CronExpression, tzName); err != nil {
+			return "Expressão cron inválida: " + err.Error()
+		}
+	case models.ScheduleKindInterval:
+		if msg := validateInterval(s, tzName); msg != "" {
+			return msg
+		}
+	default:
+		return "kind inválido: use cron ou interval"
+	}
+
+	switch s.MisfirePolicy {
+	case "":
+		s.MisfirePolicy = models.MisfireSkip
+	case models.MisfireSkip, models.MisfireRunOnce, models.MisfireRunAll:
+	default:
+		return "misfirePolicy inválida: use skip, run_once ou run_all"
+	}
+	if s.MisfireMaxLookbackMinutes == 0 {
+		s.MisfireMaxLookbackMinutes = 24 * 60
+	}
+	if s.MisfireMaxLookbackMinutes < 0 || s.MisfireMaxLookbackMinutes > maxMisfireLookbackMinutes {
+		return "misfireMaxLookbackMinutes deve estar entre 1 e 44640 (31 dias)"
+	}
+
+	switch s.OverlapPolicy {
+	case "":
+		s.OverlapPolicy = models.OverlapAllow
+	case models.OverlapAllow, models.OverlapSkip, models.OverlapQueueAfter, models.OverlapCancelPrevious:
+	default:
+		return "overlapPolicy inválida: use allow, skip, queue_after ou cancel_previous"
+	}
+
+	switch s.HolidayPolicy {
+	case "":
+		s.HolidayPolicy = models.HolidayIgnore
+	case models.HolidayIgnore, models.HolidaySkip, models.HolidayNextBusinessDay:
+	default:
+		return "holidayPolicy inválida: use ignore, skip ou next_business_day"
+	}
+
+	if msg := validateScheduleParams(s.Parameters); msg != "" {
+		return msg
+	}
+	return validateScheduleFanOut(s)
 }
 
-// ScheduleReloader é implementado pelo scheduler para sincronizar agendamentos após mudanças via API.
-type ScheduleReloader interface {
+// validateScheduleFanOut confere que o fanOutParam aponta pra uma lista dos
+// parâmetros que dá pra dividir (ver fanout.Split).
+func validateScheduleFanOut(s *models.Schedule) string {
+	s.FanOutParam = trimmedOrNil(s.FanOutParam)
+	if s.FanOutParam == nil {
+		return ""
+	}
+	var params map[string]interface{}
+	if len(s.Parameters) > 0 {
+		_ = json.Unmarshal(s.Parameters, &params)
+	}
+	if _, err := fanout.Split(params, *s.FanOutParam); err != nil {
+		return "fanOutParam inválido: " + err.Error()
+	}
+	return ""
+}
+
+// validateScheduleParams confere os placeholders de data dos parâmetros:
+// token desconhecido ou mal formado ({{ontem}}, {{today|}}) é rejeitado no
+// cadastro em vez de chegar literal no worker.
+func validateScheduleParams(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var params map[string]interface{}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return "parameters deve ser um objeto JSON"
+	}
+	if errs := scheduler.ValidatePlaceholders(params); len(errs) > 0 {
+		return "Placeholders inválidos: " + strings.Join(errs, "; ")
+	}
+	return ""
+}
+
+// ScheduleRuntime é implementado pelo scheduler: sincroniza agendamentos após
+// mudanças via API, resolve o fuso efetivo de cada agendamento, calcula o
+// preview dos próximos disparos e dispara sob demanda.
+type ScheduleRuntime interface {
 	Reload(ctx context.Context) error
+	ReloadSchedule(ctx context.Context, id int) error
+	Location(timezone *string) (*time.Location, string)
+	Preview(ctx context.Context, sc *models.Schedule, from time.Time, count int) ([]models.ScheduleFirePreview, error)
+	ActivePause(ctx context.Context, sc *models.Schedule, t time.Time) (*models.SchedulePause, error)
+	RunNow(ctx context.Context, sc *models.Schedule, userID *int) (*models.Job, error)
 }
 
 type ScheduleHandler struct {
-	scheduleRepo repository.ScheduleRepository
-	reloader     ScheduleReloader
+	scheduleRepo   repository.ScheduleRepository
+	eventRepo      repository.ScheduleEventRepository
+	calendarRepo   repository.CalendarRepository
+	jobRepo        repository.JobRepository
+	automationRepo repository.AutomationRepository
+	scheduler      ScheduleRuntime
 }
 
-func NewScheduleHandler(scheduleRepo repository.ScheduleRepository, reloader ScheduleReloader) *ScheduleHandler {
+func NewScheduleHandler(
+	scheduleRepo repository.ScheduleRepository,
+	eventRepo repository.ScheduleEventRepository,
+	calendarRepo repository.CalendarRepository,
+	jobRepo repository.JobRepository,
+	automationRepo repository.AutomationRepository,
+	scheduler ScheduleRuntime,
+) *ScheduleHandler {
 	return &ScheduleHandler{
-		scheduleRepo: scheduleRepo,
-		reloader:     reloader,
+		scheduleRepo:   scheduleRepo,
+		eventRepo:      eventRepo,
+		calendarRepo:   calendarRepo,
+		jobRepo:        jobRepo,
+		automationRepo: automationRepo,
+		scheduler:      scheduler,
+	}
+}
+
+// validateCalendar confere que o calendário referenciado existe — sem isso a
+// FK estouraria como erro 500 no insert.
+func (h *ScheduleHandler) validateCalendar(ctx context.Context, s *models.Schedule) string {
+	if s.CalendarID == nil {
+		return ""
 	}
+	if _, err := h.calendarRepo.GetByID(ctx, *s.CalendarID); err != nil {
+		return "Calendário não encontrado: " + strconv.Itoa(*s.CalendarID)
+	}
+	return ""
+}
+
+// validateAutomationParams confere os parâmetros do agendamento contra o
+// parameterSchema da automação, com o defaultParams aplicado como no disparo.
+// Placeholders ({{today-1}}) passam — o scheduler só os expande no disparo.
+// Responde e devolve false se algo não passar.
+func (h *ScheduleHandler) validateAutomationParams(c *gin.Context, s *models.Schedule) bool {
+	automation, err := h.automationRepo.GetByID(c.Request.Context(), s.AutomationID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Automação não encontrada: " + strconv.Itoa(s.AutomationID)})
+		return false
+	}
+	var params map[string]interface{}
+	if len(s.Parameters) > 0 {
+		_ = json.Unmarshal(s.Parameters, &params)
+	}
+	_, ok := prepareParams(c, automation, params, paramschema.Options{AllowPlaceholders: true})
+	return ok
+}
+
+// decorate preenche os campos calculados da resposta: o fuso efetivo, o
+// próximo disparo no fuso do agendamento e em UTC (nextRunAt continua saindo
+// como está no banco) e a pausa em vigor agora.
+func (h *ScheduleHandler) decorate(ctx context.Context, s *models.Schedule) {
+	loc, name := h.scheduler.Location(s.Timezone)
+	if name == "" {
+		name = loc.String()
+	}
+	s.EffectiveTimezone = name
+	if s.NextRunAt != nil {
+		local := s.NextRunAt.In(loc)
+		utc := s.NextRunAt.UTC()
+		s.NextRunAtLocal = &local
+		s.NextRunAtUTC = &utc
+	}
+	pause, err := h.scheduler.ActivePause(ctx, s, time.Now())
+	if err != nil {
+		log.Printf("[schedule_handler] erro ao calcular pausa do agendamento %d: %v", s.ID, err)
+		return
+	}
+	s.ActivePause = pause
 }
 
 func (h *ScheduleHandler) CreateSchedule(c *gin.Context) {
@@ -47,8 +306,15 @@ func (h *ScheduleHandler) CreateSchedule(c *gin.Context) {
 		return
 	}
 
-	if err := validateCron(schedule.CronExpression); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Expressão cron inválida: " + err.Error()})
+	if msg := validateSchedulePayload(&schedule); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if msg := h.validateCalendar(c.Request.Context(), &schedule); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if !h.validateAutomationParams(c, &schedule) {
 		return
 	}
 
@@ -57,13 +323,15 @@ func (h *ScheduleHandler) CreateSchedule(c *gin.Context) {
 		return
 	}
 
-	h.triggerReload(c.Request.Context())
+	h.triggerReload(c.Request.Context(), schedule.ID)
 
 	// Re-busca após o reload para devolver o next_run_at recém-calculado pelo scheduler.
 	if fresh, err := h.scheduleRepo.GetByID(c.Request.Context(), schedule.ID); err == nil {
+		h.decorate(c.Request.Context(), fresh)
 		c.JSON(http.StatusCreated, fresh)
 		return
 	}
+	h.decorate(c.Request.Context(), &schedule)
 	c.JSON(http.StatusCreated, schedule)
 }
 
@@ -81,16 +349,62 @@ func (h *ScheduleHandler) GetScheduleByID(c *gin.Context) {
 		return
 	}
 
+	h.decorate(c.Request.Context(), schedule)
 	c.JSON(http.StatusOK, schedule)
 }
 
-func (h *ScheduleHandler) GetAllEnabledSchedules(c *gin.Context) {
-	schedules, err := h.scheduleRepo.GetAllEnabled(c.Request.Context())
+// ListSchedules lista os agendamentos, habilitados ou não.
+//
+// Query params suportados:
+//   - automation_id: int
+//   - enabled:       true | false
+//   - next_run_from: RFC3339 (próximo disparo a partir desta data)
+//   - next_run_to:   RFC3339 (próximo disparo até esta data)
+func (h *ScheduleHandler) ListSchedules(c *gin.Context) {
+	filter := models.ScheduleListFilter{}
+
+	if v := c.Query("automation_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "automation_id inválido"})
+			return
+		}
+		filter.AutomationID = &id
+	}
+	if v := c.Query("enabled"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "enabled inválido (use true ou false)"})
+			return
+		}
+		filter.Enabled = &enabled
+	}
+	if v := c.Query("next_run_from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "next_run_from inválido (use RFC3339)"})
+			return
+		}
+		filter.NextRunFrom = &t
+	}
+	if v := c.Query("next_run_to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "next_run_to inválido (use RFC3339)"})
+			return
+		}
+		filter.NextRunTo = &t
+	}
+
+	schedules, err := h.scheduleRepo.List(c.Request.Context(), filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar agendamentos: " + err.Error()})
 		return
 	}
 
+	for i := range schedules {
+		h.decorate(c.Request.Context(), &schedules[i])
+	}
 	c.JSON(http.StatusOK, schedules)
 }
 
@@ -108,8 +422,15 @@ func (h *ScheduleHandler) UpdateSchedule(c *gin.Context) {
 		return
 	}
 
-	if err := validateCron(schedule.CronExpression); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Expressão cron inválida: " + err.Error()})
+	if msg := validateSchedulePayload(&schedule); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if msg := h.validateCalendar(c.Request.Context(), &schedule); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if !h.validateAutomationParams(c, &schedule) {
 		return
 	}
 
@@ -119,13 +440,15 @@ func (h *ScheduleHandler) UpdateSchedule(c *gin.Context) {
 		return
 	}
 
-	h.triggerReload(c.Request.Context())
+	h.triggerReload(c.Request.Context(), id)
 
 	// Re-busca após o reload para devolver o next_run_at recém-calculado pelo scheduler.
 	if fresh, err := h.scheduleRepo.GetByID(c.Request.Context(), id); err == nil {
+		h.decorate(c.Request.Context(), fresh)
 		c.JSON(http.StatusOK, fresh)
 		return
 	}
+	h.decorate(c.Request.Context(), &schedule)
 	c.JSON(http.StatusOK, schedule)
 }
 
@@ -142,12 +465,372 @@ func (h *ScheduleHandler) DeleteSchedule(c *gin.Context) {
 		return
 	}
 
-	h.triggerReload(c.Request.Context())
+	h.triggerReload(c.Request.Context(), id)
 	c.Status(http.StatusNoContent)
 }
 
-func (h *ScheduleHandler) triggerReload(ctx context.Context) {
-	if err := h.reloader.Reload(ctx); err != nil {
+// PreviewSchedule calcula os próximos disparos de um agendamento AINDA NÃO
+// salvo, com os parâmetros expandidos como o disparo real publicaria. Query:
+//
+//	cronExpression (obrigatório com kind=cron, o padrão), kind,
+//	intervalAnchor (RFC3339), intervalMinutes, activeFrom, activeTo,
+//	timezone, calendarId, holidayPolicy, parameters (objeto JSON
+//	url-encoded), count (padrão 5, máx. 50), from (RFC3339, padrão agora)
+//
+// Passa pelas mesmas validações do cadastro — um 200 aqui significa que o
+// POST /schedules com os mesmos campos também seria aceito.
+func (h *ScheduleHandler) PreviewSchedule(c *gin.Context) {
+	schedule := models.Schedule{
+		CronExpression: c.Query("cronExpression"),
+		Kind:           c.Query("kind"),
+		HolidayPolicy:  c.Query("holidayPolicy"),
+	}
+	if schedule.Kind == models.ScheduleKindInterval {
+		if v := c.Query("intervalAnchor"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "intervalAnchor inválido: use RFC3339 (ex.: 2026-06-01T07:15:00-03:00)"})
+				return
+			}
+			schedule.IntervalAnchor = &t
+		}
+		if v := c.Query("intervalMinutes"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "intervalMinutes inválido"})
+				return
+			}
+			schedule.IntervalMinutes = &n
+		}
+		if v := c.Query("activeFrom"); v != "" {
+			schedule.ActiveFrom = &v
+		}
+		if v := c.Query("activeTo"); v != "" {
+			schedule.ActiveTo = &v
+		}
+	} else if schedule.CronExpression == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cronExpression é obrigatório"})
+		return
+	}
+	if tz := c.Query("timezone"); tz != "" {
+		schedule.Timezone = &tz
+	}
+	if v := c.Query("calendarId"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "calendarId inválido"})
+			return
+		}
+		schedule.CalendarID = &id
+	}
+	if v := c.Query("parameters"); v != "" {
+		schedule.Parameters = json.RawMessage(v)
+	}
+
+	if msg := validateSchedulePayload(&schedule); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if msg := h.validateCalendar(c.Request.Context(), &schedule); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	h.preview(c, &schedule)
+}
+
+// PreviewScheduleByID é o preview de um agendamento salvo (habilitado ou não).
+// Aceita os mesmos count e from de PreviewSchedule.
+func (h *ScheduleHandler) PreviewScheduleByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	schedule, err := h.scheduleRepo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agendamento não encontrado"})
+		return
+	}
+
+	h.preview(c, schedule)
+}
+
+// preview responde { effectiveTimezone, fires: [{fireAt, fireAtUtc, prevRun, parameters}] }.
+func (h *ScheduleHandler) preview(c *gin.Context, schedule *models.Schedule) {
+	count := 5
+	if v := c.Query("count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "count inválido"})
+			return
+		}
+		count = n
+	}
+	from := time.Now()
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from inválido: use RFC3339 (ex.: 2026-06-01T00:00:00-03:00)"})
+			return
+		}
+		from = t
+	}
+
+	fires, err := h.scheduler.Preview(c.Request.Context(), schedule, from, count)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Erro ao calcular preview: " + err.Error()})
+		return
+	}
+
+	h.decorate(c.Request.Context(), schedule)
+	c.JSON(http.StatusOK, gin.H{
+		"effectiveTimezone": schedule.EffectiveTimezone,
+		"fires":             fires,
+	})
+}
+
+// GetScheduleEvents lista as decisões do scheduler sobre disparos do
+// agendamento que não viraram job de imediato (tick pulado, enfileirado atrás
+// do anterior, anterior cancelado), mais recentes primeiro. ?limit= (padrão
+// 50, máx. 500).
+func (h *ScheduleHandler) GetScheduleEvents(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	limit := 50
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 500 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit deve estar entre 1 e 500"})
+			return
+		}
+		limit = n
+	}
+
+	if _, err := h.scheduleRepo.GetByID(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agendamento não encontrado"})
+		return
+	}
+
+	events, err := h.eventRepo.ListBySchedule(c.Request.Context(), id, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar eventos do agendamento: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
+
+// GetScheduleRuns devolve o histórico de execuções do agendamento: resumo
+// (último status, duração do último run, falhas consecutivas desde o último
+// sucesso) e os jobs mais recentes criados por ele. ?limit= (padrão 20, máx. 200).
+//
+//	{
+//	  "scheduleId": 3, "totalRuns": 41,
+//	  "lastJobId": "...", "lastStatus": "failed", "lastRunAt": "...", "lastDurationS": 12.4,
+//	  "lastSuccessAt": "...", "consecutiveFailures": 2,
+//	  "runs": [Job]
+//	}
+func (h *ScheduleHandler) GetScheduleRuns(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	limit := 20
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 200 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit deve estar entre 1 e 200"})
+			return
+		}
+		limit = n
+	}
+
+	if _, err := h.scheduleRepo.GetByID(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agendamento não encontrado"})
+		return
+	}
+
+	stats, err := h.jobRepo.GetScheduleRunStats(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao resumir execuções do agendamento: " + err.Error()})
+		return
+	}
+	runs, _, err := h.jobRepo.List(c.Request.Context(), models.JobListFilter{ScheduleID: &id, Limit: limit})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao listar execuções do agendamento: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"scheduleId":          stats.ScheduleID,
+		"totalRuns":           stats.TotalRuns,
+		"lastJobId":           stats.LastJobID,
+		"lastStatus":          stats.LastStatus,
+		"lastRunAt":           stats.LastRunAt,
+		"lastDurationS":       stats.LastDurationS,
+		"lastSuccessAt":       stats.LastSuccessAt,
+		"consecutiveFailures": stats.ConsecutiveFailures,
+		"runs":                runs,
+	})
+}
+
+// PauseSchedule suspende os disparos do agendamento até `until` (RFC3339,
+// futuro); passado esse instante ele volta sozinho. Body:
+//
+//	{ "until": "2026-06-01T08:00:00-03:00", "reason": "manutenção SEFAZ" }
+//
+// Disparos suprimidos ficam em GET /schedules/:id/events (kind "paused").
+func (h *ScheduleHandler) PauseSchedule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	var req struct {
+		Until  string  `json:"until" binding:"required"`
+		Reason *string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos: " + err.Error()})
+		return
+	}
+	until, err := time.Parse(time.RFC3339, req.Until)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "until inválido (use RFC3339)"})
+		return
+	}
+	if !until.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "until deve estar no futuro"})
+		return
+	}
+	if req.Reason != nil {
+		if r := strings.TrimSpace(*req.Reason); r != "" {
+			req.Reason = &r
+		} else {
+			req.Reason = nil
+		}
+	}
+
+	h.setPause(c, id, &until, req.Reason)
+}
+
+// ResumeSchedule remove a pausa pontual do agendamento. Janelas de bloqueio
+// não são afetadas.
+func (h *ScheduleHandler) ResumeSchedule(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+	h.setPause(c, id, nil, nil)
+}
+
+func (h *ScheduleHandler) setPause(c *gin.Context, id int, until *time.Time, reason *string) {
+	if _, err := h.scheduleRepo.GetByID(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agendamento não encontrado"})
+		return
+	}
+	if err := h.scheduleRepo.SetPause(c.Request.Context(), id, until, reason); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar pausa do agendamento: " + err.Error()})
+		return
+	}
+
+	schedule, err := h.scheduleRepo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar agendamento: " + err.Error()})
+		return
+	}
+	h.decorate(c.Request.Context(), schedule)
+	c.JSON(http.StatusOK, schedule)
+}
+
+// EnableSchedule liga o agendamento; o próximo disparo é calculado a partir
+// de agora (disparos do período desligado não contam como misfire).
+func (h *ScheduleHandler) EnableSchedule(c *gin.Context) {
+	h.setEnabled(c, true)
+}
+
+// DisableSchedule desliga o agendamento sem apagar a configuração.
+func (h *ScheduleHandler) DisableSchedule(c *gin.Context) {
+	h.setEnabled(c, false)
+}
+
+func (h *ScheduleHandler) setEnabled(c *gin.Context, enabled bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	if err := h.scheduleRepo.SetEnabled(c.Request.Context(), id, enabled); err != nil {
+		if errors.Is(err, repository.ErrScheduleNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Agendamento não encontrado"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar agendamento: " + err.Error()})
+		return
+	}
+
+	h.triggerReload(c.Request.Context(), id)
+
+	schedule, err := h.scheduleRepo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar agendamento: " + err.Error()})
+		return
+	}
+	h.decorate(c.Request.Context(), schedule)
+	c.JSON(http.StatusOK, schedule)
+}
+
+// RunScheduleNow dispara o agendamento agora, como um tick do cron
+// (placeholders expandidos, job com scheduleId e overlap_policy aplicada),
+// com trigger manual e o usuário logado. Não altera o próximo disparo.
+// Responde 202 com o job; 409 se uma pausa ou a overlap_policy barrou.
+func (h *ScheduleHandler) RunScheduleNow(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido"})
+		return
+	}
+
+	schedule, err := h.scheduleRepo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agendamento não encontrado"})
+		return
+	}
+
+	var userID *int
+	if uid, ok := c.Get("user_id"); ok {
+		if n, ok := uid.(int); ok {
+			userID = &n
+		}
+	}
+
+	job, err := h.scheduler.RunNow(c.Request.Context(), schedule, userID)
+	if err != nil {
+		if errors.Is(err, scheduler.ErrRunSkipped) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Disparo não realizado: " + err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao disparar agendamento: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, job)
+}
+
+// triggerReload aplica a mudança no scheduler desta réplica antes de
+// responder, pra resposta já trazer o next_run_at novo. As demais réplicas
+// recebem a mesma mudança pelo NOTIFY do repositório.
+func (h *ScheduleHandler) triggerReload(ctx context.Context, id int) {
+	if err := h.scheduler.ReloadSchedule(ctx, id); err != nil {
 		log.Printf("[schedule_handler] erro ao recarregar scheduler: %v", err)
 	}
 }
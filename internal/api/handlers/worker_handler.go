@@ -1,27 +1,35 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/EnzzoHosaki/rps-maestro/internal/models"
 	"github.com/EnzzoHosaki/rps-maestro/internal/repository"
+	"github.com/EnzzoHosaki/rps-maestro/internal/retry"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 type WorkerHandler struct {
-	jobRepo    repository.JobRepository
-	jobLogRepo repository.JobLogRepository
+	jobRepo        repository.JobRepository
+	jobLogRepo     repository.JobLogRepository
+	automationRepo repository.AutomationRepository
 }
 
 func NewWorkerHandler(
 	jobRepo repository.JobRepository,
 	jobLogRepo repository.JobLogRepository,
+	automationRepo repository.AutomationRepository,
 ) *WorkerHandler {
 	return &WorkerHandler{
-		jobRepo:    jobRepo,
-		jobLogRepo: jobLogRepo,
+		jobRepo:        jobRepo,
+		jobLogRepo:     jobLogRepo,
+		automationRepo: automationRepo,
 	}
 }
 
@@ -138,12 +146,27 @@ func (h *WorkerHandler) HandleJobFinish(c *gin.Context) {
 		return
 	}
 
-	_, err = h.jobRepo.GetByID(c.Request.Context(), jobID)
+	job, err := h.jobRepo.GetByID(c.Request.Context(), jobID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Job não encontrado"})
 		return
 	}
 
+	// Falha retentável pela RetryPolicy da automação: em vez de fechar o job
+	// como failed, ele volta pra fila adiada com o erro desta tentativa.
+	if finishRequest.Status == "failed" {
+		if retryAt, attempt, ok := h.scheduleRetry(c.Request.Context(), job, finishRequest.Result); ok {
+			c.JSON(http.StatusOK, gin.H{
+				"message": "Job com falha reagendado pela política de retry",
+				"job_id":  jobID,
+				"status":  "scheduled",
+				"attempt": attempt,
+				"runAt":   retryAt,
+			})
+			return
+		}
+	}
+
 	if err := h.jobRepo.UpdateStatus(c.Request.Context(), jobID, finishRequest.Status); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar status: " + err.Error()})
 		return
@@ -167,6 +190,13 @@ func (h *WorkerHandler) HandleJobFinish(c *gin.Context) {
 		}
 	}
 
+	// Filho de fan-out: o status do pai é agregado dos filhos.
+	if job.ParentJobID != nil {
+		if err := h.jobRepo.RefreshParent(c.Request.Context(), *job.ParentJobID); err != nil {
+			log.Printf("[worker_handler] %v", err)
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Job finalizado com sucesso",
 		"job_id":  jobID,
@@ -174,6 +204,56 @@ func (h *WorkerHandler) HandleJobFinish(c *gin.Context) {
 	})
 }
 
+// scheduleRetry aplica a RetryPolicy da automação a um job que o worker
+// reportou como failed: se result.error_class é retentável e ainda há
+// tentativas, grava o resultado desta tentativa, agenda a próxima pro fim do
+// backoff e registra no log do job. Devolve o horário e o número da próxima
+// tentativa; ok=false = segue o fluxo normal (job vira failed). Cancelamento
+// pedido nunca é retentado.
+func (h *WorkerHandler) scheduleRetry(ctx context.Context, job *models.Job, result map[string]interface{}) (time.Time, int, bool) {
+	if job.CancellationRequestedAt != nil {
+		return time.Time{}, 0, false
+	}
+	automation, err := h.automationRepo.GetByID(ctx, job.AutomationID)
+	if err != nil || automation.RetryPolicy == nil {
+		return time.Time{}, 0, false
+	}
+	errorClass, _ := result["error_class"].(string)
+	delay, ok := retry.Decide(automation.RetryPolicy, errorClass, job.RetryCount)
+	if !ok {
+		return time.Time{}, 0, false
+	}
+
+	if result != nil {
+		resultJSON, err := json.Marshal(result)
+		if err == nil {
+			err = h.jobRepo.SetResult(ctx, job.ID, resultJSON)
+		}
+		if err != nil {
+			log.Printf("[worker_handler] erro ao salvar resultado do job %s antes do retry: %v", job.ID, err)
+		}
+	}
+
+	runAt := time.Now().Add(delay)
+	retryCount, err := h.jobRepo.ScheduleRetry(ctx, job.ID, runAt)
+	if err != nil {
+		log.Printf("[worker_handler] erro ao agendar retry do job %s: %v", job.ID, err)
+		return time.Time{}, 0, false
+	}
+
+	attempt := retryCount + 1
+	entry := &models.JobLog{
+		JobID: job.ID,
+		Level: "WARNING",
+		Message: fmt.Sprintf("Falha %s retentável — tentativa %d de %d agendada para %s",
+			errorClass, attempt, automation.RetryPolicy.MaxAttempts, runAt.Format("2006-01-02 15:04:05 MST")),
+	}
+	if err := h.jobLogRepo.Create(ctx, entry); err != nil {
+		log.Printf("[worker_handler] erro ao registrar retry do job %s: %v", job.ID, err)
+	}
+	return runAt, attempt, true
+}
+
 // HandleJobStatus expõe o estado atual do job pra o worker decidir o que fazer
 // antes de processar uma mensagem. Existe pra cobrir o caso de redelivery:
 // quando o RabbitMQ reenfileira uma mensagem porque o basic_ack falhou (canal
@@ -9,6 +9,7 @@ import (
 	"github.com/EnzzoHosaki/rps-maestro/internal/api/handlers"
 	"github.com/EnzzoHosaki/rps-maestro/internal/api/middleware"
 	"github.com/EnzzoHosaki/rps-maestro/internal/config"
+	"github.com/EnzzoHosaki/rps-maestro/internal/leader"
 	"github.com/EnzzoHosaki/rps-maestro/internal/queue"
 	"github.com/EnzzoHosaki/rps-maestro/internal/repository"
 	"github.com/EnzzoHosaki/rps-maestro/internal/scheduler"
@@ -26,8 +27,13 @@ type Server struct {
 	jobRepo        repository.JobRepository
 	jobLogRepo     repository.JobLogRepository
 	scheduleRepo   repository.ScheduleRepository
+	eventRepo      repository.ScheduleEventRepository
+	calendarRepo   repository.CalendarRepository
+	blackoutRepo   repository.BlackoutWindowRepository
+	pipelineRepo   repository.PipelineRepository
 	queueClient    *queue.RabbitMQClient
 	scheduler      *scheduler.Scheduler
+	elector        *leader.Elector
 	router         *gin.Engine
 	httpServer     *http.Server
 }
@@ -41,8 +47,13 @@ func NewServer(
 	jobRepo repository.JobRepository,
 	jobLogRepo repository.JobLogRepository,
 	scheduleRepo repository.ScheduleRepository,
+	eventRepo repository.ScheduleEventRepository,
+	calendarRepo repository.CalendarRepository,
+	blackoutRepo repository.BlackoutWindowRepository,
+	pipelineRepo repository.PipelineRepository,
 	queueClient *queue.RabbitMQClient,
 	sched *scheduler.Scheduler,
+	elector *leader.Elector,
 ) *Server {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
@@ -61,8 +72,8 @@ func NewServer(
 
 	corsConfig := cors.Config{
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-Worker-API-Key"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-Worker-API-Key", "Idempotency-Key"},
+		ExposeHeaders:    []string{"Content-Length", "Idempotent-Replayed"},
 		AllowCredentials: false,
 		MaxAge:           12 * time.Hour,
 	}
@@ -84,8 +95,13 @@ func NewServer(
 		jobRepo:        jobRepo,
 		jobLogRepo:     jobLogRepo,
 		scheduleRepo:   scheduleRepo,
+		eventRepo:      eventRepo,
+		calendarRepo:   calendarRepo,
+		blackoutRepo:   blackoutRepo,
+		pipelineRepo:   pipelineRepo,
 		queueClient:    queueClient,
 		scheduler:      sched,
+		elector:        elector,
 		router:         router,
 	}
 
@@ -110,7 +126,7 @@ func (s *Server) setupRoutes() {
 		}
 	}
 
-	workerHandler := handlers.NewWorkerHandler(s.jobRepo, s.jobLogRepo)
+	workerHandler := handlers.NewWorkerHandler(s.jobRepo, s.jobLogRepo, s.automationRepo)
 	worker := v1.Group("/worker", middleware.WorkerAPIKey(s.workerAPIKey))
 	{
 		worker.POST("/jobs/:id/start", workerHandler.HandleJobStart)
@@ -165,10 +181,13 @@ func (s *Server) setupRoutes() {
 	{
 		jobs.GET("", jobHandler.ListJobs)
 		jobs.GET("/:id", jobHandler.GetJobByID)
+		jobs.GET("/:id/lineage", jobHandler.GetJobLineage)
 		jobs.GET("/:id/logs", jobHandler.GetJobLogs)
 		jobs.GET("/:id/logs/stream", jobHandler.StreamJobLogs)
 		jobs.POST("/:id/cancel", operatorPlus, jobHandler.CancelJob)
 		jobs.POST("/:id/retry", operatorPlus, jobHandler.RetryJob)
+		jobs.POST("/:id/retry-failed", operatorPlus, jobHandler.RetryFailedJob)
+		jobs.POST("/:id/reschedule", operatorPlus, jobHandler.RescheduleJob)
 	}
 
 	metricsHandler := handlers.NewMetricsHandler(s.jobRepo)
@@ -177,14 +196,68 @@ func (s *Server) setupRoutes() {
 	protected.GET("/metrics/automations", metricsHandler.GetAutomationHealth)
 	protected.GET("/metrics/error-classes", metricsHandler.GetErrorClasses)
 
-	scheduleHandler := handlers.NewScheduleHandler(s.scheduleRepo, s.scheduler)
+	leaderHandler := handlers.NewLeaderHandler(s.elector)
+	protected.GET("/leader", leaderHandler.GetLeader)
+
+	scheduleHandler := handlers.NewScheduleHandler(s.scheduleRepo, s.eventRepo, s.calendarRepo, s.jobRepo, s.automationRepo, s.scheduler)
 	schedules := protected.Group("/schedules")
 	{
 		schedules.POST("", adminOnly, scheduleHandler.CreateSchedule)
-		schedules.GET("", scheduleHandler.GetAllEnabledSchedules)
+		schedules.GET("", scheduleHandler.ListSchedules)
+		schedules.GET("/preview", scheduleHandler.PreviewSchedule)
 		schedules.GET("/:id", scheduleHandler.GetScheduleByID)
+		schedules.GET("/:id/events", scheduleHandler.GetScheduleEvents)
+		schedules.GET("/:id/runs", scheduleHandler.GetScheduleRuns)
+		schedules.GET("/:id/preview", scheduleHandler.PreviewScheduleByID)
 		schedules.PUT("/:id", adminOnly, scheduleHandler.UpdateSchedule)
 		schedules.DELETE("/:id", adminOnly, scheduleHandler.DeleteSchedule)
+		schedules.POST("/:id/pause", operatorPlus, scheduleHandler.PauseSchedule)
+		schedules.POST("/:id/resume", operatorPlus, scheduleHandler.ResumeSchedule)
+		schedules.POST("/:id/enable", adminOnly, scheduleHandler.EnableSchedule)
+		schedules.POST("/:id/disable", adminOnly, scheduleHandler.DisableSchedule)
+		schedules.POST("/:id/run-now", operatorPlus, scheduleHandler.RunScheduleNow)
+	}
+
+	// Janelas de bloqueio: criadas sob o agendamento ou a automação alvo,
+	// editadas/removidas pelo próprio ID.
+	blackoutHandler := handlers.NewBlackoutHandler(s.blackoutRepo, s.scheduleRepo, s.automationRepo)
+	schedules.GET("/:id/blackouts", blackoutHandler.ListScheduleBlackouts)
+	schedules.POST("/:id/blackouts", adminOnly, blackoutHandler.CreateScheduleBlackout)
+	automations.GET("/:id/blackouts", blackoutHandler.ListAutomationBlackouts)
+	automations.POST("/:id/blackouts", adminOnly, blackoutHandler.CreateAutomationBlackout)
+	blackouts := protected.Group("/blackouts")
+	{
+		blackouts.PUT("/:id", adminOnly, blackoutHandler.UpdateBlackout)
+		blackouts.DELETE("/:id", adminOnly, blackoutHandler.DeleteBlackout)
+	}
+
+	calendarHandler := handlers.NewCalendarHandler(s.calendarRepo)
+	calendars := protected.Group("/calendars")
+	{
+		calendars.POST("", adminOnly, calendarHandler.CreateCalendar)
+		calendars.GET("", calendarHandler.GetAllCalendars)
+		calendars.GET("/:id", calendarHandler.GetCalendarByID)
+		calendars.PUT("/:id", adminOnly, calendarHandler.UpdateCalendar)
+		calendars.DELETE("/:id", adminOnly, calendarHandler.DeleteCalendar)
+		calendars.POST("/:id/holidays", adminOnly, calendarHandler.AddHoliday)
+		calendars.DELETE("/:id/holidays/:holidayId", adminOnly, calendarHandler.DeleteHoliday)
+	}
+
+	pipelineHandler := handlers.NewPipelineHandler(s.pipelineRepo, s.automationRepo, s.jobRepo, s.scheduler)
+	pipelines := protected.Group("/pipelines")
+	{
+		pipelines.POST("", adminOnly, pipelineHandler.CreatePipeline)
+		pipelines.GET("", pipelineHandler.GetAllPipelines)
+		pipelines.GET("/:id", pipelineHandler.GetPipelineByID)
+		pipelines.PUT("/:id", adminOnly, pipelineHandler.UpdatePipeline)
+		pipelines.DELETE("/:id", adminOnly, pipelineHandler.DeletePipeline)
+		pipelines.POST("/:id/run", operatorPlus, pipelineHandler.RunPipeline)
+		pipelines.GET("/:id/runs", pipelineHandler.GetPipelineRuns)
+	}
+	pipelineRuns := protected.Group("/pipeline-runs")
+	{
+		pipelineRuns.GET("/:id", pipelineHandler.GetPipelineRun)
+		pipelineRuns.POST("/:id/cancel", operatorPlus, pipelineHandler.CancelPipelineRun)
 	}
 }
 
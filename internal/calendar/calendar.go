@@ -0,0 +1,203 @@
+// Package calendar resolve dias úteis e feriados pros agendamentos.
+//
+// Um calendário combina (a) os feriados nacionais brasileiros, calculados em
+// Go pra qualquer ano (inclusive os móveis, derivados da Páscoa), quando
+// IncludeNational é true, e (b) as datas cadastradas na tabela
+// calendar_holidays — feriados estaduais/municipais, recessos, pontes. Datas
+// recorrentes valem todo ano no mesmo dia/mês.
+package calendar
+
+import (
+	"time"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+)
+
+// Holiday é um feriado efetivo numa data.
+type Holiday struct {
+	Date string `json:"date"` // YYYY-MM-DD
+	Name string `json:"name"`
+}
+
+type monthDay struct {
+	month time.Month
+	day   int
+}
+
+// Calendar responde se uma data é dia útil. Um *Calendar nil é válido e
+// considera só fim de semana como dia não útil.
+type Calendar struct {
+	name      string
+	national  bool
+	fixed     map[string]string   // "2026-03-19" → nome
+	recurring map[monthDay]string // 9/jul → nome
+}
+
+// New monta o calendário a partir do registro e das datas cadastradas.
+// Datas em formato inválido são ignoradas (a API valida na entrada).
+func New(c models.Calendar, holidays []models.CalendarHoliday) *Calendar {
+	cal := &Calendar{
+		name:      c.Name,
+		national:  c.IncludeNational,
+		fixed:     make(map[string]string),
+		recurring: make(map[monthDay]string),
+	}
+	for _, h := range holidays {
+		d, err := time.Parse(dateLayout, h.Date)
+		if err != nil {
+			continue
+		}
+		if h.Recurring {
+			cal.recurring[monthDay{d.Month(), d.Day()}] = h.Name
+		} else {
+			cal.fixed[h.Date] = h.Name
+		}
+	}
+	return cal
+}
+
+const dateLayout = "2006-01-02"
+
+// Name devolve o nome do calendário ("" pra nil).
+func (c *Calendar) Name() string {
+	if c == nil {
+		return ""
+	}
+	return c.name
+}
+
+// Holiday informa se a data (no fuso do próprio `d`) é feriado e qual.
+func (c *Calendar) Holiday(d time.Time) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	if name, ok := c.fixed[d.Format(dateLayout)]; ok {
+		return name, true
+	}
+	if name, ok := c.recurring[monthDay{d.Month(), d.Day()}]; ok {
+		return name, true
+	}
+	if c.national {
+		return NationalHoliday(d)
+	}
+	return "", false
+}
+
+// IsBusinessDay é true de segunda a sexta fora de feriado.
+func (c *Calendar) IsBusinessDay(d time.Time) bool {
+	if wd := d.Weekday(); wd == time.Saturday || wd == time.Sunday {
+		return false
+	}
+	_, holiday := c.Holiday(d)
+	return !holiday
+}
+
+// NextBusinessDay devolve o primeiro dia útil estritamente depois de d,
+// preservando o horário e o fuso de d.
+func (c *Calendar) NextBusinessDay(d time.Time) time.Time {
+	return c.AddBusinessDays(d, 1)
+}
+
+// AddBusinessDays anda n dias úteis a partir de d (n negativo anda pra trás),
+// preservando o horário e o fuso. n = 0 devolve d mesmo que não seja útil.
+func (c *Calendar) AddBusinessDays(d time.Time, n int) time.Time {
+	step := 1
+	if n < 0 {
+		step, n = -1, -n
+	}
+	for n > 0 {
+		d = d.AddDate(0, 0, step)
+		if c.IsBusinessDay(d) {
+			n--
+		}
+	}
+	return d
+}
+
+// BusinessDayOfMonth devolve a posição de d entre os dias úteis do mês (1 =
+// primeiro dia útil) ou 0 se d não é dia útil.
+func (c *Calendar) BusinessDayOfMonth(d time.Time) int {
+	if !c.IsBusinessDay(d) {
+		return 0
+	}
+	n := 0
+	for day := 1; day <= d.Day(); day++ {
+		if c.IsBusinessDay(time.Date(d.Year(), d.Month(), day, 0, 0, 0, 0, d.Location())) {
+			n++
+		}
+	}
+	return n
+}
+
+// Holidays lista os feriados efetivos do ano, em ordem de data.
+func (c *Calendar) Holidays(year int) []Holiday {
+	out := []Holiday{}
+	for d := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC); d.Year() == year; d = d.AddDate(0, 0, 1) {
+		if name, ok := c.Holiday(d); ok {
+			out = append(out, Holiday{Date: d.Format(dateLayout), Name: name})
+		}
+	}
+	return out
+}
+
+// NationalHoliday informa se d é feriado nacional brasileiro. Segue o
+// calendário de dias úteis bancário (ANBIMA): além dos feriados de lei
+// (Lei 662/49, 6.802/80, 14.759/23), Carnaval (segunda e terça) e Corpus
+// Christi — pontos facultativos em que bancos e a maioria das empresas não
+// abrem. Consciência Negra (20/11) vale a partir de 2024.
+func NationalHoliday(d time.Time) (string, bool) {
+	switch m, day := d.Month(), d.Day(); {
+	case m == time.January && day == 1:
+		return "Confraternização Universal", true
+	case m == time.April && day == 21:
+		return "Tiradentes", true
+	case m == time.May && day == 1:
+		return "Dia do Trabalho", true
+	case m == time.September && day == 7:
+		return "Independência do Brasil", true
+	case m == time.October && day == 12:
+		return "Nossa Senhora Aparecida", true
+	case m == time.November && day == 2:
+		return "Finados", true
+	case m == time.November && day == 15:
+		return "Proclamação da República", true
+	case m == time.November && day == 20 && d.Year() >= 2024:
+		return "Dia Nacional de Zumbi e da Consciência Negra", true
+	case m == time.December && day == 25:
+		return "Natal", true
+	}
+
+	easter := Easter(d.Year())
+	date := time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.UTC)
+	switch int(date.Sub(easter).Hours() / 24) {
+	case -48:
+		return "Carnaval (segunda-feira)", true
+	case -47:
+		return "Carnaval (terça-feira)", true
+	case -2:
+		return "Sexta-feira Santa", true
+	case 60:
+		return "Corpus Christi", true
+	}
+	return "", false
+}
+
+// Easter devolve o domingo de Páscoa (calendário gregoriano) à meia-noite UTC,
+// pelo algoritmo anônimo de Meeus/Jones/Butcher.
+func Easter(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,59 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+)
+
+func TestEaster(t *testing.T) {
+	for year, want := range map[int]string{
+		2024: "2024-03-31",
+		2025: "2025-04-20",
+		2026: "2026-04-05",
+		2027: "2027-03-28",
+	} {
+		if got := Easter(year).Format("2006-01-02"); got != want {
+			t.Errorf("Easter(%d) = %s; quer %s", year, got, want)
+		}
+	}
+}
+
+func TestBusinessDays(t *testing.T) {
+	cal := New(
+		models.Calendar{Name: "SP", IncludeNational: true},
+		[]models.CalendarHoliday{
+			{Date: "2000-07-09", Name: "Revolução Constitucionalista", Recurring: true},
+			{Date: "2026-06-05", Name: "Ponte de Corpus Christi"},
+		},
+	)
+	day := func(m time.Month, d int) time.Time { return time.Date(2026, m, d, 8, 0, 0, 0, time.UTC) }
+
+	cases := []struct {
+		d    time.Time
+		want bool
+	}{
+		{day(time.April, 3), false},     // Sexta-feira Santa
+		{day(time.February, 17), false}, // Carnaval (terça)
+		{day(time.June, 4), false},      // Corpus Christi
+		{day(time.June, 5), false},      // ponte cadastrada
+		{day(time.July, 9), false},      // recorrente
+		{day(time.November, 20), false},
+		{day(time.June, 8), true},
+		{day(time.June, 6), false}, // sábado
+	}
+	for _, c := range cases {
+		if got := cal.IsBusinessDay(c.d); got != c.want {
+			t.Errorf("IsBusinessDay(%s) = %v; quer %v", c.d.Format("2006-01-02"), got, c.want)
+		}
+	}
+
+	// Janeiro/2026: 1º é feriado (quinta), 2 é sexta → 1º dia útil; 5º dia útil = 8 (quinta).
+	if got := cal.BusinessDayOfMonth(day(time.January, 8)); got != 5 {
+		t.Errorf("BusinessDayOfMonth(08/01) = %d; quer 5", got)
+	}
+	if got := cal.AddBusinessDays(day(time.April, 6), -2); !got.Equal(day(time.April, 1)) {
+		t.Errorf("AddBusinessDays(06/04, -2) = %s; quer 01/04", got.Format("2006-01-02"))
+	}
+}
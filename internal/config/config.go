@@ -15,6 +15,7 @@ type Config struct {
 	JWT      JWTConfig
 	Worker   WorkerConfig
 	Log      LogConfig
+	Leader   LeaderConfig
 }
 
 type ServerConfig struct {
@@ -50,6 +51,10 @@ type LogConfig struct {
 	Level string `mapstructure:"level"`
 }
 
+type LeaderConfig struct {
+	LeaseTTL int `mapstructure:"lease_ttl"` // segundos
+}
+
 func LoadConfig(path string) (config Config, err error) {
 	viper.AddConfigPath(path)
 	viper.SetConfigName("config")
@@ -75,6 +80,7 @@ func LoadConfig(path string) (config Config, err error) {
 		"jwt.secret":            "MAESTRO_JWT_SECRET",
 		"jwt.expires_in":        "MAESTRO_JWT_EXPIRES_IN",
 		"log.level":             "MAESTRO_LOG_LEVEL",
+		"leader.lease_ttl":      "MAESTRO_LEADER_LEASE_TTL",
 	}
 
 	for key, env := range bindings {
@@ -88,6 +94,7 @@ func LoadConfig(path string) (config Config, err error) {
 	viper.SetDefault("server.port", 8000)
 	viper.SetDefault("jwt.expires_in", 24)
 	viper.SetDefault("log.level", "info")
+	viper.SetDefault("leader.lease_ttl", 30)
 
 	_ = viper.ReadInConfig()
 
@@ -0,0 +1,23 @@
+-- Lease de liderança entre réplicas do Maestro.
+--
+-- Com mais de uma réplica do backend (HA), cada processo sobe o próprio
+-- scheduler e o próprio retry worker: os crons disparam em dobro e os dois
+-- loops re-enfileiram os mesmos jobs travados. Só o dono do lease
+-- "maestro-scheduler" age; as outras réplicas ficam em standby e tomam o lease
+-- quando ele expira (líder morto para de renovar).
+--
+-- Lease em tabela (em vez de pg_advisory_lock) porque o estado fica visível pra
+-- qualquer réplica — GET /leader mostra quem é o líder mesmo respondendo de um
+-- standby — e não depende de segurar uma conexão dedicada do pool.
+--
+-- ⚠ AMBIENTES EXISTENTES — aplicar manualmente (init-db só roda com volume vazio):
+--
+--   docker exec -i maestro_postgres psql -U user -d maestro_db < internal/database/init-db/000010_add_leader_leases.up.sql
+
+CREATE TABLE IF NOT EXISTS leader_leases (
+    name VARCHAR(100) PRIMARY KEY,
+    holder VARCHAR(255) NOT NULL,
+    acquired_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    renewed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,21 @@
+-- Política de misfire por agendamento: o que fazer com disparos que deveriam
+-- ter acontecido enquanto o backend estava fora do ar (deploy, queda).
+--
+--   skip      → ignora os disparos perdidos (comportamento anterior).
+--   run_once  → enfileira só o disparo perdido mais recente.
+--   run_all   → enfileira todos os disparos perdidos, em ordem.
+--
+-- misfire_max_lookback_minutes limita quão para trás a recuperação olha —
+-- disparos mais antigos que isso são descartados mesmo em run_all.
+--
+-- ⚠ AMBIENTES EXISTENTES — aplicar manualmente (init-db só roda com volume vazio).
+
+ALTER TABLE schedules
+    ADD COLUMN IF NOT EXISTS misfire_policy VARCHAR(20) NOT NULL DEFAULT 'skip'
+        CHECK (misfire_policy IN ('skip', 'run_once', 'run_all')),
+    ADD COLUMN IF NOT EXISTS misfire_max_lookback_minutes INT NOT NULL DEFAULT 1440
+        CHECK (misfire_max_lookback_minutes > 0);
+
+-- next_run_at de agendamento desabilitado não significa nada; zerá-lo evita
+-- que reabilitar um agendamento antigo seja confundido com misfire.
+UPDATE schedules SET next_run_at = NULL WHERE is_enabled = FALSE;
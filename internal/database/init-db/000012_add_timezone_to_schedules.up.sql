@@ -0,0 +1,13 @@
+-- Fuso horário por agendamento (nome IANA, ex.: 'America/Manaus', 'Europe/Lisbon').
+-- A expressão cron, o {{prev_run}} e os demais placeholders de data do
+-- agendamento passam a ser interpretados nesse fuso.
+--
+-- NULL → usa o fuso padrão do processo (SCHEDULER_TZ > TZ > America/Sao_Paulo),
+-- que é o comportamento anterior — agendamentos existentes não mudam.
+--
+-- A validação contra o tz database é feita na API (time.LoadLocation).
+--
+-- ⚠ AMBIENTES EXISTENTES — aplicar manualmente (init-db só roda com volume vazio).
+
+ALTER TABLE schedules
+    ADD COLUMN IF NOT EXISTS timezone VARCHAR(64);
@@ -0,0 +1,46 @@
+-- Política de sobreposição por agendamento: o que fazer num tick do cron
+-- quando o job do disparo anterior ainda está pending/running.
+--
+--   allow            → cria o job mesmo assim (comportamento anterior).
+--   skip             → não cria job; registra o tick pulado em schedule_events.
+--   queue_after      → cria o job retido (after_job_id) e só o publica quando
+--                      o anterior terminar. No máximo um disparo aguardando.
+--   cancel_previous  → pede cancelamento do(s) anterior(es) e cria o novo,
+--                      retido até o anterior realmente parar.
+--
+-- ⚠ AMBIENTES EXISTENTES — aplicar manualmente (init-db só roda com volume vazio).
+
+ALTER TABLE schedules
+    ADD COLUMN IF NOT EXISTS overlap_policy VARCHAR(20) NOT NULL DEFAULT 'allow'
+        CHECK (overlap_policy IN ('allow', 'skip', 'queue_after', 'cancel_previous'));
+
+-- Vínculo job → agendamento que o criou (NULL = manual/retry). Necessário pra
+-- achar o "job do disparo anterior".
+ALTER TABLE jobs
+    ADD COLUMN IF NOT EXISTS schedule_id INT REFERENCES schedules(id) ON DELETE SET NULL;
+
+-- Job retido até outro terminar (queue_after / cancel_previous). Enquanto
+-- after_job_id não é NULL o job está pending mas NÃO foi publicado na fila;
+-- o scheduler zera o campo e publica quando o job referenciado finaliza.
+ALTER TABLE jobs
+    ADD COLUMN IF NOT EXISTS after_job_id UUID REFERENCES jobs(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_schedule_id_status ON jobs(schedule_id, status)
+    WHERE schedule_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_jobs_after_job_id ON jobs(after_job_id)
+    WHERE after_job_id IS NOT NULL;
+
+-- Histórico de decisões do scheduler que NÃO viram (ou não viram logo) um job:
+-- tick pulado, disparo enfileirado atrás do anterior, anterior cancelado.
+-- É o que o operador consulta pra entender "por que não rodou às 08:00?".
+CREATE TABLE IF NOT EXISTS schedule_events (
+    id BIGSERIAL PRIMARY KEY,
+    schedule_id INT NOT NULL REFERENCES schedules(id) ON DELETE CASCADE,
+    scheduled_for TIMESTAMPTZ NOT NULL,
+    kind VARCHAR(30) NOT NULL,
+    reason TEXT NOT NULL,
+    job_id UUID REFERENCES jobs(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedule_events_schedule_id ON schedule_events(schedule_id, created_at DESC);
@@ -0,0 +1,42 @@
+-- Calendários de feriados pra agendamentos por dia útil.
+--
+-- include_national soma os feriados nacionais brasileiros, calculados no
+-- backend pra qualquer ano (inclusive Carnaval, Sexta-feira Santa e Corpus
+-- Christi, que dependem da Páscoa) — por isso não ficam em calendar_holidays.
+-- calendar_holidays guarda o resto: feriados estaduais/municipais, recessos,
+-- pontes. recurring = TRUE vale todo ano no mesmo dia/mês.
+--
+-- ⚠ AMBIENTES EXISTENTES — aplicar manualmente (init-db só roda com volume vazio).
+
+CREATE TABLE IF NOT EXISTS calendars (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) UNIQUE NOT NULL,
+    description TEXT,
+    include_national BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS calendar_holidays (
+    id SERIAL PRIMARY KEY,
+    calendar_id INT NOT NULL REFERENCES calendars(id) ON DELETE CASCADE,
+    date DATE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    recurring BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (calendar_id, date)
+);
+
+INSERT INTO calendars (name, description, include_national)
+VALUES ('BR-NACIONAL', 'Feriados nacionais brasileiros (calendário bancário)', TRUE)
+ON CONFLICT (name) DO NOTHING;
+
+-- holiday_policy: o que fazer com disparo em dia não útil (fim de semana ou
+-- feriado do calendário). Sem calendar_id, só fim de semana conta.
+--   ignore            → dispara normalmente (comportamento anterior).
+--   skip              → não dispara.
+--   next_business_day → adia pro próximo dia útil, mesmo horário.
+ALTER TABLE schedules
+    ADD COLUMN IF NOT EXISTS calendar_id INT REFERENCES calendars(id) ON DELETE RESTRICT,
+    ADD COLUMN IF NOT EXISTS holiday_policy VARCHAR(30) NOT NULL DEFAULT 'ignore'
+        CHECK (holiday_policy IN ('ignore', 'skip', 'next_business_day'));
@@ -0,0 +1,23 @@
+-- Origem do job: quem o criou. Antes o único sinal era user_id NULL (= agendado),
+-- heurística que o dashboard usava pra separar manual×agendado.
+--
+--   manual    → usuário clicou Executar (POST /automations/:id/execute).
+--   schedule  → disparo de agendamento (schedule_id aponta o agendamento).
+--   retry     → "Tentar novamente" num job finalizado (POST /jobs/:id/retry).
+--   api       → execução via API sem usuário autenticado associado.
+--
+-- Backfill: schedule_id preenchido ou user_id NULL → schedule; resto → manual.
+-- Retries antigos não são distinguíveis e ficam como manual.
+--
+-- ⚠ AMBIENTES EXISTENTES — aplicar manualmente (init-db só roda com volume vazio).
+
+ALTER TABLE jobs
+    ADD COLUMN IF NOT EXISTS trigger VARCHAR(20) NOT NULL DEFAULT 'manual'
+        CHECK (trigger IN ('manual', 'schedule', 'retry', 'api'));
+
+UPDATE jobs SET trigger = 'schedule'
+WHERE trigger = 'manual' AND (schedule_id IS NOT NULL OR user_id IS NULL);
+
+-- Histórico por agendamento (GET /schedules/:id/runs) ordena por completed_at.
+CREATE INDEX IF NOT EXISTS idx_jobs_schedule_id_completed_at ON jobs(schedule_id, completed_at DESC)
+    WHERE schedule_id IS NOT NULL;
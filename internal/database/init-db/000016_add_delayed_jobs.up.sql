@@ -0,0 +1,20 @@
+-- Execuções únicas agendadas ("rodar hoje às 02:00"): POST /automations/:id/execute
+-- com runAt cria o job em status 'scheduled', com run_at preenchido e SEM
+-- mensagem na fila. O dispatcher do scheduler (só na réplica líder) move pra
+-- 'pending' e publica quando run_at chega. Como vive no Postgres, sobrevive a
+-- restart: o que venceu com o backend fora do ar é publicado no primeiro tick.
+--
+-- Enquanto 'scheduled', o job pode ser cancelado (vira 'canceled' direto) ou
+-- reagendado (POST /jobs/:id/reschedule).
+--
+-- ⚠ AMBIENTES EXISTENTES — aplicar manualmente (init-db só roda com volume vazio).
+
+ALTER TABLE jobs DROP CONSTRAINT IF EXISTS jobs_status_check;
+ALTER TABLE jobs ADD CONSTRAINT jobs_status_check
+    CHECK (status IN ('scheduled', 'pending', 'running', 'completed', 'completed_no_invoices', 'failed', 'canceled'));
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS run_at TIMESTAMPTZ;
+
+-- Varredura do dispatcher: só os scheduled, por ordem de vencimento.
+CREATE INDEX IF NOT EXISTS idx_jobs_scheduled_run_at ON jobs(run_at)
+    WHERE status = 'scheduled';
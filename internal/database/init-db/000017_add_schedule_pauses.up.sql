@@ -0,0 +1,37 @@
+-- Pausas de agendamento, pra manutenção da SEFAZ, fechamento de mês etc.,
+-- sem precisar deletar o agendamento ou desligar is_enabled na mão.
+--
+--   schedules.paused_until → pausa pontual: nenhum disparo antes desse
+--                            instante. Passou dele, o agendamento volta sozinho.
+--   blackout_windows       → janelas recorrentes: começam a cada disparo de
+--                            cron_expression e duram duration_minutes. Valem
+--                            pra UM agendamento (schedule_id) ou pra todos os
+--                            agendamentos de uma automação (automation_id).
+--
+-- Disparo suprimido não cria job e fica registrado em schedule_events
+-- (kind 'paused' ou 'blackout').
+--
+-- ⚠ AMBIENTES EXISTENTES — aplicar manualmente (init-db só roda com volume vazio).
+
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS paused_until TIMESTAMPTZ;
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS pause_reason TEXT;
+
+CREATE TABLE IF NOT EXISTS blackout_windows (
+    id SERIAL PRIMARY KEY,
+    schedule_id INT REFERENCES schedules(id) ON DELETE CASCADE,
+    automation_id INT REFERENCES automations(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    cron_expression VARCHAR(100) NOT NULL,
+    duration_minutes INT NOT NULL CHECK (duration_minutes BETWEEN 1 AND 44640),
+    timezone VARCHAR(64),
+    is_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- Exatamente um dos dois alvos.
+    CHECK ((schedule_id IS NULL) <> (automation_id IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_blackout_windows_schedule_id ON blackout_windows(schedule_id)
+    WHERE schedule_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_blackout_windows_automation_id ON blackout_windows(automation_id)
+    WHERE automation_id IS NOT NULL;
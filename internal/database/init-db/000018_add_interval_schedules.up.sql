@@ -0,0 +1,27 @@
+-- Agendamentos por intervalo fixo, pra portais que precisam ser consultados
+-- "a cada 90 minutos a partir das 07:15" — cron não expressa isso (90 não
+-- divide a hora, e o */N do cron recomeça a cada hora/dia).
+--
+--   kind             → 'cron' (padrão, usa cron_expression) ou 'interval'.
+--   interval_anchor  → primeiro disparo; os seguintes caem em
+--                      anchor + k * interval_minutes.
+--   interval_minutes → tamanho do passo.
+--   active_from/to   → janela diária opcional ("HH:MM", no fuso do
+--                      agendamento) fora da qual os disparos são pulados;
+--                      to < from atravessa a meia-noite.
+--
+-- Agendamento de intervalo grava cron_expression = '' (a coluna é NOT NULL).
+--
+-- ⚠ AMBIENTES EXISTENTES — aplicar manualmente (init-db só roda com volume vazio).
+
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS kind VARCHAR(10) NOT NULL DEFAULT 'cron'
+    CHECK (kind IN ('cron', 'interval'));
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS interval_anchor TIMESTAMPTZ;
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS interval_minutes INT
+    CHECK (interval_minutes BETWEEN 1 AND 44640);
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS active_from VARCHAR(5);
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS active_to VARCHAR(5);
+
+ALTER TABLE schedules DROP CONSTRAINT IF EXISTS schedules_interval_check;
+ALTER TABLE schedules ADD CONSTRAINT schedules_interval_check
+    CHECK (kind <> 'interval' OR (interval_anchor IS NOT NULL AND interval_minutes IS NOT NULL));
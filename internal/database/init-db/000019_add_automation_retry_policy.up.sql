@@ -0,0 +1,15 @@
+-- Política de retry por automação (models.RetryPolicy), em JSON:
+--
+--   { "maxAttempts": 4, "initialDelaySeconds": 60, "maxDelaySeconds": 3600,
+--     "multiplier": 2, "jitter": 0.2,
+--     "retryableErrorClasses": ["RATE_LIMITED", "PORTAL_DOWN"] }
+--
+-- NULL = sem política: job que termina em failed não volta sozinho e job
+-- travado é re-enfileirado até 3 vezes (o comportamento anterior).
+--
+-- Retry de falha reaproveita o job: volta pra 'scheduled' com run_at no fim do
+-- backoff e retry_count + 1 (= número da tentativa - 1).
+--
+-- ⚠ AMBIENTES EXISTENTES — aplicar manualmente (init-db só roda com volume vazio).
+
+ALTER TABLE automations ADD COLUMN IF NOT EXISTS retry_policy JSONB;
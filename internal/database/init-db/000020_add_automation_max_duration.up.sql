@@ -0,0 +1,11 @@
+-- Tempo máximo de execução por automação, em minutos. NULL = sem limite.
+--
+-- O retry worker (réplica líder) pede cancelamento do job running que passou
+-- do limite (contado a partir de started_at). Se o worker não finalizar em
+-- até 5 minutos depois do pedido, o job é marcado failed com
+-- result.error_class = 'JOB_TIMEOUT'.
+--
+-- ⚠ AMBIENTES EXISTENTES — aplicar manualmente (init-db só roda com volume vazio).
+
+ALTER TABLE automations ADD COLUMN IF NOT EXISTS max_duration_minutes INT
+    CHECK (max_duration_minutes IS NULL OR max_duration_minutes BETWEEN 1 AND 10080);
@@ -0,0 +1,25 @@
+-- Limites de concorrência controlados pelo Maestro.
+--
+--   automations.max_concurrency      — no máximo N jobs da automação em voo.
+--   automations.resource_key         — chave de recurso montada dos parâmetros,
+--                                      ex.: 'cert:{{cnpj}}'. Jobs com a mesma
+--                                      chave (de qualquer automação) disputam
+--                                      o mesmo recurso.
+--   automations.resource_concurrency — jobs em voo por chave (NULL = 1).
+--
+-- Job de automação com limite nasce retido (jobs.held) em pending, sem
+-- mensagem na fila; o dispatcher da réplica líder publica por ordem de
+-- criação enquanto houver vaga e grava a chave resolvida em
+-- jobs.resource_key. "Em voo" = running ou pending já publicado.
+--
+-- ⚠ AMBIENTES EXISTENTES — aplicar manualmente (init-db só roda com volume vazio).
+
+ALTER TABLE automations ADD COLUMN IF NOT EXISTS max_concurrency INT CHECK (max_concurrency IS NULL OR max_concurrency >= 1);
+ALTER TABLE automations ADD COLUMN IF NOT EXISTS resource_key VARCHAR(255);
+ALTER TABLE automations ADD COLUMN IF NOT EXISTS resource_concurrency INT CHECK (resource_concurrency IS NULL OR resource_concurrency >= 1);
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS held BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS resource_key VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_held ON jobs (created_at) WHERE held AND status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_jobs_resource_key ON jobs (resource_key) WHERE resource_key IS NOT NULL AND status IN ('pending', 'running');
@@ -0,0 +1,19 @@
+-- Limite de taxa por automação (token bucket), em JSON:
+--
+--   { "jobs": 30, "periodSeconds": 3600 }   -- até 30 jobs por hora
+--
+-- O balde enche a jobs/periodSeconds fichas por segundo até `jobs` (rajada
+-- máxima). Cada job publicado consome uma ficha; sem ficha, o job fica retido
+-- em pending (jobs.held, ver 000021) e o dispatcher da réplica líder o publica
+-- quando o balde repõe. O estado do balde fica em rate_limit_buckets pra
+-- sobreviver a restart e troca de líder.
+--
+-- ⚠ AMBIENTES EXISTENTES — aplicar manualmente (init-db só roda com volume vazio).
+
+ALTER TABLE automations ADD COLUMN IF NOT EXISTS rate_limit JSONB;
+
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    automation_id INT PRIMARY KEY REFERENCES automations(id) ON DELETE CASCADE,
+    tokens DOUBLE PRECISION NOT NULL,
+    refilled_at TIMESTAMPTZ NOT NULL
+);
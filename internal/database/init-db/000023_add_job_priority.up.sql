@@ -0,0 +1,21 @@
+-- Prioridade de job (0–10, maior sai primeiro), publicada na mensagem AMQP.
+--
+-- Definida na criação do job: automations.priorities[trigger] se existir,
+-- senão o padrão do trigger (manual 8, api 6, schedule 4, retry 2 — ver
+-- models.DefaultPriorities). Retry automático (retryPolicy) cai pra prioridade
+-- de retry. Jobs antigos ficam com 0.
+--
+--   automations.priorities = { "manual": 9, "schedule": 3 }
+--
+-- As filas passam a ser declaradas com x-max-priority = 10. Fila que já existe
+-- sem esse argumento continua recebendo (sem prioridade) até ser recriada —
+-- ver docs/automations.md, seção 4.
+--
+-- ⚠ AMBIENTES EXISTENTES — aplicar manualmente (init-db só roda com volume vazio).
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS priority SMALLINT NOT NULL DEFAULT 0
+    CHECK (priority BETWEEN 0 AND 10);
+ALTER TABLE automations ADD COLUMN IF NOT EXISTS priorities JSONB;
+
+DROP INDEX IF EXISTS idx_jobs_held;
+CREATE INDEX IF NOT EXISTS idx_jobs_held ON jobs (priority DESC, created_at) WHERE held AND status = 'pending';
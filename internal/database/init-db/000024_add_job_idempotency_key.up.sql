@@ -0,0 +1,11 @@
+-- Idempotency-Key dos endpoints de execução (POST /automations/:id/execute e
+-- POST /jobs/:id/retry). A chave fica no job criado; a mesma chave dentro do
+-- TTL (24h) devolve esse job em vez de criar outro. O índice único é o que
+-- garante isso sob requisições concorrentes (INSERT ... ON CONFLICT). Passado
+-- o TTL, a chave é liberada (zerada no job antigo) antes do novo INSERT.
+--
+-- ⚠ AMBIENTES EXISTENTES — aplicar manualmente (init-db só roda com volume vazio).
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(255);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_idempotency_key ON jobs (idempotency_key);
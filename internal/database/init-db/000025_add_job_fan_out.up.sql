@@ -0,0 +1,29 @@
+-- Fan-out: uma execução sobre uma lista (lojas, cnpjs) vira um job pai e um
+-- job filho por item (POST /automations/:id/execute?fanOut=<param> ou
+-- schedules.fan_out_param). Só os filhos vão pra fila; o pai nasce 'running'
+-- e o status dele é agregado da última tentativa de cada item:
+--   completed → todos com sucesso;  partial → sucesso e falha/cancelamento;
+--   failed    → nenhum sucesso;     canceled → todos cancelados.
+--
+-- jobs.fan_out_param (no pai) guarda o nome da lista e marca o job como pai;
+-- jobs.fan_out_item (no filho) identifica o item entre as retentativas.
+--
+-- ⚠ AMBIENTES EXISTENTES — aplicar manualmente (init-db só roda com volume vazio).
+
+ALTER TABLE jobs DROP CONSTRAINT IF EXISTS jobs_status_check;
+ALTER TABLE jobs ADD CONSTRAINT jobs_status_check
+    CHECK (status IN ('scheduled', 'pending', 'running', 'completed', 'completed_no_invoices', 'partial', 'failed', 'canceled'));
+
+ALTER TABLE jobs
+    ADD COLUMN IF NOT EXISTS parent_job_id UUID REFERENCES jobs(id) ON DELETE CASCADE,
+    ADD COLUMN IF NOT EXISTS fan_out_param VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS fan_out_item VARCHAR(255);
+
+-- Agregação: última tentativa de cada item do pai.
+CREATE INDEX IF NOT EXISTS idx_jobs_parent ON jobs(parent_job_id, fan_out_item, created_at DESC)
+    WHERE parent_job_id IS NOT NULL;
+-- Varredura dos pais em aberto.
+CREATE INDEX IF NOT EXISTS idx_jobs_open_parents ON jobs(id)
+    WHERE fan_out_param IS NOT NULL AND status = 'running';
+
+ALTER TABLE schedules ADD COLUMN IF NOT EXISTS fan_out_param VARCHAR(255);
@@ -0,0 +1,41 @@
+-- Pipelines: DAG de automações ("baixar XMLs → importar no GMS → mandar o
+-- relatório"). steps é a lista de passos (automação, parâmetros e inputs
+-- mapeados do result de um passo anterior); edges liga os passos com
+-- on_success / on_failure / always. A validação do DAG fica na API.
+--
+-- Cada execução é uma linha em pipeline_runs (running | completed | failed |
+-- canceled) e cada passo executado é um job normal com pipeline_run_id e
+-- pipeline_step. O scheduler da réplica líder avança as execuções abertas.
+--
+-- ⚠ AMBIENTES EXISTENTES — aplicar manualmente (init-db só roda com volume vazio).
+
+CREATE TABLE IF NOT EXISTS pipelines (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    description TEXT,
+    steps JSONB NOT NULL,
+    edges JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS pipeline_runs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    pipeline_id INT NOT NULL REFERENCES pipelines(id) ON DELETE CASCADE,
+    user_id INT REFERENCES users(id) ON DELETE SET NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'running'
+        CHECK (status IN ('running', 'completed', 'failed', 'canceled')),
+    parameters JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_pipeline_runs_pipeline ON pipeline_runs(pipeline_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_pipeline_runs_running ON pipeline_runs(id) WHERE status = 'running';
+
+ALTER TABLE jobs
+    ADD COLUMN IF NOT EXISTS pipeline_run_id UUID REFERENCES pipeline_runs(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS pipeline_step VARCHAR(100);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_pipeline_run ON jobs(pipeline_run_id, created_at)
+    WHERE pipeline_run_id IS NOT NULL;
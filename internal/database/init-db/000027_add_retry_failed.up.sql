@@ -0,0 +1,14 @@
+-- Retry só dos itens que falharam: automations.retry_failed declara como o
+-- job novo é montado a partir de result.summary.failed[] do original
+--
+--   automations.retry_failed = { "param": "empresas", "itemField": "empresa" }
+--
+-- e jobs.retry_of liga a reexecução ao job de origem.
+--
+-- ⚠ AMBIENTES EXISTENTES — aplicar manualmente (init-db só roda com volume vazio).
+
+ALTER TABLE automations ADD COLUMN IF NOT EXISTS retry_failed JSONB;
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS retry_of UUID REFERENCES jobs(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_retry_of ON jobs(retry_of) WHERE retry_of IS NOT NULL;
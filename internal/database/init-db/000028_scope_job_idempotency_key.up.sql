@@ -0,0 +1,13 @@
+-- Idempotency-Key por usuário: com o índice único só na chave, dois usuários
+-- (ou clientes de API) que mandassem a mesma chave recebiam o job um do
+-- outro. A chave passa a valer por (user_id, idempotency_key); chamadas sem
+-- usuário (user_id NULL) dividem um escopo só (NULLS NOT DISTINCT, Postgres
+-- 15+). O WHERE deixa de fora os jobs sem chave.
+--
+-- ⚠ AMBIENTES EXISTENTES — aplicar manualmente (init-db só roda com volume vazio).
+
+DROP INDEX IF EXISTS idx_jobs_idempotency_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_user_idempotency_key
+    ON jobs (user_id, idempotency_key) NULLS NOT DISTINCT
+    WHERE idempotency_key IS NOT NULL;
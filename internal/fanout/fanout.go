@@ -0,0 +1,87 @@
+// Package fanout divide uma execução em jobs filhos, um por item de um
+// parâmetro lista ("lojas", "cnpjs"). O job pai não vai pra fila: o status
+// dele é agregado dos filhos (ver JobRepository.RefreshParent).
+package fanout
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MaxItems limita quantos filhos uma execução cria.
+const MaxItems = 500
+
+// maxKeyLen acompanha o VARCHAR(255) de jobs.fan_out_item.
+const maxKeyLen = 255
+
+// Item é um filho: Key identifica o item entre os irmãos (jobs.fan_out_item,
+// estável entre retentativas) e Params são os parâmetros do pai com a lista
+// reduzida a esse item.
+type Item struct {
+	Key    string
+	Params map[string]interface{}
+}
+
+// Split devolve um Item por elemento de params[param]. No filho o parâmetro
+// continua lista — com um elemento só —, então o worker recebe o mesmo formato
+// de sempre. Lista ausente, vazia, grande demais ou com item repetido é erro.
+func Split(params map[string]interface{}, param string) ([]Item, error) {
+	raw, ok := params[param]
+	if !ok || raw == nil {
+		return nil, fmt.Errorf("parâmetro %q não informado", param)
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("parâmetro %q deve ser uma lista", param)
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("lista %q está vazia", param)
+	}
+	if len(list) > MaxItems {
+		return nil, fmt.Errorf("lista %q tem %d itens (máximo %d)", param, len(list), MaxItems)
+	}
+
+	items := make([]Item, 0, len(list))
+	seen := make(map[string]bool, len(list))
+	for _, v := range list {
+		key, err := itemKey(v)
+		if err != nil {
+			return nil, fmt.Errorf("item inválido em %q: %w", param, err)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("item %q repetido em %q", key, param)
+		}
+		seen[key] = true
+
+		child := make(map[string]interface{}, len(params))
+		for k, pv := range params {
+			child[k] = pv
+		}
+		child[param] = []interface{}{v}
+		items = append(items, Item{Key: key, Params: child})
+	}
+	return items, nil
+}
+
+// itemKey é o texto do item: strings como vieram, o resto em JSON (4814, não
+// 4814.0 nem 4.814e+03).
+func itemKey(v interface{}) (string, error) {
+	if v == nil {
+		return "", fmt.Errorf("item nulo")
+	}
+	key, ok := v.(string)
+	if !ok {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		key = string(b)
+	}
+	if key == "" {
+		return "", fmt.Errorf("item vazio")
+	}
+	if len(key) > maxKeyLen {
+		return "", fmt.Errorf("item com mais de %d caracteres", maxKeyLen)
+	}
+	return key, nil
+}
@@ -0,0 +1,47 @@
+package fanout
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	var params map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"lojas":[4814,"6861"],"dataInicio":"01/05/2026"}`), &params); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := Split(params, "lojas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("esperava 2 filhos, veio %d", len(items))
+	}
+	if items[0].Key != "4814" || items[1].Key != "6861" {
+		t.Errorf("chaves: %q, %q", items[0].Key, items[1].Key)
+	}
+	want := map[string]interface{}{"lojas": []interface{}{float64(4814)}, "dataInicio": "01/05/2026"}
+	if !reflect.DeepEqual(items[0].Params, want) {
+		t.Errorf("parâmetros do filho: %v", items[0].Params)
+	}
+	if len(params["lojas"].([]interface{})) != 2 {
+		t.Error("Split não pode alterar os parâmetros do pai")
+	}
+}
+
+func TestSplitInvalid(t *testing.T) {
+	cases := map[string]map[string]interface{}{
+		"ausente":   {"cnpjs": []interface{}{"1"}},
+		"não lista": {"lojas": "4814"},
+		"vazia":     {"lojas": []interface{}{}},
+		"repetido":  {"lojas": []interface{}{"4814", "4814"}},
+		"nulo":      {"lojas": []interface{}{nil}},
+	}
+	for name, params := range cases {
+		if _, err := Split(params, "lojas"); err == nil {
+			t.Errorf("%s: esperava erro", name)
+		}
+	}
+}
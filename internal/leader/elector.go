@@ -0,0 +1,180 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+	"github.com/EnzzoHosaki/rps-maestro/internal/repository"
+	"github.com/rs/zerolog/log"
+)
+
+// LeaseName é o lease disputado pelas réplicas. Um só lease cobre scheduler e
+// retry worker: os dois leem/escrevem as mesmas linhas de jobs, então é mais
+// simples (e sem risco de split) ter um único líder pros dois.
+const LeaseName = "maestro-scheduler"
+
+// Elector disputa o lease de liderança no Postgres e mantém em memória se esta
+// réplica é a líder.
+//
+// O líder renova o lease a cada ttl/3; os standbys tentam tomar na mesma
+// cadência. Se o líder morre, o lease dele expira em no máximo `ttl` e o
+// primeiro standby a tentar depois disso assume — takeover em até ttl + ttl/3.
+//
+// IsLeader também expira sozinho: a liderança local vale até o instante em que
+// o último lease renovado com sucesso expiraria. Assim, se o Postgres ficar
+// inacessível, a réplica para de agir por conta própria em vez de continuar
+// disparando enquanto outra réplica (que alcança o banco) já assumiu.
+type Elector struct {
+	repo       repository.LeaderLeaseRepository
+	name       string
+	holder     string
+	ttl        time.Duration
+	renewEvery time.Duration
+
+	mu         sync.RWMutex
+	leader     bool
+	validUntil time.Time
+	since      time.Time
+	onElected  []func(ctx context.Context)
+}
+
+func New(repo repository.LeaderLeaseRepository, ttl time.Duration) *Elector {
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	return &Elector{
+		repo:       repo,
+		name:       LeaseName,
+		holder:     holderID(),
+		ttl:        ttl,
+		renewEvery: ttl / 3,
+	}
+}
+
+// holderID identifica a réplica no lease: hostname (= ID do container no
+// docker) + PID, pra duas instâncias no mesmo host não se confundirem.
+func holderID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "maestro"
+	}
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
+
+// OnElected registra um callback chamado (em goroutine) toda vez que esta
+// réplica passa de standby a líder. Registrar antes de Run.
+func (e *Elector) OnElected(fn func(ctx context.Context)) {
+	e.mu.Lock()
+	e.onElected = append(e.onElected, fn)
+	e.mu.Unlock()
+}
+
+// Holder devolve o identificador desta réplica no lease.
+func (e *Elector) Holder() string {
+	return e.holder
+}
+
+// IsLeader informa se esta réplica detém um lease ainda válido.
+func (e *Elector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leader && time.Now().Before(e.validUntil)
+}
+
+// LeaderSince devolve desde quando esta réplica é líder (zero se não for).
+func (e *Elector) LeaderSince() time.Time {
+	if !e.IsLeader() {
+		return time.Time{}
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.since
+}
+
+// Lease devolve o lease atual como está no banco — quem é o líder visto por
+// qualquer réplica.
+func (e *Elector) Lease(ctx context.Context) (*models.LeaderLease, error) {
+	return e.repo.Get(ctx, e.name)
+}
+
+// Run disputa o lease até o ctx ser cancelado. Bloqueante — chamar em goroutine.
+// No shutdown libera o lease (se for o dono) pra um standby assumir na hora.
+func (e *Elector) Run(ctx context.Context) {
+	log.Info().
+		Str("holder", e.holder).
+		Dur("ttl", e.ttl).
+		Msg("[leader] disputando liderança")
+
+	e.tryAcquire(ctx)
+
+	ticker := time.NewTicker(e.renewEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			e.release()
+			return
+		case <-ticker.C:
+			e.tryAcquire(ctx)
+		}
+	}
+}
+
+func (e *Elector) tryAcquire(ctx context.Context) {
+	// validUntil é medido a partir de ANTES da chamada: o lease no banco
+	// expira em NOW()+ttl do Postgres, que é no mínimo este instante + ttl.
+	start := time.Now()
+	ok, err := e.repo.TryAcquire(ctx, e.name, e.holder, e.ttl)
+	if err != nil {
+		if ctx.Err() == nil {
+			// Mantém o estado: se era líder, IsLeader expira sozinho em validUntil.
+			log.Error().Err(err).Msg("[leader] erro ao disputar lease")
+		}
+		return
+	}
+
+	e.mu.Lock()
+	wasLeader := e.leader && start.Before(e.validUntil)
+	e.leader = ok
+	if ok {
+		e.validUntil = start.Add(e.ttl)
+		if !wasLeader {
+			e.since = time.Now()
+		}
+	}
+	callbacks := e.onElected
+	e.mu.Unlock()
+
+	switch {
+	case ok && !wasLeader:
+		log.Info().Str("holder", e.holder).Msg("[leader] esta réplica assumiu a liderança")
+		for _, fn := range callbacks {
+			go fn(ctx)
+		}
+	case !ok && wasLeader:
+		log.Warn().Str("holder", e.holder).Msg("[leader] liderança perdida — réplica em standby")
+	}
+}
+
+func (e *Elector) release() {
+	e.mu.Lock()
+	wasLeader := e.leader
+	e.leader = false
+	e.mu.Unlock()
+	if !wasLeader {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := e.repo.Release(ctx, e.name, e.holder); err != nil {
+		log.Error().Err(err).Msg("[leader] erro ao liberar lease no shutdown")
+		return
+	}
+	log.Info().Str("holder", e.holder).Msg("[leader] lease liberado")
+}
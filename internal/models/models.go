@@ -27,8 +27,70 @@ type Automation struct {
 	QueueName       string          `db:"queue_name" json:"queueName"`
 	DefaultParams   json.RawMessage `db:"default_params" json:"defaultParams,omitempty"`
 	ParameterSchema json.RawMessage `db:"parameter_schema" json:"parameterSchema,omitempty"`
-	CreatedAt       time.Time       `db:"created_at" json:"createdAt"`
-	UpdatedAt       time.Time       `db:"updated_at" json:"updatedAt"`
+	RetryPolicy     *RetryPolicy    `db:"retry_policy" json:"retryPolicy,omitempty"`
+	// MaxDurationMinutes limita quanto um job pode ficar em running; passou,
+	// o retry worker pede cancelamento e depois força failed (JOB_TIMEOUT).
+	MaxDurationMinutes *int `db:"max_duration_minutes" json:"maxDurationMinutes,omitempty"`
+	// Limites de concorrência aplicados pelo dispatcher do Maestro: até
+	// MaxConcurrency jobs da automação em voo e até ResourceConcurrency
+	// (default 1) por ResourceKey — modelo com {{parametro}}, ex.:
+	// "cert:{{cnpj}}", compartilhado entre automações com a mesma chave.
+	MaxConcurrency      *int       `db:"max_concurrency" json:"maxConcurrency,omitempty"`
+	ResourceKey         *string    `db:"resource_key" json:"resourceKey,omitempty"`
+	ResourceConcurrency *int       `db:"resource_concurrency" json:"resourceConcurrency,omitempty"`
+	RateLimit           *RateLimit `db:"rate_limit" json:"rateLimit,omitempty"`
+	// Priorities sobrescreve, por trigger, a prioridade padrão dos jobs da
+	// automação (DefaultPriorities), ex.: {"manual": 9, "schedule": 3}.
+	Priorities map[string]int `db:"priorities" json:"priorities,omitempty"`
+	// RetryFailed habilita o POST /jobs/:id/retry-failed (ver
+	// RetryFailedMapping).
+	RetryFailed *RetryFailedMapping `db:"retry_failed" json:"retryFailed,omitempty"`
+	CreatedAt   time.Time           `db:"created_at" json:"createdAt"`
+	UpdatedAt   time.Time           `db:"updated_at" json:"updatedAt"`
+}
+
+// HasDispatchLimit diz se os jobs da automação passam pelo dispatcher do
+// Maestro (nascem retidos) em vez de irem direto pra fila: limite de
+// concorrência ou de taxa.
+func (a *Automation) HasDispatchLimit() bool {
+	return a.MaxConcurrency != nil || (a.ResourceKey != nil && *a.ResourceKey != "") || a.RateLimit != nil
+}
+
+// RetryFailedMapping diz como refazer só os itens que falharam num job: os
+// valores de ItemField (padrão "empresa") em result.summary.failed[] viram a
+// lista do parâmetro Param no job novo; os demais parâmetros são copiados.
+type RetryFailedMapping struct {
+	Param     string `json:"param"`
+	ItemField string `json:"itemField,omitempty"`
+}
+
+// RateLimit é o token bucket da automação: até Jobs publicações por
+// PeriodSeconds, com rajada de no máximo Jobs (ver pacote ratelimit).
+type RateLimit struct {
+	Jobs          int `json:"jobs"`
+	PeriodSeconds int `json:"periodSeconds"`
+}
+
+// RateBucket é o estado persistido do token bucket de uma automação.
+type RateBucket struct {
+	AutomationID int       `db:"automation_id"`
+	Tokens       float64   `db:"tokens"`
+	RefilledAt   time.Time `db:"refilled_at"`
+}
+
+// RetryPolicy decide se um job da automação que terminou em failed volta pra
+// fila sozinho (ver retry.Decide). MaxAttempts conta todas as execuções,
+// inclusive a primeira — e também limita os re-enfileiramentos de job travado.
+// A espera antes da tentativa n+1 é InitialDelaySeconds * Multiplier^(n-1),
+// limitada a MaxDelaySeconds, com ±Jitter (fração, 0–1) aleatório. Só falhas
+// com result.error_class em RetryableErrorClasses são retentadas.
+type RetryPolicy struct {
+	MaxAttempts           int      `json:"maxAttempts"`
+	InitialDelaySeconds   int      `json:"initialDelaySeconds"`
+	MaxDelaySeconds       int      `json:"maxDelaySeconds"`
+	Multiplier            float64  `json:"multiplier"`
+	Jitter                float64  `json:"jitter"`
+	RetryableErrorClasses []string `json:"retryableErrorClasses"`
 }
 
 type Job struct {
@@ -44,8 +106,72 @@ type Job struct {
 	CancellationRequestedAt *time.Time      `db:"cancellation_requested_at" json:"cancellationRequestedAt,omitempty"`
 	LastHeartbeatAt         *time.Time      `db:"last_heartbeat_at" json:"lastHeartbeatAt,omitempty"`
 	CreatedAt               time.Time       `db:"created_at" json:"createdAt"`
+	ScheduleID              *int            `db:"schedule_id" json:"scheduleId,omitempty"`
+	// AfterJobID != nil: job retido (pending, ainda não publicado) até o job
+	// referenciado terminar — ver overlap_policy queue_after/cancel_previous.
+	AfterJobID *uuid.UUID `db:"after_job_id" json:"afterJobId,omitempty"`
+	Trigger    string     `db:"trigger" json:"trigger"`
+	// RunAt: execução única adiada — o job fica em status scheduled até esse
+	// instante e só então é publicado na fila.
+	RunAt *time.Time `db:"run_at" json:"runAt,omitempty"`
+	// Held: job pending de automação com limite de concorrência ainda não
+	// publicado — o dispatcher publica quando houver vaga. QueuePosition é a
+	// posição dele na fila da automação (1 = próximo), calculada na leitura,
+	// e EstimatedReleaseAt a previsão de saída pelo limite de taxa.
+	Held        bool    `db:"held" json:"held"`
+	ResourceKey *string `db:"resource_key" json:"resourceKey,omitempty"`
+	// Priority (0–MaxPriority) vai na mensagem AMQP e ordena a fila retida.
+	Priority           int        `db:"priority" json:"priority"`
+	IdempotencyKey     *string    `db:"idempotency_key" json:"idempotencyKey,omitempty"`
+	QueuePosition      *int       `db:"-" json:"queuePosition,omitempty"`
+	EstimatedReleaseAt *time.Time `db:"-" json:"estimatedReleaseAt,omitempty"`
+	// Fan-out: o pai tem FanOutParam (a lista dividida) e nunca vai pra fila —
+	// o status dele é agregado dos filhos, que apontam pra ele em ParentJobID
+	// e levam o item em FanOutItem. Children vem preenchido na leitura do pai.
+	ParentJobID *uuid.UUID `db:"parent_job_id" json:"parentJobId,omitempty"`
+	FanOutParam *string    `db:"fan_out_param" json:"fanOutParam,omitempty"`
+	FanOutItem  *string    `db:"fan_out_item" json:"fanOutItem,omitempty"`
+	Children    []Job      `db:"-" json:"children,omitempty"`
+	// Passo de pipeline: a execução (PipelineRun) e a chave do passo.
+	PipelineRunID *uuid.UUID `db:"pipeline_run_id" json:"pipelineRunId,omitempty"`
+	PipelineStep  *string    `db:"pipeline_step" json:"pipelineStep,omitempty"`
+	// RetryOf: job de que este é uma reexecução.
+	RetryOf *uuid.UUID `db:"retry_of" json:"retryOf,omitempty"`
+}
+
+// IsFanOutParent diz se o job é o pai de um fan-out.
+func (j *Job) IsFanOutParent() bool {
+	return j.FanOutParam != nil
+}
+
+// MaxPriority é o x-max-priority das filas: prioridades vão de 0 a 10.
+const MaxPriority = 10
+
+// DefaultPriorities é a prioridade de um job por trigger quando a automação
+// não define outra: operador esperando na tela passa na frente do backlog de
+// agendamentos, e retentativas ficam por último.
+var DefaultPriorities = map[string]int{
+	TriggerManual:   8,
+	TriggerAPI:      6,
+	TriggerSchedule: 4,
+	TriggerRetry:    2,
+}
+
+// JobLoad é um job em voo (running ou pending já publicado), do ponto de vista
+// dos limites de concorrência.
+type JobLoad struct {
+	AutomationID int
+	ResourceKey  *string
 }
 
+// Origens de um job (jobs.trigger).
+const (
+	TriggerManual   = "manual"   // usuário clicou Executar
+	TriggerSchedule = "schedule" // disparo de agendamento (ScheduleID preenchido)
+	TriggerRetry    = "retry"    // "Tentar novamente" num job finalizado
+	TriggerAPI      = "api"      // execução via API sem usuário associado
+)
+
 // JobMetrics agrega contadores de jobs em janelas de tempo úteis para o dashboard.
 type JobMetrics struct {
 	Running         int     `json:"running"`
@@ -79,8 +205,10 @@ type AutomationHealth struct {
 	Failed       int      `json:"failed"`
 	Canceled     int      `json:"canceled"`
 	SuccessRate  float64  `json:"successRate"`
-	Manual       int      `json:"manual"`    // disparados por usuário (user_id != NULL)
-	Scheduled    int      `json:"scheduled"` // disparados por agendamento (user_id NULL)
+	Manual       int      `json:"manual"`    // trigger manual
+	Scheduled    int      `json:"scheduled"` // trigger schedule
+	Retried      int      `json:"retried"`   // trigger retry
+	API          int      `json:"api"`       // trigger api
 	DurationP50S *float64 `json:"durationP50S,omitempty"`
 	DurationP95S *float64 `json:"durationP95S,omitempty"`
 	LastStatus   *string  `json:"lastStatus,omitempty"`
@@ -100,6 +228,10 @@ type JobListFilter struct {
 	Status       *string
 	AutomationID *int
 	UserID       *int
+	ScheduleID   *int
+	Trigger      *string
+	Held         *bool
+	ParentJobID  *uuid.UUID
 	Since        *time.Time
 	Until        *time.Time
 	Limit        int
@@ -115,13 +247,257 @@ type JobLog struct {
 	Actionable bool      `db:"actionable" json:"actionable"`
 }
 
+// Tipos de agendamento: expressão cron ou intervalo fixo a partir de uma
+// âncora ("a cada 90 minutos a partir das 07:15").
+const (
+	ScheduleKindCron     = "cron"
+	ScheduleKindInterval = "interval"
+)
+
+// Políticas de misfire (disparos perdidos com o backend fora do ar).
+const (
+	MisfireSkip    = "skip"
+	MisfireRunOnce = "run_once"
+	MisfireRunAll  = "run_all"
+)
+
+// Políticas de sobreposição (tick do cron com o job anterior ainda ativo).
+const (
+	OverlapAllow          = "allow"
+	OverlapSkip           = "skip"
+	OverlapQueueAfter     = "queue_after"
+	OverlapCancelPrevious = "cancel_previous"
+)
+
+// Políticas de feriado: o que fazer com um disparo que cai em dia não útil
+// (fim de semana ou feriado do calendário do agendamento).
+const (
+	HolidayIgnore          = "ignore"            // dispara normalmente
+	HolidaySkip            = "skip"              // não dispara
+	HolidayNextBusinessDay = "next_business_day" // adia pro próximo dia útil, mesmo horário
+)
+
 type Schedule struct {
-	ID             int             `db:"id" json:"id"`
-	AutomationID   int             `db:"automation_id" json:"automationId"`
-	CronExpression string          `db:"cron_expression" json:"cronExpression"`
-	Parameters     json.RawMessage `db:"parameters" json:"parameters,omitempty"`
-	NextRunAt      *time.Time      `db:"next_run_at" json:"nextRunAt,omitempty"`
-	IsEnabled      bool            `db:"is_enabled" json:"isEnabled"`
-	CreatedAt      time.Time       `db:"created_at" json:"createdAt"`
-	UpdatedAt      time.Time       `db:"updated_at" json:"updatedAt"`
+	ID                        int             `db:"id" json:"id"`
+	AutomationID              int             `db:"automation_id" json:"automationId"`
+	CronExpression            string          `db:"cron_expression" json:"cronExpression"`
+	// Kind interval: dispara em IntervalAnchor + k*IntervalMinutes (k >= 0) e
+	// ignora CronExpression. ActiveFrom/ActiveTo ("HH:MM", no fuso do
+	// agendamento) restringem os disparos a uma janela diária; to < from
+	// atravessa a meia-noite.
+	Kind            string     `db:"kind" json:"kind"`
+	IntervalAnchor  *time.Time `db:"interval_anchor" json:"intervalAnchor,omitempty"`
+	IntervalMinutes *int       `db:"interval_minutes" json:"intervalMinutes,omitempty"`
+	ActiveFrom      *string    `db:"active_from" json:"activeFrom,omitempty"`
+	ActiveTo        *string    `db:"active_to" json:"activeTo,omitempty"`
+	Timezone                  *string         `db:"timezone" json:"timezone,omitempty"`
+	Parameters                json.RawMessage `db:"parameters" json:"parameters,omitempty"`
+	NextRunAt                 *time.Time      `db:"next_run_at" json:"nextRunAt,omitempty"`
+	IsEnabled                 bool            `db:"is_enabled" json:"isEnabled"`
+	MisfirePolicy             string          `db:"misfire_policy" json:"misfirePolicy"`
+	MisfireMaxLookbackMinutes int             `db:"misfire_max_lookback_minutes" json:"misfireMaxLookbackMinutes"`
+	OverlapPolicy             string          `db:"overlap_policy" json:"overlapPolicy"`
+	CalendarID                *int            `db:"calendar_id" json:"calendarId,omitempty"`
+	HolidayPolicy             string          `db:"holiday_policy" json:"holidayPolicy"`
+	// FanOutParam: cada disparo vira um job pai com um filho por item desta
+	// lista dos parâmetros (ver fanout.Split).
+	FanOutParam *string `db:"fan_out_param" json:"fanOutParam,omitempty"`
+	// PausedUntil: nenhum disparo antes desse instante. Só é escrito pelas
+	// rotas de pause/resume, nunca pelo update.
+	PausedUntil *time.Time `db:"paused_until" json:"pausedUntil,omitempty"`
+	PauseReason *string    `db:"pause_reason" json:"pauseReason,omitempty"`
+	CreatedAt   time.Time  `db:"created_at" json:"createdAt"`
+	UpdatedAt   time.Time  `db:"updated_at" json:"updatedAt"`
+
+	// Preenchidos pela API na resposta (não vêm do banco): o fuso efetivo
+	// (timezone ou o padrão do processo), o próximo disparo nesse fuso e em
+	// UTC, e a pausa em vigor agora (pausedUntil ou janela de bloqueio).
+	EffectiveTimezone string         `db:"-" json:"effectiveTimezone,omitempty"`
+	NextRunAtLocal    *time.Time     `db:"-" json:"nextRunAtLocal,omitempty"`
+	NextRunAtUTC      *time.Time     `db:"-" json:"nextRunAtUtc,omitempty"`
+	ActivePause       *SchedulePause `db:"-" json:"activePause,omitempty"`
+}
+
+// ScheduleListFilter agrega os filtros suportados por ScheduleRepository.List.
+// NextRunFrom/NextRunTo filtram por next_run_at (agendamentos sem próximo
+// disparo — desabilitados — ficam de fora quando algum dos dois é usado).
+type ScheduleListFilter struct {
+	AutomationID *int
+	Enabled      *bool
+	NextRunFrom  *time.Time
+	NextRunTo    *time.Time
+}
+
+// Tipos de SchedulePause.
+const (
+	PauseKindPaused   = "paused"   // schedules.paused_until
+	PauseKindBlackout = "blackout" // janela de bloqueio recorrente
+)
+
+// SchedulePause é uma pausa que cobre um instante: por que o disparo nesse
+// instante não sai e até quando.
+type SchedulePause struct {
+	Kind     string    `json:"kind"`
+	Until    time.Time `json:"until"`
+	Reason   string    `json:"reason,omitempty"`
+	WindowID *int      `json:"windowId,omitempty"`
+}
+
+// BlackoutWindow é uma janela de bloqueio recorrente (tabela blackout_windows):
+// começa a cada disparo de CronExpression, no fuso Timezone (vazio = padrão do
+// scheduler), e dura DurationMinutes. Vale pra um agendamento (ScheduleID) ou
+// pra todos os agendamentos de uma automação (AutomationID) — nunca os dois.
+type BlackoutWindow struct {
+	ID              int       `db:"id" json:"id"`
+	ScheduleID      *int      `db:"schedule_id" json:"scheduleId,omitempty"`
+	AutomationID    *int      `db:"automation_id" json:"automationId,omitempty"`
+	Name            string    `db:"name" json:"name"`
+	CronExpression  string    `db:"cron_expression" json:"cronExpression"`
+	DurationMinutes int       `db:"duration_minutes" json:"durationMinutes"`
+	Timezone        *string   `db:"timezone" json:"timezone,omitempty"`
+	IsEnabled       bool      `db:"is_enabled" json:"isEnabled"`
+	CreatedAt       time.Time `db:"created_at" json:"createdAt"`
+	UpdatedAt       time.Time `db:"updated_at" json:"updatedAt"`
+}
+
+// ScheduleRunStats resume o histórico de execuções de um agendamento
+// (GET /schedules/:id/runs). Last* vem do job finalizado mais recente;
+// ConsecutiveFailures conta os failed desde o último sucesso (canceled não
+// quebra nem soma na sequência).
+type ScheduleRunStats struct {
+	ScheduleID          int        `json:"scheduleId"`
+	TotalRuns           int        `json:"totalRuns"`
+	LastJobID           *uuid.UUID `json:"lastJobId,omitempty"`
+	LastStatus          *string    `json:"lastStatus,omitempty"`
+	LastRunAt           *time.Time `json:"lastRunAt,omitempty"`
+	LastDurationS       *float64   `json:"lastDurationS,omitempty"`
+	LastSuccessAt       *time.Time `json:"lastSuccessAt,omitempty"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+}
+
+// ScheduleFirePreview é um disparo futuro calculado pelo preview: quando e com
+// quais parâmetros (placeholders já expandidos) o job seria criado — ou, com
+// Suppressed, por que não seria.
+type ScheduleFirePreview struct {
+	FireAt     time.Time              `json:"fireAt"` // no fuso do agendamento
+	FireAtUTC  time.Time              `json:"fireAtUtc"`
+	PrevRun    *time.Time             `json:"prevRun,omitempty"`
+	Parameters map[string]interface{} `json:"parameters"`
+	Suppressed *SchedulePause         `json:"suppressed,omitempty"` // pausa/janela que impediria o disparo
+}
+
+// Tipos de ScheduleEvent.
+const (
+	ScheduleEventSkipped          = "skipped"           // tick pulado, nenhum job criado
+	ScheduleEventQueuedAfter      = "queued_after"      // job criado retido atrás do anterior
+	ScheduleEventCanceledPrevious = "canceled_previous" // anterior cancelado pra dar lugar ao novo
+	ScheduleEventPaused           = "paused"            // suprimido por pausedUntil
+	ScheduleEventBlackout         = "blackout"          // suprimido por janela de bloqueio
+)
+
+// ScheduleEvent registra uma decisão do scheduler sobre um disparo que não
+// virou (ou não virou de imediato) um job — tabela schedule_events.
+type ScheduleEvent struct {
+	ID           int64      `db:"id" json:"id"`
+	ScheduleID   int        `db:"schedule_id" json:"scheduleId"`
+	ScheduledFor time.Time  `db:"scheduled_for" json:"scheduledFor"`
+	Kind         string     `db:"kind" json:"kind"`
+	Reason       string     `db:"reason" json:"reason"`
+	JobID        *uuid.UUID `db:"job_id" json:"jobId,omitempty"`
+	CreatedAt    time.Time  `db:"created_at" json:"createdAt"`
+}
+
+// Calendar é um calendário de feriados (tabela calendars). IncludeNational
+// soma os feriados nacionais, calculados em Go (ver pacote calendar).
+type Calendar struct {
+	ID              int       `db:"id" json:"id"`
+	Name            string    `db:"name" json:"name"`
+	Description     *string   `db:"description" json:"description,omitempty"`
+	IncludeNational bool      `db:"include_national" json:"includeNational"`
+	CreatedAt       time.Time `db:"created_at" json:"createdAt"`
+	UpdatedAt       time.Time `db:"updated_at" json:"updatedAt"`
+
+	Holidays []CalendarHoliday `db:"-" json:"holidays,omitempty"`
+}
+
+// CalendarHoliday é uma data cadastrada num calendário. Date em YYYY-MM-DD;
+// Recurring = vale todo ano no mesmo dia/mês (o ano de Date é ignorado).
+type CalendarHoliday struct {
+	ID         int       `db:"id" json:"id"`
+	CalendarID int       `db:"calendar_id" json:"calendarId"`
+	Date       string    `db:"date" json:"date"`
+	Name       string    `db:"name" json:"name"`
+	Recurring  bool      `db:"recurring" json:"recurring"`
+	CreatedAt  time.Time `db:"created_at" json:"createdAt"`
+}
+
+// Pipeline encadeia automações num DAG (tabela pipelines): cada passo é um
+// job normal, e as arestas dizem quando o passo seguinte roda.
+type Pipeline struct {
+	ID          int            `db:"id" json:"id"`
+	Name        string         `db:"name" json:"name"`
+	Description *string        `db:"description" json:"description,omitempty"`
+	Steps       []PipelineStep `db:"steps" json:"steps"`
+	Edges       []PipelineEdge `db:"edges" json:"edges"`
+	CreatedAt   time.Time      `db:"created_at" json:"createdAt"`
+	UpdatedAt   time.Time      `db:"updated_at" json:"updatedAt"`
+}
+
+// PipelineStep é um passo do pipeline. Inputs mapeia parâmetro do passo →
+// "<passo anterior>.<caminho no result>" (ex.: "download.summary.arquivos"),
+// resolvido quando o passo sai; sobrescreve Parameters.
+type PipelineStep struct {
+	Key          string                 `json:"key"`
+	AutomationID int                    `json:"automationId"`
+	Parameters   map[string]interface{} `json:"parameters,omitempty"`
+	Inputs       map[string]string      `json:"inputs,omitempty"`
+}
+
+// PipelineEdge liga dois passos: To roda depois de From conforme On.
+type PipelineEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	On   string `json:"on"`
+}
+
+const (
+	EdgeOnSuccess = "on_success" // From terminou completed/completed_no_invoices
+	EdgeOnFailure = "on_failure" // From terminou failed/partial
+	EdgeAlways    = "always"     // From terminou, com qualquer status
+)
+
+// PipelineRun é uma execução de um pipeline (tabela pipeline_runs). Status:
+// running | completed | failed | canceled. Parameters sobrescreve, por chave
+// de passo, os parâmetros definidos no pipeline. Steps vem preenchido na
+// leitura de uma execução.
+type PipelineRun struct {
+	ID          uuid.UUID                         `db:"id" json:"id"`
+	PipelineID  int                               `db:"pipeline_id" json:"pipelineId"`
+	UserID      *int                              `db:"user_id" json:"userId,omitempty"`
+	Status      string                            `db:"status" json:"status"`
+	Parameters  map[string]map[string]interface{} `db:"parameters" json:"parameters,omitempty"`
+	CreatedAt   time.Time                         `db:"created_at" json:"createdAt"`
+	CompletedAt *time.Time                        `db:"completed_at" json:"completedAt,omitempty"`
+
+	Steps []PipelineRunStep `db:"-" json:"steps,omitempty"`
+}
+
+// PipelineRunStep é o estado de um passo numa execução: o status do job mais
+// recente do passo ou, quando o passo não tem job, waiting (aguardando os
+// anteriores), ready (sai no próximo avanço) ou skipped (aresta não
+// satisfeita).
+type PipelineRunStep struct {
+	Key    string `json:"key"`
+	Status string `json:"status"`
+	Job    *Job   `json:"job,omitempty"`
+}
+
+// LeaderLease é o lease de liderança entre réplicas (tabela leader_leases).
+// Só o holder com lease não expirado roda scheduler e retry worker.
+type LeaderLease struct {
+	Name       string    `db:"name" json:"name"`
+	Holder     string    `db:"holder" json:"holder"`
+	AcquiredAt time.Time `db:"acquired_at" json:"acquiredAt"`
+	RenewedAt  time.Time `db:"renewed_at" json:"renewedAt"`
+	ExpiresAt  time.Time `db:"expires_at" json:"expiresAt"`
 }
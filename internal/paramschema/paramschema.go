@@ -0,0 +1,197 @@
+// Package paramschema valida parâmetros de execução contra o parameterSchema
+// da automação — o mesmo formato que o formulário do painel usa (ver
+// docs/automations.md, seção 3.1) — e aplica sobre eles o defaultParams e
+// JSON merge patch (RFC 7396).
+package paramschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// dateLayout é o formato de data que o worker recebe ("dd/MM/yyyy").
+const dateLayout = "02/01/2006"
+
+// Field é um campo do parameterSchema.
+type Field struct {
+	Name        string   `json:"name"`
+	Label       string   `json:"label"`
+	Type        string   `json:"type"`
+	Required    bool     `json:"required,omitempty"`
+	Options     []string `json:"options,omitempty"`
+	Placeholder string   `json:"placeholder,omitempty"`
+	ItemType    string   `json:"itemType,omitempty"`
+}
+
+// FieldError é um erro de validação de um parâmetro.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors junta os erros de todos os campos.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.Field + ": " + fe.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Options ajusta a validação.
+type Options struct {
+	// AllowPlaceholders aceita string com {{...}} em qualquer campo que não
+	// seja boolean — agendamentos guardam os placeholders e o scheduler
+	// expande no disparo.
+	AllowPlaceholders bool
+}
+
+// Parse lê o parameterSchema gravado na automação. Schema vazio ou null
+// devolve nil (nada a validar).
+func Parse(raw json.RawMessage) ([]Field, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var fields []Field
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, fmt.Errorf("parameterSchema inválido: %w", err)
+	}
+	return fields, nil
+}
+
+// Validate confere params contra o schema: obrigatórios, tipo de cada campo,
+// opções de select e tipo dos itens de list. Parâmetros fora do schema passam
+// sem checagem. Devolve nil se estiver tudo certo.
+func Validate(schema []Field, params map[string]interface{}, opts Options) Errors {
+	var errs Errors
+	for _, f := range schema {
+		v, present := params[f.Name]
+		if !present || v == nil || v == "" || isEmptyList(v) {
+			if f.Required {
+				errs = append(errs, FieldError{Field: f.Name, Message: "obrigatório"})
+			}
+			continue
+		}
+		if opts.AllowPlaceholders && f.Type != "boolean" {
+			if s, ok := v.(string); ok && strings.Contains(s, "{{") {
+				continue
+			}
+		}
+		if msg := checkType(f, v); msg != "" {
+			errs = append(errs, FieldError{Field: f.Name, Message: msg})
+		}
+	}
+	return errs
+}
+
+func checkType(f Field, v interface{}) string {
+	switch f.Type {
+	case "text", "":
+		if _, ok := v.(string); !ok {
+			return "deve ser texto"
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return "deve ser número"
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return "deve ser true ou false"
+		}
+	case "date":
+		s, ok := v.(string)
+		if !ok {
+			return "deve ser data no formato dd/MM/yyyy"
+		}
+		if _, err := time.Parse(dateLayout, s); err != nil {
+			return fmt.Sprintf("data inválida %q (use dd/MM/yyyy)", s)
+		}
+	case "select":
+		s, ok := v.(string)
+		if !ok {
+			return "deve ser uma das opções: " + strings.Join(f.Options, ", ")
+		}
+		for _, o := range f.Options {
+			if s == o {
+				return ""
+			}
+		}
+		return fmt.Sprintf("%q não é uma opção válida (use %s)", s, strings.Join(f.Options, ", "))
+	case "list", "multiselect":
+		// multiselect aceita códigos fora das opções (o painel deixa
+		// adicionar), então só o tipo dos itens é conferido.
+		items, ok := v.([]interface{})
+		if !ok {
+			return "deve ser uma lista"
+		}
+		for i, item := range items {
+			switch f.ItemType {
+			case "number":
+				if _, ok := item.(float64); !ok {
+					return fmt.Sprintf("item %d deve ser número", i+1)
+				}
+			default:
+				if _, ok := item.(string); !ok {
+					return fmt.Sprintf("item %d deve ser texto", i+1)
+				}
+			}
+		}
+	default:
+		return fmt.Sprintf("tipo %q desconhecido no parameterSchema", f.Type)
+	}
+	return ""
+}
+
+func isEmptyList(v interface{}) bool {
+	items, ok := v.([]interface{})
+	return ok && len(items) == 0
+}
+
+// ApplyDefaults devolve params com os valores de defaults (o defaultParams da
+// automação) nos parâmetros ausentes ou null. params não é alterado.
+func ApplyDefaults(params map[string]interface{}, defaults json.RawMessage) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		out[k] = v
+	}
+	if len(defaults) == 0 || string(defaults) == "null" {
+		return out, nil
+	}
+	var d map[string]interface{}
+	if err := json.Unmarshal(defaults, &d); err != nil {
+		return nil, fmt.Errorf("defaultParams inválido: %w", err)
+	}
+	for k, v := range d {
+		if cur, ok := out[k]; !ok || cur == nil {
+			out[k] = v
+		}
+	}
+	return out, nil
+}
+
+// MergePatch aplica patch (JSON merge patch, RFC 7396) sobre params e devolve
+// um mapa novo: chave com null é removida, objeto é mesclado recursivamente e
+// qualquer outro valor (inclusive lista) substitui o original.
+func MergePatch(params, patch map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(params)+len(patch))
+	for k, v := range params {
+		out[k] = v
+	}
+	for k, pv := range patch {
+		if pv == nil {
+			delete(out, k)
+			continue
+		}
+		if pm, ok := pv.(map[string]interface{}); ok {
+			tm, _ := out[k].(map[string]interface{})
+			out[k] = MergePatch(tm, pm)
+			continue
+		}
+		out[k] = pv
+	}
+	return out
+}
@@ -0,0 +1,87 @@
+package paramschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const testSchema = `[
+	{"name": "stores", "label": "Lojas", "type": "list", "itemType": "number", "required": true},
+	{"name": "start_date", "label": "Data inicial", "type": "date", "required": true},
+	{"name": "tipo", "label": "Tipo", "type": "select", "options": ["nfe", "nfce"]},
+	{"name": "headless", "label": "Headless", "type": "boolean"},
+	{"name": "limite", "label": "Limite", "type": "number"}
+]`
+
+func params(raw string) map[string]interface{} {
+	var p map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &p); err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func TestValidate(t *testing.T) {
+	schema, err := Parse(json.RawMessage(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok := params(`{"stores": [4814, 6861], "start_date": "01/05/2026", "tipo": "nfe", "headless": true, "extra": 1}`)
+	if errs := Validate(schema, ok, Options{}); errs != nil {
+		t.Errorf("parâmetros válidos rejeitados: %v", errs)
+	}
+
+	bad := params(`{"stores": [4814, "x"], "start_date": "2026-05-01", "tipo": "cte", "headless": "sim", "limite": "10"}`)
+	want := []string{"stores", "start_date", "tipo", "headless", "limite"}
+	errs := Validate(schema, bad, Options{})
+	var got []string
+	for _, e := range errs {
+		got = append(got, e.Field)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("campos com erro = %v, esperava %v (%v)", got, want, errs)
+	}
+
+	missing := Validate(schema, params(`{"stores": []}`), Options{})
+	if len(missing) != 2 || missing[0].Message != "obrigatório" {
+		t.Errorf("obrigatórios: %v", missing)
+	}
+
+	placeholder := params(`{"stores": [1], "start_date": "{{today-1}}", "limite": "{{n}}"}`)
+	if errs := Validate(schema, placeholder, Options{}); len(errs) != 2 {
+		t.Errorf("placeholder sem AllowPlaceholders deveria falhar: %v", errs)
+	}
+	if errs := Validate(schema, placeholder, Options{AllowPlaceholders: true}); errs != nil {
+		t.Errorf("placeholder com AllowPlaceholders: %v", errs)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	orig := params(`{"stores": [1, 2, 3], "start_date": "01/05/2026", "opts": {"a": 1, "b": 2}, "tipo": "nfe"}`)
+	patch := params(`{"stores": [2], "tipo": null, "opts": {"b": null, "c": 3}}`)
+
+	got := MergePatch(orig, patch)
+	want := params(`{"stores": [2], "start_date": "01/05/2026", "opts": {"a": 1, "c": 3}}`)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MergePatch = %v, esperava %v", got, want)
+	}
+	if _, ok := orig["tipo"]; !ok {
+		t.Error("MergePatch alterou o mapa original")
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	got, err := ApplyDefaults(params(`{"tipo": "nfce", "headless": null}`), json.RawMessage(`{"tipo": "nfe", "headless": true, "limite": 10}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := params(`{"tipo": "nfce", "headless": true, "limite": 10}`)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ApplyDefaults = %v, esperava %v", got, want)
+	}
+	if _, err := ApplyDefaults(nil, json.RawMessage(`[1]`)); err == nil {
+		t.Error("defaultParams que não é objeto deveria dar erro")
+	}
+}
@@ -0,0 +1,288 @@
+// Package pipeline implementa as regras do DAG de automações
+// (models.Pipeline): validação da definição, o estado de cada passo numa
+// execução a partir dos jobs já criados e a resolução dos inputs mapeados do
+// result de um passo anterior. Quem cria e publica os jobs é o scheduler.
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+)
+
+// MaxSteps limita o tamanho de um pipeline.
+const MaxSteps = 50
+
+// Estados de passo sem job (os demais são o status do job do passo).
+const (
+	StateWaiting = "waiting" // algum passo anterior ainda não terminou
+	StateReady   = "ready"   // pode sair agora
+	StateSkipped = "skipped" // alguma aresta de entrada não foi satisfeita
+)
+
+var stepKeyRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,100}$`)
+
+var (
+	terminal = map[string]bool{
+		"completed": true, "completed_no_invoices": true, "partial": true, "failed": true, "canceled": true,
+	}
+	succeeded = map[string]bool{"completed": true, "completed_no_invoices": true}
+	failed    = map[string]bool{"failed": true, "partial": true}
+)
+
+// Validate confere a definição: chaves únicas (sem ponto — o ponto separa o
+// passo do caminho nos inputs), arestas entre passos existentes, sem ciclo e
+// inputs vindos só de passos com aresta direta pro passo.
+func Validate(steps []models.PipelineStep, edges []models.PipelineEdge) error {
+	if len(steps) == 0 {
+		return fmt.Errorf("o pipeline precisa de ao menos um passo")
+	}
+	if len(steps) > MaxSteps {
+		return fmt.Errorf("o pipeline tem %d passos (máximo %d)", len(steps), MaxSteps)
+	}
+
+	keys := make(map[string]bool, len(steps))
+	for _, s := range steps {
+		if !stepKeyRe.MatchString(s.Key) {
+			return fmt.Errorf("chave de passo inválida %q (use letras, números, _ ou -)", s.Key)
+		}
+		if keys[s.Key] {
+			return fmt.Errorf("chave de passo %q repetida", s.Key)
+		}
+		keys[s.Key] = true
+		if s.AutomationID <= 0 {
+			return fmt.Errorf("passo %q sem automationId", s.Key)
+		}
+	}
+
+	direct := make(map[[2]string]bool, len(edges))
+	for _, e := range edges {
+		if !keys[e.From] || !keys[e.To] {
+			return fmt.Errorf("aresta %s → %s liga passo inexistente", e.From, e.To)
+		}
+		if e.From == e.To {
+			return fmt.Errorf("aresta %s → %s liga o passo a ele mesmo", e.From, e.To)
+		}
+		switch e.On {
+		case models.EdgeOnSuccess, models.EdgeOnFailure, models.EdgeAlways:
+		default:
+			return fmt.Errorf("aresta %s → %s: on inválido %q (use on_success, on_failure ou always)", e.From, e.To, e.On)
+		}
+		pair := [2]string{e.From, e.To}
+		if direct[pair] {
+			return fmt.Errorf("aresta %s → %s repetida", e.From, e.To)
+		}
+		direct[pair] = true
+	}
+
+	if _, err := order(steps, edges); err != nil {
+		return err
+	}
+
+	for _, s := range steps {
+		for param, ref := range s.Inputs {
+			if strings.TrimSpace(param) == "" {
+				return fmt.Errorf("passo %q: input sem nome de parâmetro", s.Key)
+			}
+			from, path, ok := strings.Cut(ref, ".")
+			if !ok || path == "" {
+				return fmt.Errorf("passo %q: input %q deve ter a forma <passo>.<caminho no result>", s.Key, param)
+			}
+			if !direct[[2]string{from, s.Key}] {
+				return fmt.Errorf("passo %q: input %q vem de %q, que não tem aresta pra este passo", s.Key, param, from)
+			}
+		}
+	}
+	return nil
+}
+
+// order devolve as chaves em ordem topológica (estável na ordem da
+// definição) ou erro se houver ciclo.
+func order(steps []models.PipelineStep, edges []models.PipelineEdge) ([]string, error) {
+	indegree := make(map[string]int, len(steps))
+	for _, e := range edges {
+		indegree[e.To]++
+	}
+	out := make([]string, 0, len(steps))
+	done := make(map[string]bool, len(steps))
+	for len(out) < len(steps) {
+		progressed := false
+		for _, s := range steps {
+			if done[s.Key] || indegree[s.Key] > 0 {
+				continue
+			}
+			done[s.Key] = true
+			out = append(out, s.Key)
+			progressed = true
+			for _, e := range edges {
+				if e.From == s.Key {
+					indegree[e.To]--
+				}
+			}
+		}
+		if !progressed {
+			return nil, fmt.Errorf("o pipeline tem um ciclo")
+		}
+	}
+	return out, nil
+}
+
+// StepJob é o job mais recente de um passo numa execução.
+type StepJob struct {
+	Status string
+	Result json.RawMessage
+}
+
+// Plan é o estado de uma execução.
+type Plan struct {
+	States map[string]string // por passo: status do job ou StateWaiting/StateReady/StateSkipped
+	Ready  []string          // passos que podem sair agora, na ordem topológica
+	Status string            // running | completed | failed | canceled
+}
+
+// Evaluate calcula o estado de cada passo a partir dos jobs já criados. Um
+// passo sem job fica pronto quando todos os anteriores terminaram (ou foram
+// pulados) e todas as arestas de entrada foram satisfeitas; se alguma não
+// foi, ele é pulado — e passo pulado não satisfaz aresta nenhuma.
+//
+// A execução termina quando nada está aberto: failed se algum passo falhou
+// sem aresta on_failure/always saindo dele (falha não tratada), canceled se
+// algum foi cancelado sem aresta always, completed no resto.
+func Evaluate(steps []models.PipelineStep, edges []models.PipelineEdge, jobs map[string]StepJob) Plan {
+	plan := Plan{States: make(map[string]string, len(steps))}
+	keys, err := order(steps, edges)
+	if err != nil {
+		// Definição validada na gravação; só chega aqui editando o banco.
+		plan.Status = "failed"
+		return plan
+	}
+
+	for _, key := range keys {
+		if j, ok := jobs[key]; ok {
+			plan.States[key] = j.Status
+			continue
+		}
+		resolved, satisfied := true, true
+		for _, e := range edges {
+			if e.To != key {
+				continue
+			}
+			up := plan.States[e.From]
+			if !terminal[up] && up != StateSkipped {
+				resolved = false
+				break
+			}
+			if !edgeSatisfied(e.On, up) {
+				satisfied = false
+			}
+		}
+		switch {
+		case !resolved:
+			plan.States[key] = StateWaiting
+		case satisfied:
+			plan.States[key] = StateReady
+			plan.Ready = append(plan.Ready, key)
+		default:
+			plan.States[key] = StateSkipped
+		}
+	}
+
+	open, unhandledFailure, unhandledCancel := false, false, false
+	for _, key := range keys {
+		st := plan.States[key]
+		switch {
+		case st == StateSkipped:
+		case !terminal[st]:
+			open = true
+		case failed[st] && !hasEdge(edges, key, models.EdgeOnFailure, models.EdgeAlways):
+			unhandledFailure = true
+		case st == "canceled" && !hasEdge(edges, key, models.EdgeAlways):
+			unhandledCancel = true
+		}
+	}
+	switch {
+	case open:
+		plan.Status = "running"
+	case unhandledFailure:
+		plan.Status = "failed"
+	case unhandledCancel:
+		plan.Status = "canceled"
+	default:
+		plan.Status = "completed"
+	}
+	return plan
+}
+
+func edgeSatisfied(on, upstream string) bool {
+	switch on {
+	case models.EdgeOnSuccess:
+		return succeeded[upstream]
+	case models.EdgeOnFailure:
+		return failed[upstream]
+	case models.EdgeAlways:
+		return terminal[upstream]
+	}
+	return false
+}
+
+func hasEdge(edges []models.PipelineEdge, from string, on ...string) bool {
+	for _, e := range edges {
+		if e.From != from {
+			continue
+		}
+		for _, o := range on {
+			if e.On == o {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ResolveInputs busca, no result dos passos anteriores, o valor de cada input
+// do passo. Caminho ausente é erro — o passo não sai com parâmetro faltando.
+// Índices de lista entram como número ("summary.arquivos.0").
+func ResolveInputs(step models.PipelineStep, results map[string]json.RawMessage) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(step.Inputs))
+	for param, ref := range step.Inputs {
+		from, path, _ := strings.Cut(ref, ".")
+		var doc interface{}
+		if raw := results[from]; len(raw) > 0 {
+			if err := json.Unmarshal(raw, &doc); err != nil {
+				return nil, fmt.Errorf("input %q: result de %q inválido: %w", param, from, err)
+			}
+		}
+		v, ok := lookup(doc, strings.Split(path, "."))
+		if !ok {
+			return nil, fmt.Errorf("input %q: %q não encontrado no result de %q", param, path, from)
+		}
+		values[param] = v
+	}
+	return values, nil
+}
+
+func lookup(v interface{}, path []string) (interface{}, bool) {
+	for _, seg := range path {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			next, ok := node[seg]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []interface{}:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,102 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+)
+
+// download → import (on_success) → report (always); import → alert (on_failure).
+var (
+	testSteps = []models.PipelineStep{
+		{Key: "download", AutomationID: 1},
+		{Key: "import", AutomationID: 2, Inputs: map[string]string{"pasta": "download.summary.pasta"}},
+		{Key: "report", AutomationID: 3},
+		{Key: "alert", AutomationID: 4},
+	}
+	testEdges = []models.PipelineEdge{
+		{From: "download", To: "import", On: models.EdgeOnSuccess},
+		{From: "import", To: "report", On: models.EdgeAlways},
+		{From: "import", To: "alert", On: models.EdgeOnFailure},
+	}
+)
+
+func TestValidate(t *testing.T) {
+	if err := Validate(testSteps, testEdges); err != nil {
+		t.Fatalf("definição válida rejeitada: %v", err)
+	}
+
+	cycle := append([]models.PipelineEdge{{From: "report", To: "download", On: models.EdgeAlways}}, testEdges...)
+	if err := Validate(testSteps, cycle); err == nil {
+		t.Error("ciclo deveria ser rejeitado")
+	}
+
+	steps := append([]models.PipelineStep(nil), testSteps...)
+	steps[2] = models.PipelineStep{Key: "report", AutomationID: 3, Inputs: map[string]string{"x": "download.total"}}
+	if err := Validate(steps, testEdges); err == nil {
+		t.Error("input de passo sem aresta direta deveria ser rejeitado")
+	}
+
+	if err := Validate([]models.PipelineStep{{Key: "a.b", AutomationID: 1}}, nil); err == nil {
+		t.Error("chave com ponto deveria ser rejeitada")
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	cases := []struct {
+		name   string
+		jobs   map[string]StepJob
+		ready  []string
+		status string
+	}{
+		{"início", map[string]StepJob{}, []string{"download"}, "running"},
+		{"download ok", map[string]StepJob{"download": {Status: "completed"}}, []string{"import"}, "running"},
+		{"download falhou", map[string]StepJob{"download": {Status: "failed"}}, nil, "failed"},
+		{"import falhou", map[string]StepJob{
+			"download": {Status: "completed"}, "import": {Status: "failed"},
+		}, []string{"report", "alert"}, "running"},
+		{"falha tratada", map[string]StepJob{
+			"download": {Status: "completed"}, "import": {Status: "failed"},
+			"report": {Status: "completed"}, "alert": {Status: "completed"},
+		}, nil, "completed"},
+		{"tudo ok", map[string]StepJob{
+			"download": {Status: "completed"}, "import": {Status: "completed"}, "report": {Status: "completed"},
+		}, nil, "completed"},
+	}
+	for _, tc := range cases {
+		plan := Evaluate(testSteps, testEdges, tc.jobs)
+		if !reflect.DeepEqual(plan.Ready, tc.ready) || plan.Status != tc.status {
+			t.Errorf("%s: ready=%v status=%s, esperava ready=%v status=%s", tc.name, plan.Ready, plan.Status, tc.ready, tc.status)
+		}
+	}
+
+	plan := Evaluate(testSteps, testEdges, map[string]StepJob{"download": {Status: "failed"}})
+	if plan.States["import"] != StateSkipped || plan.States["report"] != StateSkipped {
+		t.Errorf("falha no download deveria pular o resto: %v", plan.States)
+	}
+}
+
+func TestResolveInputs(t *testing.T) {
+	results := map[string]json.RawMessage{
+		"download": json.RawMessage(`{"summary":{"pasta":"/xml/2026-05","arquivos":["a.xml","b.xml"]}}`),
+	}
+	got, err := ResolveInputs(testSteps[1], results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["pasta"] != "/xml/2026-05" {
+		t.Errorf("pasta: %v", got["pasta"])
+	}
+
+	step := models.PipelineStep{Key: "import", Inputs: map[string]string{"primeiro": "download.summary.arquivos.0"}}
+	if got, err := ResolveInputs(step, results); err != nil || got["primeiro"] != "a.xml" {
+		t.Errorf("índice de lista: %v, %v", got, err)
+	}
+
+	step.Inputs = map[string]string{"x": "download.summary.total"}
+	if _, err := ResolveInputs(step, results); err == nil {
+		t.Error("caminho ausente deveria dar erro")
+	}
+}
@@ -18,6 +18,10 @@ const (
 	dlxName = "maestro.dlx"
 	dlqName = "maestro.dlq"
 
+	// maxPriority é o x-max-priority das filas de jobs (models.MaxPriority).
+	// Worker e Maestro precisam declarar a fila com os mesmos argumentos.
+	maxPriority = 10
+
 	reconnectInitialBackoff = 1 * time.Second
 	reconnectMaxBackoff     = 30 * time.Second
 )
@@ -32,6 +36,10 @@ type RabbitMQClient struct {
 	mu      sync.RWMutex
 	conn    *amqp091.Connection
 	channel *amqp091.Channel
+	// Filas já declaradas nesta conexão (nome → true). Trocado junto com a
+	// conexão: depois de reconectar, cada fila é redeclarada no primeiro
+	// publish.
+	declared *sync.Map
 
 	closed atomic.Bool // true após Close() intencional — para de reconectar
 
@@ -40,6 +48,9 @@ type RabbitMQClient struct {
 	dlqMu      sync.Mutex
 	dlqHandler func(jobID string, reason string)
 	dlqCtx     context.Context
+
+	// Filas legadas (declaradas sem x-max-priority) já avisadas no log.
+	legacyWarned sync.Map
 }
 
 func NewRabbitMQClient(cfg config.RabbitMQConfig) (*RabbitMQClient, error) {
@@ -78,6 +89,7 @@ func (c *RabbitMQClient) connect() error {
 	c.mu.Lock()
 	c.conn = conn
 	c.channel = channel
+	c.declared = &sync.Map{}
 	c.mu.Unlock()
 	return nil
 }
@@ -168,23 +180,71 @@ type JobMessage struct {
 	AutomationID int                    `json:"automation_id"`
 	ScriptPath   string                 `json:"script_path"`
 	Parameters   map[string]interface{} `json:"parameters"`
+	Priority     int                    `json:"priority"`
+}
+
+// QueueArgs são os argumentos com que toda fila de jobs é declarada: DLX e
+// prioridade. Argumentos diferentes entre Maestro e worker fazem o broker
+// recusar a declaração com PRECONDITION_FAILED (406).
+func QueueArgs() amqp091.Table {
+	return amqp091.Table{
+		"x-dead-letter-exchange": dlxName,
+		"x-max-priority":         maxPriority,
+	}
+}
+
+// declareQueue declara a fila num canal descartável, só no primeiro publish
+// dela em cada conexão (declared) — os seguintes não pagam canal nem ida ao
+// broker. Se ela já existe com os argumentos antigos (sem x-max-priority), o
+// broker responde 406 e fecha o canal — fechar o canal compartilhado
+// derrubaria o consumidor da DLQ. Nesse caso a fila legada segue recebendo (o
+// broker ignora a prioridade), o log avisa uma vez que ela precisa ser
+// recriada e ela não entra em declared: enquanto não for migrada, cada
+// publish tenta de novo, e o primeiro depois de apagá-la já a recria com os
+// argumentos novos.
+func (c *RabbitMQClient) declareQueue(conn *amqp091.Connection, declared *sync.Map, queueName string) error {
+	if _, ok := declared.Load(queueName); ok {
+		return nil
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("falha ao abrir canal RabbitMQ: %w", err)
+	}
+	defer ch.Close()
+
+	_, err = ch.QueueDeclare(queueName, true, false, false, false, QueueArgs())
+	if err == nil {
+		declared.Store(queueName, true)
+		return nil
+	}
+	var amqpErr *amqp091.Error
+	if !errors.As(err, &amqpErr) || amqpErr.Code != amqp091.PreconditionFailed {
+		return fmt.Errorf("falha ao declarar fila: %w", err)
+	}
+	if _, warned := c.legacyWarned.LoadOrStore(queueName, true); !warned {
+		log.Warn().Str("queue", queueName).
+			Msg("fila declarada sem x-max-priority — jobs saem sem prioridade até ela ser recriada (docs/automations.md, seção 4)")
+	}
+	return nil
 }
 
 func (c *RabbitMQClient) PublishJob(ctx context.Context, queueName string, msg JobMessage) error {
 	c.mu.RLock()
-	channel := c.channel
+	conn, channel, declared := c.conn, c.channel, c.declared
 	c.mu.RUnlock()
 	if channel == nil {
 		return errors.New("canal RabbitMQ indisponível (reconectando)")
 	}
 
-	// Declara a fila com dead-letter exchange configurado
-	args := amqp091.Table{
-		"x-dead-letter-exchange": dlxName,
+	if err := c.declareQueue(conn, declared, queueName); err != nil {
+		return err
 	}
-	q, err := channel.QueueDeclare(queueName, true, false, false, false, args)
-	if err != nil {
-		return fmt.Errorf("falha ao declarar fila: %w", err)
+
+	priority := msg.Priority
+	if priority < 0 {
+		priority = 0
+	} else if priority > maxPriority {
+		priority = maxPriority
 	}
 
 	body, err := json.Marshal(msg)
@@ -192,10 +252,11 @@ func (c *RabbitMQClient) PublishJob(ctx context.Context, queueName string, msg J
 		return fmt.Errorf("falha ao serializar mensagem: %w", err)
 	}
 
-	err = channel.PublishWithContext(ctx, "", q.Name, false, false,
+	err = channel.PublishWithContext(ctx, "", queueName, false, false,
 		amqp091.Publishing{
 			DeliveryMode: amqp091.Persistent,
 			ContentType:  "application/json",
+			Priority:     uint8(priority),
 			Body:         body,
 		},
 	)
@@ -203,7 +264,7 @@ func (c *RabbitMQClient) PublishJob(ctx context.Context, queueName string, msg J
 		return fmt.Errorf("falha ao publicar mensagem: %w", err)
 	}
 
-	log.Info().Str("queue", queueName).Str("job_id", msg.JobID).Msg("job publicado")
+	log.Info().Str("queue", queueName).Str("job_id", msg.JobID).Int("priority", priority).Msg("job publicado")
 	return nil
 }
 
@@ -0,0 +1,69 @@
+// Package ratelimit implementa o token bucket do limite de taxa por automação
+// (models.RateLimit). O estado fica no Postgres (models.RateBucket); aqui só a
+// conta, pra o dispatcher e a estimativa de liberação usarem a mesma regra.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+)
+
+// Limites aceitos na configuração.
+const (
+	maxJobs          = 10000
+	maxPeriodSeconds = 7 * 24 * 3600
+)
+
+// Validate confere a configuração do limite.
+func Validate(rl *models.RateLimit) error {
+	if rl.Jobs < 1 || rl.Jobs > maxJobs {
+		return fmt.Errorf("jobs deve estar entre 1 e %d", maxJobs)
+	}
+	if rl.PeriodSeconds < 1 || rl.PeriodSeconds > maxPeriodSeconds {
+		return fmt.Errorf("periodSeconds deve estar entre 1 e %d", maxPeriodSeconds)
+	}
+	return nil
+}
+
+// perSecond é a taxa de reposição em fichas por segundo.
+func perSecond(rl *models.RateLimit) float64 {
+	return float64(rl.Jobs) / float64(rl.PeriodSeconds)
+}
+
+// Available devolve as fichas disponíveis em now. Balde nunca usado (nil) está
+// cheio.
+func Available(b *models.RateBucket, rl *models.RateLimit, now time.Time) float64 {
+	capacity := float64(rl.Jobs)
+	if b == nil {
+		return capacity
+	}
+	elapsed := now.Sub(b.RefilledAt).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(capacity, b.Tokens+elapsed*perSecond(rl))
+}
+
+// Take consome uma ficha em now. Devolve o novo estado do balde e false, sem
+// mudar nada, quando não há ficha.
+func Take(b *models.RateBucket, automationID int, rl *models.RateLimit, now time.Time) (*models.RateBucket, bool) {
+	tokens := Available(b, rl, now)
+	if tokens < 1 {
+		return b, false
+	}
+	return &models.RateBucket{AutomationID: automationID, Tokens: tokens - 1, RefilledAt: now}, true
+}
+
+// ReleaseAt estima quando o job na posição `position` da fila retida (1 =
+// próximo) terá ficha, supondo que os da frente saiam assim que puderem.
+// Devolve now se já houver fichas pra ele.
+func ReleaseAt(b *models.RateBucket, rl *models.RateLimit, now time.Time, position int) time.Time {
+	missing := float64(position) - Available(b, rl, now)
+	if missing <= 0 {
+		return now
+	}
+	return now.Add(time.Duration(math.Ceil(missing / perSecond(rl) * float64(time.Second))))
+}
@@ -0,0 +1,41 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+)
+
+func TestBucket(t *testing.T) {
+	rl := &models.RateLimit{Jobs: 30, PeriodSeconds: 3600} // 1 ficha a cada 2 min
+	now := time.Date(2026, 5, 18, 8, 0, 0, 0, time.UTC)
+
+	// Balde novo está cheio: 30 saem na hora, o 31º não.
+	var b *models.RateBucket
+	for i := 0; i < 30; i++ {
+		var ok bool
+		if b, ok = Take(b, 1, rl, now); !ok {
+			t.Fatalf("ficha %d deveria estar disponível", i+1)
+		}
+	}
+	if _, ok := Take(b, 1, rl, now); ok {
+		t.Fatal("31º job na mesma hora deveria esperar")
+	}
+
+	// Posição 1 espera uma reposição (2 min), posição 3 espera três.
+	if got, want := ReleaseAt(b, rl, now, 1), now.Add(2*time.Minute); !got.Equal(want) {
+		t.Errorf("posição 1: esperava %s, veio %s", want, got)
+	}
+	if got, want := ReleaseAt(b, rl, now, 3), now.Add(6*time.Minute); !got.Equal(want) {
+		t.Errorf("posição 3: esperava %s, veio %s", want, got)
+	}
+
+	// Depois de 2 min há ficha de novo; parado o dia todo, não passa de 30.
+	if _, ok := Take(b, 1, rl, now.Add(2*time.Minute)); !ok {
+		t.Error("ficha deveria ter reposto após 2 min")
+	}
+	if got := Available(b, rl, now.Add(24*time.Hour)); got != 30 {
+		t.Errorf("balde deveria encher até 30, veio %v", got)
+	}
+}
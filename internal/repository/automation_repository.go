@@ -9,8 +9,9 @@ import (
 )
 
 func (r *PostgresAutomationRepository) Create(ctx context.Context, automation *models.Automation) error {
-	sql := `INSERT INTO automations (name, description, script_path, queue_name, default_params, parameter_schema)
-	        VALUES ($1, $2, $3, $4, $5, $6)
+	sql := `INSERT INTO automations (name, description, script_path, queue_name, default_params, parameter_schema, retry_policy, max_duration_minutes,
+	                                 max_concurrency, resource_key, resource_concurrency, rate_limit, priorities, retry_failed)
+	        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 	        RETURNING id, created_at, updated_at`
 
 	err := r.db.QueryRow(ctx, sql,
@@ -20,6 +21,14 @@ func (r *PostgresAutomationRepository) Create(ctx context.Context, automation *m
 		automation.QueueName,
 		automation.DefaultParams,
 		automation.ParameterSchema,
+		automation.RetryPolicy,
+		automation.MaxDurationMinutes,
+		automation.MaxConcurrency,
+		automation.ResourceKey,
+		automation.ResourceConcurrency,
+		automation.RateLimit,
+		automation.Priorities,
+		automation.RetryFailed,
 	).Scan(&automation.ID, &automation.CreatedAt, &automation.UpdatedAt)
 
 	if err != nil {
@@ -29,7 +38,7 @@ func (r *PostgresAutomationRepository) Create(ctx context.Context, automation *m
 }
 
 func (r *PostgresAutomationRepository) GetByID(ctx context.Context, id int) (*models.Automation, error) {
-	sql := `SELECT id, name, description, script_path, queue_name, default_params, parameter_schema, created_at, updated_at
+	sql := `SELECT id, name, description, script_path, queue_name, default_params, parameter_schema, retry_policy, max_duration_minutes, max_concurrency, resource_key, resource_concurrency, rate_limit, priorities, retry_failed, created_at, updated_at
 	        FROM automations WHERE id = $1`
 
 	a := &models.Automation{}
@@ -41,6 +50,14 @@ func (r *PostgresAutomationRepository) GetByID(ctx context.Context, id int) (*mo
 		&a.QueueName,
 		&a.DefaultParams,
 		&a.ParameterSchema,
+		&a.RetryPolicy,
+		&a.MaxDurationMinutes,
+		&a.MaxConcurrency,
+		&a.ResourceKey,
+		&a.ResourceConcurrency,
+		&a.RateLimit,
+		&a.Priorities,
+		&a.RetryFailed,
 		&a.CreatedAt,
 		&a.UpdatedAt,
 	)
@@ -51,7 +68,7 @@ func (r *PostgresAutomationRepository) GetByID(ctx context.Context, id int) (*mo
 }
 
 func (r *PostgresAutomationRepository) GetByName(ctx context.Context, name string) (*models.Automation, error) {
-	sql := `SELECT id, name, description, script_path, queue_name, default_params, parameter_schema, created_at, updated_at
+	sql := `SELECT id, name, description, script_path, queue_name, default_params, parameter_schema, retry_policy, max_duration_minutes, max_concurrency, resource_key, resource_concurrency, rate_limit, priorities, retry_failed, created_at, updated_at
 	        FROM automations WHERE name = $1`
 
 	a := &models.Automation{}
@@ -63,6 +80,14 @@ func (r *PostgresAutomationRepository) GetByName(ctx context.Context, name strin
 		&a.QueueName,
 		&a.DefaultParams,
 		&a.ParameterSchema,
+		&a.RetryPolicy,
+		&a.MaxDurationMinutes,
+		&a.MaxConcurrency,
+		&a.ResourceKey,
+		&a.ResourceConcurrency,
+		&a.RateLimit,
+		&a.Priorities,
+		&a.RetryFailed,
 		&a.CreatedAt,
 		&a.UpdatedAt,
 	)
@@ -73,7 +98,7 @@ func (r *PostgresAutomationRepository) GetByName(ctx context.Context, name strin
 }
 
 func (r *PostgresAutomationRepository) GetAll(ctx context.Context) ([]models.Automation, error) {
-	sql := `SELECT id, name, description, script_path, queue_name, default_params, parameter_schema, created_at, updated_at
+	sql := `SELECT id, name, description, script_path, queue_name, default_params, parameter_schema, retry_policy, max_duration_minutes, max_concurrency, resource_key, resource_concurrency, rate_limit, priorities, retry_failed, created_at, updated_at
 	        FROM automations ORDER BY name`
 
 	rows, err := r.db.Query(ctx, sql)
@@ -91,7 +116,10 @@ func (r *PostgresAutomationRepository) GetAll(ctx context.Context) ([]models.Aut
 
 func (r *PostgresAutomationRepository) Update(ctx context.Context, automation *models.Automation) error {
 	sql := `UPDATE automations
-	        SET name = $1, description = $2, script_path = $3, queue_name = $4, default_params = $5, parameter_schema = $6, updated_at = NOW()
+	        SET name = $1, description = $2, script_path = $3, queue_name = $4, default_params = $5, parameter_schema = $6,
+	            retry_policy = $8, max_duration_minutes = $9,
+	            max_concurrency = $10, resource_key = $11, resource_concurrency = $12,
+	            rate_limit = $13, priorities = $14, retry_failed = $15, updated_at = NOW()
 	        WHERE id = $7
 	        RETURNING updated_at`
 
@@ -103,6 +131,14 @@ func (r *PostgresAutomationRepository) Update(ctx context.Context, automation *m
 		automation.DefaultParams,
 		automation.ParameterSchema,
 		automation.ID,
+		automation.RetryPolicy,
+		automation.MaxDurationMinutes,
+		automation.MaxConcurrency,
+		automation.ResourceKey,
+		automation.ResourceConcurrency,
+		automation.RateLimit,
+		automation.Priorities,
+		automation.RetryFailed,
 	).Scan(&automation.UpdatedAt)
 
 	if err != nil {
@@ -122,4 +158,32 @@ func (r *PostgresAutomationRepository) Delete(ctx context.Context, id int) error
 		return fmt.Errorf("nenhuma automação encontrada para deletar com ID %d", id)
 	}
 	return nil
+}
+
+// GetRateBucket devolve o estado do token bucket da automação, ou (nil, nil)
+// se ela nunca publicou com limite de taxa (balde cheio).
+func (r *PostgresAutomationRepository) GetRateBucket(ctx context.Context, automationID int) (*models.RateBucket, error) {
+	sql := `SELECT automation_id, tokens, refilled_at FROM rate_limit_buckets WHERE automation_id = $1`
+
+	b := &models.RateBucket{}
+	err := r.db.QueryRow(ctx, sql, automationID).Scan(&b.AutomationID, &b.Tokens, &b.RefilledAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar limite de taxa da automação: %w", err)
+	}
+	return b, nil
+}
+
+// SaveRateBucket grava o estado do token bucket depois de uma publicação.
+func (r *PostgresAutomationRepository) SaveRateBucket(ctx context.Context, b *models.RateBucket) error {
+	sql := `INSERT INTO rate_limit_buckets (automation_id, tokens, refilled_at)
+	        VALUES ($1, $2, $3)
+	        ON CONFLICT (automation_id) DO UPDATE SET tokens = EXCLUDED.tokens, refilled_at = EXCLUDED.refilled_at`
+
+	if _, err := r.db.Exec(ctx, sql, b.AutomationID, b.Tokens, b.RefilledAt); err != nil {
+		return fmt.Errorf("erro ao salvar limite de taxa da automação: %w", err)
+	}
+	return nil
 }
\ No newline at end of file
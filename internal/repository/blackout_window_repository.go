@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const blackoutWindowSelectColumns = `id, schedule_id, automation_id, name, cron_expression, duration_minutes,
+	timezone, is_enabled, created_at, updated_at`
+
+func (r *PostgresBlackoutWindowRepository) Create(ctx context.Context, w *models.BlackoutWindow) error {
+	sql := `INSERT INTO blackout_windows (schedule_id, automation_id, name, cron_expression, duration_minutes,
+	                                      timezone, is_enabled)
+	        VALUES ($1, $2, $3, $4, $5, $6, $7)
+	        RETURNING id, created_at, updated_at`
+
+	err := r.db.QueryRow(ctx, sql,
+		w.ScheduleID, w.AutomationID, w.Name, w.CronExpression, w.DurationMinutes, w.Timezone, w.IsEnabled,
+	).Scan(&w.ID, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("erro ao criar janela de bloqueio: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresBlackoutWindowRepository) GetByID(ctx context.Context, id int) (*models.BlackoutWindow, error) {
+	sql := `SELECT ` + blackoutWindowSelectColumns + ` FROM blackout_windows WHERE id = $1`
+
+	rows, err := r.db.Query(ctx, sql, id)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar janela de bloqueio por ID: %w", err)
+	}
+	w, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByPos[models.BlackoutWindow])
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar janela de bloqueio por ID: %w", err)
+	}
+	return w, nil
+}
+
+// ListBySchedule devolve as janelas cadastradas no próprio agendamento.
+func (r *PostgresBlackoutWindowRepository) ListBySchedule(ctx context.Context, scheduleID int) ([]models.BlackoutWindow, error) {
+	return r.list(ctx, `WHERE schedule_id = $1`, scheduleID)
+}
+
+// ListByAutomation devolve as janelas cadastradas na automação (valem pra
+// todos os agendamentos dela).
+func (r *PostgresBlackoutWindowRepository) ListByAutomation(ctx context.Context, automationID int) ([]models.BlackoutWindow, error) {
+	return r.list(ctx, `WHERE automation_id = $1`, automationID)
+}
+
+// ListEffective devolve as janelas ATIVAS que valem pro agendamento: as dele
+// mais as da automação dele.
+func (r *PostgresBlackoutWindowRepository) ListEffective(ctx context.Context, scheduleID, automationID int) ([]models.BlackoutWindow, error) {
+	return r.list(ctx, `WHERE is_enabled AND (schedule_id = $1 OR automation_id = $2)`, scheduleID, automationID)
+}
+
+func (r *PostgresBlackoutWindowRepository) list(ctx context.Context, where string, args ...any) ([]models.BlackoutWindow, error) {
+	sql := `SELECT ` + blackoutWindowSelectColumns + ` FROM blackout_windows ` + where + ` ORDER BY id`
+
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar janelas de bloqueio: %w", err)
+	}
+	windows, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.BlackoutWindow])
+	if err != nil {
+		return nil, fmt.Errorf("erro ao processar janelas de bloqueio: %w", err)
+	}
+	return windows, nil
+}
+
+// Update troca nome, expressão, duração, fuso e is_enabled. O alvo
+// (agendamento/automação) não muda — pra isso, apagar e criar de novo.
+func (r *PostgresBlackoutWindowRepository) Update(ctx context.Context, w *models.BlackoutWindow) error {
+	sql := `UPDATE blackout_windows
+	        SET name = $1, cron_expression = $2, duration_minutes = $3, timezone = $4, is_enabled = $5,
+	            updated_at = NOW()
+	        WHERE id = $6
+	        RETURNING schedule_id, automation_id, created_at, updated_at`
+
+	err := r.db.QueryRow(ctx, sql,
+		w.Name, w.CronExpression, w.DurationMinutes, w.Timezone, w.IsEnabled, w.ID,
+	).Scan(&w.ScheduleID, &w.AutomationID, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("erro ao atualizar janela de bloqueio: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresBlackoutWindowRepository) Delete(ctx context.Context, id int) error {
+	sql := `DELETE FROM blackout_windows WHERE id = $1`
+
+	cmdTag, err := r.db.Exec(ctx, sql, id)
+	if err != nil {
+		return fmt.Errorf("erro ao deletar janela de bloqueio: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("nenhuma janela de bloqueio encontrada para deletar com ID %d", id)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrCalendarInUse é devolvido ao deletar um calendário referenciado por algum
+// agendamento (FK ON DELETE RESTRICT).
+var ErrCalendarInUse = errors.New("calendário em uso por agendamento(s)")
+
+const calendarSelectColumns = `id, name, description, include_national, created_at, updated_at`
+
+func (r *PostgresCalendarRepository) Create(ctx context.Context, cal *models.Calendar) error {
+	sql := `INSERT INTO calendars (name, description, include_national)
+	        VALUES ($1, $2, $3)
+	        RETURNING id, created_at, updated_at`
+
+	err := r.db.QueryRow(ctx, sql, cal.Name, cal.Description, cal.IncludeNational).
+		Scan(&cal.ID, &cal.CreatedAt, &cal.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("erro ao criar calendário: %w", err)
+	}
+	return nil
+}
+
+// GetByID devolve o calendário com as datas cadastradas (Holidays).
+func (r *PostgresCalendarRepository) GetByID(ctx context.Context, id int) (*models.Calendar, error) {
+	sql := `SELECT ` + calendarSelectColumns + ` FROM calendars WHERE id = $1`
+
+	rows, err := r.db.Query(ctx, sql, id)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar calendário por ID: %w", err)
+	}
+	cal, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByPos[models.Calendar])
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar calendário por ID: %w", err)
+	}
+
+	// to_char: a data sai como YYYY-MM-DD, sem hora/fuso.
+	hsql := `SELECT id, calendar_id, to_char(date, 'YYYY-MM-DD'), name, recurring, created_at
+	         FROM calendar_holidays
+	         WHERE calendar_id = $1
+	         ORDER BY date`
+	hrows, err := r.db.Query(ctx, hsql, id)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar feriados do calendário: %w", err)
+	}
+	cal.Holidays, err = pgx.CollectRows(hrows, pgx.RowToStructByPos[models.CalendarHoliday])
+	if err != nil {
+		return nil, fmt.Errorf("erro ao processar feriados do calendário: %w", err)
+	}
+	return cal, nil
+}
+
+func (r *PostgresCalendarRepository) GetAll(ctx context.Context) ([]models.Calendar, error) {
+	sql := `SELECT ` + calendarSelectColumns + ` FROM calendars ORDER BY name`
+
+	rows, err := r.db.Query(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar calendários: %w", err)
+	}
+	cals, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Calendar])
+	if err != nil {
+		return nil, fmt.Errorf("erro ao processar calendários: %w", err)
+	}
+	return cals, nil
+}
+
+func (r *PostgresCalendarRepository) Update(ctx context.Context, cal *models.Calendar) error {
+	sql := `UPDATE calendars
+	        SET name = $1, description = $2, include_national = $3, updated_at = NOW()
+	        WHERE id = $4
+	        RETURNING created_at, updated_at`
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("erro ao atualizar calendário: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, sql, cal.Name, cal.Description, cal.IncludeNational, cal.ID).
+		Scan(&cal.CreatedAt, &cal.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("erro ao atualizar calendário: %w", err)
+	}
+	if err := commitCalendarChange(ctx, tx); err != nil {
+		return fmt.Errorf("erro ao atualizar calendário: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresCalendarRepository) Delete(ctx context.Context, id int) error {
+	sql := `DELETE FROM calendars WHERE id = $1`
+
+	cmdTag, err := r.db.Exec(ctx, sql, id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" { // foreign_key_violation
+			return ErrCalendarInUse
+		}
+		return fmt.Errorf("erro ao deletar calendário: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("nenhum calendário encontrado para deletar com ID %d", id)
+	}
+	return nil
+}
+
+// AddHoliday cadastra (ou renomeia, se a data já existir no calendário) uma data.
+func (r *PostgresCalendarRepository) AddHoliday(ctx context.Context, h *models.CalendarHoliday) error {
+	sql := `INSERT INTO calendar_holidays (calendar_id, date, name, recurring)
+	        VALUES ($1, $2::date, $3, $4)
+	        ON CONFLICT (calendar_id, date) DO UPDATE SET name = EXCLUDED.name, recurring = EXCLUDED.recurring
+	        RETURNING id, created_at`
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("erro ao cadastrar feriado: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, sql, h.CalendarID, h.Date, h.Name, h.Recurring).Scan(&h.ID, &h.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("erro ao cadastrar feriado: %w", err)
+	}
+	if err := commitCalendarChange(ctx, tx); err != nil {
+		return fmt.Errorf("erro ao cadastrar feriado: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresCalendarRepository) DeleteHoliday(ctx context.Context, calendarID, holidayID int) error {
+	sql := `DELETE FROM calendar_holidays WHERE id = $1 AND calendar_id = $2`
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("erro ao deletar feriado: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	cmdTag, err := tx.Exec(ctx, sql, holidayID, calendarID)
+	if err != nil {
+		return fmt.Errorf("erro ao deletar feriado: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("nenhum feriado encontrado com ID %d neste calendário", holidayID)
+	}
+	if err := commitCalendarChange(ctx, tx); err != nil {
+		return fmt.Errorf("erro ao deletar feriado: %w", err)
+	}
+	return nil
+}
+
+// commitCalendarChange notifica ScheduleChangesChannel com "*" e comita: os
+// dias úteis e a holidayPolicy ficam embutidos no cron de cada agendamento,
+// então toda réplica refaz o Reload completo (como commitScheduleChange, o
+// NOTIFY só é entregue no commit).
+func commitCalendarChange(ctx context.Context, tx pgx.Tx) error {
+	if _, err := tx.Exec(ctx, `SELECT pg_notify($1, '*')`, ScheduleChangesChannel); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
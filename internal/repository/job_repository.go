@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -9,28 +10,175 @@ import (
 	"github.com/EnzzoHosaki/rps-maestro/internal/models"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+// ErrJobNotScheduled é devolvido ao reagendar um job que não está (mais) em
+// scheduled — já publicado, cancelado ou inexistente.
+var ErrJobNotScheduled = errors.New("job não está agendado (já publicado, cancelado ou inexistente)")
+
 // jobSelectColumns mantém a ordem de colunas alinhada com o struct models.Job.
 // Qualquer SELECT que use pgx.RowToStructByPos[models.Job] precisa usar esta
 // ordem exata.
 const jobSelectColumns = `id, automation_id, user_id, status, parameters, result,
-	retry_count, started_at, completed_at, cancellation_requested_at, last_heartbeat_at, created_at`
+	retry_count, started_at, completed_at, cancellation_requested_at, last_heartbeat_at, created_at,
+	schedule_id, after_job_id, trigger, run_at, held, resource_key, priority, idempotency_key,
+	parent_job_id, fan_out_param, fan_out_item, pipeline_run_id, pipeline_step, retry_of`
 
-func (r *PostgresJobRepository) Create(ctx context.Context, job *models.Job) error {
-	sql := `INSERT INTO jobs (automation_id, user_id, status, parameters)
-	        VALUES ($1, $2, $3, $4)
-	        RETURNING id, created_at`
+// holdIfLimitedSQL decide jobs.held na entrada em pending: retido quando a
+// automação tem limite de concorrência ou de taxa (ver
+// models.Automation.HasDispatchLimit).
+const holdIfLimitedSQL = `EXISTS (
+	    SELECT 1 FROM automations a
+	    WHERE a.id = jobs.automation_id
+	      AND (a.max_concurrency IS NOT NULL OR COALESCE(a.resource_key, '') <> '' OR a.rate_limit IS NOT NULL)
+	)`
+
+// jobInsertSQL cria um job. Job criado em pending de automação com limite
+// (concorrência ou taxa) já nasce retido: o caller confere job.Held e, nesse
+// caso, não publica. A prioridade vem de automations.priorities[trigger] ou do
+// padrão do trigger ($9). Só o pai de um fan-out nasce em running.
+const jobInsertSQL = `INSERT INTO jobs (automation_id, user_id, status, parameters, schedule_id, after_job_id, trigger, run_at,
+	                  held, priority, idempotency_key, parent_job_id, fan_out_param, fan_out_item, started_at,
+	                  pipeline_run_id, pipeline_step, retry_of)
+	        VALUES ($1, $2, $3, $4, $5, $6, $7, $8,
+	                $3 = 'pending' AND EXISTS (
+	                    SELECT 1 FROM automations a
+	                    WHERE a.id = $1
+	                      AND (a.max_concurrency IS NOT NULL OR COALESCE(a.resource_key, '') <> '' OR a.rate_limit IS NOT NULL)
+	                ),
+	                COALESCE((SELECT (a.priorities->>$7::text)::smallint FROM automations a WHERE a.id = $1), $9),
+	                $10, $11, $12, $13, CASE WHEN $3 = 'running' THEN NOW() END,
+	                $14, $15, $16)`
+
+// jobQuerier é o que os helpers de escrita de job usam do pool ou de uma
+// transação.
+type jobQuerier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// insertJob executa jobInsertSQL (com o sufixo dado antes do RETURNING) e
+// preenche os campos calculados pelo banco. ON CONFLICT sem linha devolve
+// pgx.ErrNoRows.
+func insertJob(ctx context.Context, q jobQuerier, job *models.Job, suffix string) error {
+	if job.Trigger == "" {
+		job.Trigger = models.TriggerManual
+	}
+	return q.QueryRow(ctx, jobInsertSQL+suffix+` RETURNING id, created_at, held, priority, started_at`,
+		job.AutomationID, job.UserID, job.Status, job.Parameters, job.ScheduleID, job.AfterJobID, job.Trigger, job.RunAt,
+		models.DefaultPriorities[job.Trigger], job.IdempotencyKey, job.ParentJobID, job.FanOutParam, job.FanOutItem,
+		job.PipelineRunID, job.PipelineStep, job.RetryOf,
+	).Scan(&job.ID, &job.CreatedAt, &job.Held, &job.Priority, &job.StartedAt)
+}
+
+// idempotencyConflict é o alvo do ON CONFLICT das chaves de idempotência —
+// tem que bater com o índice idx_jobs_user_idempotency_key: a chave vale por
+// usuário (user_id NULL é um escopo só).
+const idempotencyConflict = ` ON CONFLICT (user_id, idempotency_key) WHERE idempotency_key IS NOT NULL DO NOTHING`
+
+// expireIdempotencyKey libera a chave do usuário de job se o job que a usa
+// passou do ttl.
+func expireIdempotencyKey(ctx context.Context, q jobQuerier, job *models.Job, ttl time.Duration) error {
+	expire := `UPDATE jobs SET idempotency_key = NULL
+	           WHERE idempotency_key = $1 AND user_id IS NOT DISTINCT FROM $2
+	             AND created_at < NOW() - $3::interval`
+	if _, err := q.Exec(ctx, expire, job.IdempotencyKey, job.UserID, ttl.String()); err != nil {
+		return fmt.Errorf("erro ao liberar chave de idempotência vencida: %w", err)
+	}
+	return nil
+}
 
-	err := r.db.QueryRow(ctx, sql,
-		job.AutomationID, job.UserID, job.Status, job.Parameters,
-	).Scan(&job.ID, &job.CreatedAt)
+// getByIdempotencyKey lê o job que ficou com a chave do usuário de job.
+func getByIdempotencyKey(ctx context.Context, q jobQuerier, job *models.Job) (*models.Job, error) {
+	rows, err := q.Query(ctx, `SELECT `+jobSelectColumns+` FROM jobs
+	                           WHERE idempotency_key = $1 AND user_id IS NOT DISTINCT FROM $2`, job.IdempotencyKey, job.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar job da chave de idempotência: %w", err)
+	}
+	existing, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByPos[models.Job])
 	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar job da chave de idempotência: %w", err)
+	}
+	return existing, nil
+}
+
+func (r *PostgresJobRepository) Create(ctx context.Context, job *models.Job) error {
+	if err := insertJob(ctx, r.db, job, ""); err != nil {
 		return fmt.Errorf("erro ao criar job: %w", err)
 	}
 	return nil
 }
 
+// CreateIdempotent cria o job com job.IdempotencyKey, a menos que um job do
+// mesmo usuário (job.UserID) com a mesma chave tenha sido criado há menos de
+// ttl — aí devolve esse job e false.
+// A chave vencida é liberada antes; a disputa entre requisições simultâneas é
+// resolvida pelo índice único (ON CONFLICT), não por consulta prévia: a
+// perdedora espera a vencedora confirmar e lê o job dela.
+func (r *PostgresJobRepository) CreateIdempotent(ctx context.Context, job *models.Job, ttl time.Duration) (*models.Job, bool, error) {
+	if err := expireIdempotencyKey(ctx, r.db, job, ttl); err != nil {
+		return nil, false, err
+	}
+
+	err := insertJob(ctx, r.db, job, idempotencyConflict)
+	if err == nil {
+		return job, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, fmt.Errorf("erro ao criar job: %w", err)
+	}
+
+	existing, err := getByIdempotencyKey(ctx, r.db, job)
+	if err != nil {
+		return nil, false, err
+	}
+	return existing, false, nil
+}
+
+// CreateFanOut cria, numa transação, o job pai e os filhos apontando pra ele.
+// Com parent.IdempotencyKey vale a mesma regra do CreateIdempotent: chave
+// usada dentro do ttl devolve o pai original e false, sem criar filhos.
+func (r *PostgresJobRepository) CreateFanOut(ctx context.Context, parent *models.Job, children []models.Job, ttl time.Duration) (*models.Job, bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("erro ao criar fan-out: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if parent.IdempotencyKey != nil {
+		if err := expireIdempotencyKey(ctx, tx, parent, ttl); err != nil {
+			return nil, false, err
+		}
+		err := insertJob(ctx, tx, parent, idempotencyConflict)
+		if errors.Is(err, pgx.ErrNoRows) {
+			existing, err := getByIdempotencyKey(ctx, tx, parent)
+			if err != nil {
+				return nil, false, err
+			}
+			return existing, false, nil
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("erro ao criar job pai: %w", err)
+		}
+	} else if err := insertJob(ctx, tx, parent, ""); err != nil {
+		return nil, false, fmt.Errorf("erro ao criar job pai: %w", err)
+	}
+
+	for i := range children {
+		children[i].ParentJobID = &parent.ID
+		if err := insertJob(ctx, tx, &children[i], ""); err != nil {
+			return nil, false, fmt.Errorf("erro ao criar job filho: %w", err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, false, fmt.Errorf("erro ao criar fan-out: %w", err)
+	}
+	parent.Children = children
+	return parent, true, nil
+}
+
 func (r *PostgresJobRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Job, error) {
 	sql := `SELECT ` + jobSelectColumns + ` FROM jobs WHERE id = $1`
 
@@ -39,6 +187,9 @@ func (r *PostgresJobRepository) GetByID(ctx context.Context, id uuid.UUID) (*mod
 		&j.ID, &j.AutomationID, &j.UserID, &j.Status,
 		&j.Parameters, &j.Result, &j.RetryCount,
 		&j.StartedAt, &j.CompletedAt, &j.CancellationRequestedAt, &j.LastHeartbeatAt, &j.CreatedAt,
+		&j.ScheduleID, &j.AfterJobID, &j.Trigger, &j.RunAt, &j.Held, &j.ResourceKey, &j.Priority, &j.IdempotencyKey,
+		&j.ParentJobID, &j.FanOutParam, &j.FanOutItem, &j.PipelineRunID, &j.PipelineStep,
+		&j.RetryOf,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("erro ao buscar job por ID: %w", err)
@@ -132,10 +283,13 @@ func (r *PostgresJobRepository) SetCompleted(ctx context.Context, id uuid.UUID)
 //
 // Quando todos os workers migrarem pro polling, noHeartbeatTimeout pode ser
 // retirado e o threshold fica apenas em heartbeatTimeout.
+//
+// Pai de fan-out nunca entra: está running sem worker nenhum.
 func (r *PostgresJobRepository) GetStuckJobs(ctx context.Context, heartbeatTimeout, noHeartbeatTimeout time.Duration) ([]models.Job, error) {
 	sql := `SELECT ` + jobSelectColumns + `
 	        FROM jobs
 	        WHERE status = 'running'
+	          AND fan_out_param IS NULL
 	          AND (
 	              (last_heartbeat_at IS NOT NULL AND last_heartbeat_at < NOW() - $1::interval)
 	              OR
@@ -154,6 +308,44 @@ func (r *PostgresJobRepository) GetStuckJobs(ctx context.Context, heartbeatTimeo
 	return jobs, nil
 }
 
+// GetOverdueJobs retorna jobs running há mais tempo que o max_duration_minutes
+// da automação. Automação sem limite (NULL) nunca entra, nem pai de fan-out —
+// o limite vale pra cada filho.
+func (r *PostgresJobRepository) GetOverdueJobs(ctx context.Context) ([]models.Job, error) {
+	sql := `SELECT ` + jobSelectColumns + `
+	        FROM jobs
+	        WHERE status = 'running'
+	          AND fan_out_param IS NULL
+	          AND started_at < NOW() - (
+	              SELECT make_interval(mins => a.max_duration_minutes)
+	              FROM automations a WHERE a.id = jobs.automation_id
+	          )`
+
+	rows, err := r.db.Query(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar jobs acima do tempo máximo: %w", err)
+	}
+
+	jobs, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Job])
+	if err != nil {
+		return nil, fmt.Errorf("erro ao processar jobs acima do tempo máximo: %w", err)
+	}
+	return jobs, nil
+}
+
+// FailRunning marca o job como failed com o resultado dado, mas só se ele
+// ainda estiver em running — se o worker finalizou no meio tempo, nada muda e
+// devolve false.
+func (r *PostgresJobRepository) FailRunning(ctx context.Context, id uuid.UUID, result []byte) (bool, error) {
+	sql := `UPDATE jobs SET status = 'failed', result = $1, completed_at = NOW()
+	        WHERE id = $2 AND status = 'running'`
+	cmdTag, err := r.db.Exec(ctx, sql, result, id)
+	if err != nil {
+		return false, fmt.Errorf("erro ao marcar job como failed: %w", err)
+	}
+	return cmdTag.RowsAffected() > 0, nil
+}
+
 // IncrementRetryCount incrementa o contador de tentativas de um job.
 func (r *PostgresJobRepository) IncrementRetryCount(ctx context.Context, id uuid.UUID) error {
 	sql := `UPDATE jobs SET retry_count = retry_count + 1 WHERE id = $1`
@@ -189,6 +381,26 @@ func (r *PostgresJobRepository) List(ctx context.Context, filter models.JobListF
 		args = append(args, *filter.UserID)
 		argIdx++
 	}
+	if filter.ScheduleID != nil {
+		conditions = append(conditions, fmt.Sprintf("schedule_id = $%d", argIdx))
+		args = append(args, *filter.ScheduleID)
+		argIdx++
+	}
+	if filter.Trigger != nil && *filter.Trigger != "" {
+		conditions = append(conditions, fmt.Sprintf("trigger = $%d", argIdx))
+		args = append(args, *filter.Trigger)
+		argIdx++
+	}
+	if filter.Held != nil {
+		conditions = append(conditions, fmt.Sprintf("held = $%d", argIdx))
+		args = append(args, *filter.Held)
+		argIdx++
+	}
+	if filter.ParentJobID != nil {
+		conditions = append(conditions, fmt.Sprintf("parent_job_id = $%d", argIdx))
+		args = append(args, *filter.ParentJobID)
+		argIdx++
+	}
 	if filter.Since != nil {
 		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", argIdx))
 		args = append(args, *filter.Since)
@@ -240,22 +452,26 @@ func (r *PostgresJobRepository) List(ctx context.Context, filter models.JobListF
 }
 
 // RequestCancellation marca cancellation_requested_at e, se o job ainda estiver
-// em pending, já move pra status='canceled' (não vai sair da fila pra worker).
-// Para jobs em running, só sinaliza — o worker decide quando parar.
+// em pending ou scheduled, já move pra status='canceled' (não vai sair da fila
+// pra worker / o dispatcher não vai publicá-lo). Para jobs em running, só
+// sinaliza — o worker decide quando parar.
+//
+// No pai de um fan-out o pedido vale pros filhos em aberto também; o status
+// do pai acompanha os filhos via RefreshParent.
 func (r *PostgresJobRepository) RequestCancellation(ctx context.Context, id uuid.UUID) error {
 	sql := `
 		UPDATE jobs
 		SET cancellation_requested_at = NOW(),
 		    status = CASE
-		        WHEN status = 'pending' THEN 'canceled'
+		        WHEN status IN ('pending', 'scheduled') THEN 'canceled'
 		        ELSE status
 		    END,
 		    completed_at = CASE
-		        WHEN status = 'pending' THEN NOW()
+		        WHEN status IN ('pending', 'scheduled') THEN NOW()
 		        ELSE completed_at
 		    END
-		WHERE id = $1
-		  AND status IN ('pending', 'running')
+		WHERE (id = $1 OR parent_job_id = $1)
+		  AND status IN ('scheduled', 'pending', 'running')
 	`
 	cmdTag, err := r.db.Exec(ctx, sql, id)
 	if err != nil {
@@ -267,6 +483,356 @@ func (r *PostgresJobRepository) RequestCancellation(ctx context.Context, id uuid
 	return nil
 }
 
+// GetActiveBySchedule devolve os jobs pending/running criados pelo agendamento,
+// do mais antigo pro mais novo — base da overlap_policy. Inclui os scheduled:
+// um job que falhou e aguarda o retry da RetryPolicy ainda é a execução
+// anterior.
+func (r *PostgresJobRepository) GetActiveBySchedule(ctx context.Context, scheduleID int) ([]models.Job, error) {
+	sql := `SELECT ` + jobSelectColumns + `
+	        FROM jobs
+	        WHERE schedule_id = $1 AND status IN ('scheduled', 'pending', 'running')
+	        ORDER BY created_at`
+
+	rows, err := r.db.Query(ctx, sql, scheduleID)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar jobs ativos do agendamento: %w", err)
+	}
+	jobs, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Job])
+	if err != nil {
+		return nil, fmt.Errorf("erro ao processar jobs ativos do agendamento: %w", err)
+	}
+	return jobs, nil
+}
+
+// ReleaseHeldJobs libera os jobs retidos (after_job_id) cujo job de referência
+// já terminou: zera after_job_id e devolve os liberados pro caller publicar.
+// O UPDATE ... RETURNING é a reivindicação — duas chamadas concorrentes nunca
+// devolvem o mesmo job. after_job_id NULL por ON DELETE SET NULL não é pego
+// aqui, então referência apagada também conta como "terminou". Referência em
+// scheduled (aguardando retry) ainda não terminou.
+//
+// Num fan-out retido, pai (running) e filhos são liberados juntos; o caller
+// não publica o pai.
+func (r *PostgresJobRepository) ReleaseHeldJobs(ctx context.Context) ([]models.Job, error) {
+	sql := `UPDATE jobs j
+	        SET after_job_id = NULL
+	        WHERE (j.status = 'pending' OR j.fan_out_param IS NOT NULL)
+	          AND j.after_job_id IS NOT NULL
+	          AND NOT EXISTS (
+	              SELECT 1 FROM jobs p
+	              WHERE p.id = j.after_job_id AND p.status IN ('scheduled', 'pending', 'running')
+	          )
+	        RETURNING ` + jobSelectColumns
+
+	rows, err := r.db.Query(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao liberar jobs retidos: %w", err)
+	}
+	jobs, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Job])
+	if err != nil {
+		return nil, fmt.Errorf("erro ao processar jobs liberados: %w", err)
+	}
+	return jobs, nil
+}
+
+// GetScheduleRunStats resume as execuções finalizadas de um agendamento: o
+// último job (status, quando terminou, duração), o último sucesso e quantas
+// falhas seguidas houve desde ele. Agendamento sem execuções volta zerado.
+func (r *PostgresJobRepository) GetScheduleRunStats(ctx context.Context, scheduleID int) (*models.ScheduleRunStats, error) {
+	sql := `
+		WITH finished AS (
+		    SELECT id, status, started_at, completed_at
+		    FROM jobs
+		    WHERE schedule_id = $1
+		      AND completed_at IS NOT NULL
+		      AND status IN ('completed', 'completed_no_invoices', 'partial', 'failed', 'canceled')
+		),
+		last_success AS (
+		    SELECT MAX(completed_at) AS at FROM finished
+		    WHERE status IN ('completed', 'completed_no_invoices')
+		),
+		last_run AS (
+		    SELECT id, status, completed_at,
+		           EXTRACT(EPOCH FROM (completed_at - started_at))::float8 AS duration
+		    FROM finished
+		    ORDER BY completed_at DESC
+		    LIMIT 1
+		)
+		SELECT
+		    (SELECT COUNT(*) FROM finished),
+		    lr.id, lr.status, lr.completed_at, lr.duration,
+		    (SELECT at FROM last_success),
+		    (SELECT COUNT(*) FROM finished f
+		     WHERE f.status = 'failed'
+		       AND f.completed_at > COALESCE((SELECT at FROM last_success), '-infinity'::timestamptz))
+		FROM (SELECT 1) AS one
+		LEFT JOIN last_run lr ON TRUE`
+
+	st := &models.ScheduleRunStats{ScheduleID: scheduleID}
+	err := r.db.QueryRow(ctx, sql, scheduleID).Scan(
+		&st.TotalRuns, &st.LastJobID, &st.LastStatus, &st.LastRunAt, &st.LastDurationS,
+		&st.LastSuccessAt, &st.ConsecutiveFailures,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao resumir execuções do agendamento: %w", err)
+	}
+	return st, nil
+}
+
+// ClaimDueScheduled move pra pending até `limit` jobs scheduled cujo run_at já
+// venceu e os devolve pro caller publicar — os de automação com limite de
+// concorrência voltam retidos (held) e ficam pro dispatcher. O UPDATE ...
+// RETURNING com SKIP LOCKED é a reivindicação: um job vencido nunca é
+// devolvido duas vezes.
+func (r *PostgresJobRepository) ClaimDueScheduled(ctx context.Context, limit int) ([]models.Job, error) {
+	sql := `UPDATE jobs
+	        SET status = 'pending', held = ` + holdIfLimitedSQL + `
+	        WHERE id IN (
+	            SELECT id FROM jobs
+	            WHERE status = 'scheduled' AND run_at <= NOW()
+	            ORDER BY run_at
+	            LIMIT $1
+	            FOR UPDATE SKIP LOCKED
+	        )
+	        RETURNING ` + jobSelectColumns
+
+	rows, err := r.db.Query(ctx, sql, limit)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao reivindicar jobs agendados: %w", err)
+	}
+	jobs, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Job])
+	if err != nil {
+		return nil, fmt.Errorf("erro ao processar jobs agendados: %w", err)
+	}
+	return jobs, nil
+}
+
+// GetHeldJobs devolve os jobs retidos pelo limite de concorrência que já podem
+// sair (sem after_job_id), por prioridade e, empatados, do mais antigo pro
+// mais novo.
+func (r *PostgresJobRepository) GetHeldJobs(ctx context.Context) ([]models.Job, error) {
+	sql := `SELECT ` + jobSelectColumns + `
+	        FROM jobs
+	        WHERE held AND status = 'pending' AND after_job_id IS NULL
+	        ORDER BY priority DESC, created_at, id`
+
+	rows, err := r.db.Query(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar jobs retidos: %w", err)
+	}
+	jobs, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Job])
+	if err != nil {
+		return nil, fmt.Errorf("erro ao processar jobs retidos: %w", err)
+	}
+	return jobs, nil
+}
+
+// GetInFlightLoad devolve os jobs em voo — running ou pending já publicado —
+// que contam pra algum limite: da automação com max_concurrency ou com
+// resource_key resolvida. Pai de fan-out não conta: quem ocupa vaga são os
+// filhos.
+func (r *PostgresJobRepository) GetInFlightLoad(ctx context.Context) ([]models.JobLoad, error) {
+	sql := `SELECT automation_id, resource_key
+	        FROM jobs
+	        WHERE (status = 'running' OR (status = 'pending' AND NOT held AND after_job_id IS NULL))
+	          AND fan_out_param IS NULL
+	          AND (resource_key IS NOT NULL
+	               OR automation_id IN (SELECT id FROM automations WHERE max_concurrency IS NOT NULL))`
+
+	rows, err := r.db.Query(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar jobs em execução: %w", err)
+	}
+	defer rows.Close()
+
+	var load []models.JobLoad
+	for rows.Next() {
+		var l models.JobLoad
+		if err := rows.Scan(&l.AutomationID, &l.ResourceKey); err != nil {
+			return nil, fmt.Errorf("erro ao processar jobs em execução: %w", err)
+		}
+		load = append(load, l)
+	}
+	return load, rows.Err()
+}
+
+// Dispatch tira o job da retenção gravando a chave de recurso resolvida. O
+// UPDATE condicional é a reivindicação: devolve false se o job já saiu (outra
+// rodada, cancelamento).
+func (r *PostgresJobRepository) Dispatch(ctx context.Context, id uuid.UUID, resourceKey *string) (bool, error) {
+	sql := `UPDATE jobs SET held = FALSE, resource_key = $1
+	        WHERE id = $2 AND held AND status = 'pending'`
+	cmdTag, err := r.db.Exec(ctx, sql, resourceKey, id)
+	if err != nil {
+		return false, fmt.Errorf("erro ao liberar job retido: %w", err)
+	}
+	return cmdTag.RowsAffected() > 0, nil
+}
+
+// GetQueuePositions devolve a posição (1 = próximo) de cada job retido da
+// lista na fila da sua automação. Jobs fora da retenção não aparecem no mapa.
+func (r *PostgresJobRepository) GetQueuePositions(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]int, error) {
+	sql := `SELECT id, pos FROM (
+	            SELECT id, ROW_NUMBER() OVER (PARTITION BY automation_id ORDER BY priority DESC, created_at, id)::int AS pos
+	            FROM jobs
+	            WHERE held AND status = 'pending' AND after_job_id IS NULL
+	        ) q
+	        WHERE id = ANY($1)`
+
+	rows, err := r.db.Query(ctx, sql, ids)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao calcular posição na fila: %w", err)
+	}
+	defer rows.Close()
+
+	positions := make(map[uuid.UUID]int)
+	for rows.Next() {
+		var id uuid.UUID
+		var pos int
+		if err := rows.Scan(&id, &pos); err != nil {
+			return nil, fmt.Errorf("erro ao processar posição na fila: %w", err)
+		}
+		positions[id] = pos
+	}
+	return positions, rows.Err()
+}
+
+// Requeue devolve pra pending um job travado que o retry worker vai
+// re-enfileirar, zerando a chave de recurso da execução anterior. Devolve true
+// quando o job voltou retido (automação com limite) — aí quem publica é o
+// dispatcher, não o caller.
+func (r *PostgresJobRepository) Requeue(ctx context.Context, id uuid.UUID) (bool, error) {
+	sql := `UPDATE jobs SET status = 'pending', resource_key = NULL, held = ` + holdIfLimitedSQL + `
+	        WHERE id = $1
+	        RETURNING held`
+	var held bool
+	if err := r.db.QueryRow(ctx, sql, id).Scan(&held); err != nil {
+		return false, fmt.Errorf("erro ao devolver job pra fila: %w", err)
+	}
+	return held, nil
+}
+
+// Reschedule muda o run_at de um job ainda em scheduled. Job já publicado,
+// cancelado ou inexistente devolve ErrJobNotScheduled.
+func (r *PostgresJobRepository) Reschedule(ctx context.Context, id uuid.UUID, runAt time.Time) error {
+	sql := `UPDATE jobs SET run_at = $1 WHERE id = $2 AND status = 'scheduled'`
+	cmdTag, err := r.db.Exec(ctx, sql, runAt, id)
+	if err != nil {
+		return fmt.Errorf("erro ao reagendar job: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return ErrJobNotScheduled
+	}
+	return nil
+}
+
+// ScheduleRetry devolve um job que terminou com falha pra fila adiada: status
+// scheduled com run_at (o dispatcher publica quando chegar), retry_count + 1 e
+// os marcos da execução anterior zerados. result fica com o erro da última
+// tentativa até a próxima terminar. A prioridade cai pra de retry da
+// automação. Devolve o novo retry_count.
+func (r *PostgresJobRepository) ScheduleRetry(ctx context.Context, id uuid.UUID, runAt time.Time) (int, error) {
+	sql := `UPDATE jobs
+	        SET status = 'scheduled', run_at = $1, retry_count = retry_count + 1,
+	            started_at = NULL, completed_at = NULL, last_heartbeat_at = NULL,
+	            priority = COALESCE((SELECT (a.priorities->>'retry')::smallint FROM automations a WHERE a.id = jobs.automation_id), $3)
+	        WHERE id = $2
+	        RETURNING retry_count`
+	var retryCount int
+	if err := r.db.QueryRow(ctx, sql, runAt, id, models.DefaultPriorities[models.TriggerRetry]).Scan(&retryCount); err != nil {
+		return 0, fmt.Errorf("erro ao agendar retry do job: %w", err)
+	}
+	return retryCount, nil
+}
+
+// refreshParentsSQL recalcula o status e o resumo (result.children) dos pais
+// de fan-out selecionados por %s, a partir da última tentativa de cada item:
+// algum em aberto → running; todos com sucesso → completed; todos cancelados
+// → canceled; nenhum sucesso → failed; o resto → partial. Só grava o que
+// mudou.
+const refreshParentsSQL = `
+	WITH latest AS (
+	    SELECT DISTINCT ON (c.parent_job_id, c.fan_out_item) c.parent_job_id, c.status
+	    FROM jobs c
+	    WHERE c.parent_job_id IN (SELECT p.id FROM jobs p WHERE %s)
+	    ORDER BY c.parent_job_id, c.fan_out_item, c.created_at DESC
+	),
+	agg AS (
+	    SELECT parent_job_id,
+	           COUNT(*) FILTER (WHERE status IN ('completed', 'completed_no_invoices')) AS succeeded,
+	           COUNT(*) FILTER (WHERE status = 'failed')                               AS failed,
+	           COUNT(*) FILTER (WHERE status = 'canceled')                             AS canceled,
+	           COUNT(*) FILTER (WHERE status NOT IN ('completed', 'completed_no_invoices', 'failed', 'canceled')) AS open
+	    FROM latest
+	    GROUP BY parent_job_id
+	),
+	next AS (
+	    SELECT parent_job_id, open,
+	           CASE
+	               WHEN open > 0                     THEN 'running'
+	               WHEN failed = 0 AND canceled = 0  THEN 'completed'
+	               WHEN succeeded = 0 AND failed = 0 THEN 'canceled'
+	               WHEN succeeded = 0                THEN 'failed'
+	               ELSE 'partial'
+	           END AS status,
+	           jsonb_build_object('children', jsonb_build_object(
+	               'total', succeeded + failed + canceled + open,
+	               'completed', succeeded, 'failed', failed, 'canceled', canceled, 'open', open
+	           )) AS summary
+	    FROM agg
+	)
+	UPDATE jobs p
+	SET status = n.status,
+	    result = n.summary,
+	    completed_at = CASE
+	        WHEN n.open > 0 THEN NULL
+	        WHEN p.status = n.status THEN p.completed_at
+	        ELSE NOW()
+	    END
+	FROM next n
+	WHERE p.id = n.parent_job_id
+	  AND (p.status <> n.status OR p.result IS DISTINCT FROM n.summary)`
+
+// RefreshParent recalcula o status agregado do pai de fan-out. Chamado quando
+// um filho termina, é cancelado ou ganha uma nova tentativa — um pai já
+// finalizado volta pra running se um filho for retentado.
+func (r *PostgresJobRepository) RefreshParent(ctx context.Context, parentID uuid.UUID) error {
+	if _, err := r.db.Exec(ctx, fmt.Sprintf(refreshParentsSQL, "p.id = $1"), parentID); err != nil {
+		return fmt.Errorf("erro ao agregar status do job pai: %w", err)
+	}
+	return nil
+}
+
+// RefreshOpenParents recalcula todos os pais em running e devolve quantos
+// mudaram. Cobre os caminhos que finalizam filhos sem passar pela API
+// (timeout, falha de publicação, retry worker).
+func (r *PostgresJobRepository) RefreshOpenParents(ctx context.Context) (int, error) {
+	cmdTag, err := r.db.Exec(ctx, fmt.Sprintf(refreshParentsSQL, "p.fan_out_param IS NOT NULL AND p.status = 'running'"))
+	if err != nil {
+		return 0, fmt.Errorf("erro ao agregar status dos jobs pai: %w", err)
+	}
+	return int(cmdTag.RowsAffected()), nil
+}
+
+// ListChildren devolve os filhos do pai, todas as tentativas, por item e da
+// mais antiga pra mais nova.
+func (r *PostgresJobRepository) ListChildren(ctx context.Context, parentID uuid.UUID) ([]models.Job, error) {
+	sql := `SELECT ` + jobSelectColumns + `
+	        FROM jobs
+	        WHERE parent_job_id = $1
+	        ORDER BY fan_out_item, created_at`
+
+	rows, err := r.db.Query(ctx, sql, parentID)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar jobs filhos: %w", err)
+	}
+	jobs, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Job])
+	if err != nil {
+		return nil, fmt.Errorf("erro ao processar jobs filhos: %w", err)
+	}
+	return jobs, nil
+}
+
 // IsCancellationRequested informa ao worker se o usuário pediu cancelamento.
 func (r *PostgresJobRepository) IsCancellationRequested(ctx context.Context, id uuid.UUID) (bool, error) {
 	sql := `SELECT cancellation_requested_at IS NOT NULL FROM jobs WHERE id = $1`
@@ -280,9 +846,10 @@ func (r *PostgresJobRepository) IsCancellationRequested(ctx context.Context, id
 // GetLastParamsForUser retorna os parâmetros do job mais recente que o usuário
 // executou para essa automação. Retorna (nil, nil) se o usuário nunca executou
 // — assim o handler pode devolver `parameters: null` sem precisar de 404.
+// Filho de fan-out não conta: os parâmetros dele têm a lista reduzida a um item.
 func (r *PostgresJobRepository) GetLastParamsForUser(ctx context.Context, automationID, userID int) ([]byte, error) {
 	sql := `SELECT parameters FROM jobs
-	        WHERE automation_id = $1 AND user_id = $2
+	        WHERE automation_id = $1 AND user_id = $2 AND parent_job_id IS NULL
 	        ORDER BY created_at DESC
 	        LIMIT 1`
 
@@ -310,6 +877,8 @@ const dashboardTZ = "America/Sao_Paulo"
 // usuário. Os campos *Last24h do modelo mantêm o nome por compatibilidade de
 // JSON, mas refletem o intervalo pedido. running/pending/completedToday são
 // independentes do intervalo (snapshot atual / dia corrente em dashboardTZ).
+// Pais de fan-out ficam de fora de todas as agregações do dashboard: cada
+// filho já conta como uma execução.
 func (r *PostgresJobRepository) GetMetrics(ctx context.Context, interval string) (*models.JobMetrics, error) {
 	sql := `
 		SELECT
@@ -330,6 +899,7 @@ func (r *PostgresJobRepository) GetMetrics(ctx context.Context, interval string)
 		          AND completed_at >= NOW() - $1::interval
 		    )                                                                               AS succeeded_period
 		FROM jobs
+		WHERE fan_out_param IS NULL
 	`
 
 	var (
@@ -387,7 +957,7 @@ func (r *PostgresJobRepository) GetJobsSeries(ctx context.Context, bucket string
 		    date_trunc($1, NOW(), $4),
 		    $3::interval
 		) AS h(bucket)
-		LEFT JOIN jobs j ON date_trunc($1, j.completed_at, $4) = h.bucket
+		LEFT JOIN jobs j ON date_trunc($1, j.completed_at, $4) = h.bucket AND j.fan_out_param IS NULL
 		GROUP BY h.bucket
 		ORDER BY h.bucket
 	`
@@ -428,8 +998,10 @@ func (r *PostgresJobRepository) GetAutomationHealth(ctx context.Context, interva
 		    COUNT(j.id) FILTER (WHERE j.status IN ('completed','completed_no_invoices')) AS succeeded,
 		    COUNT(j.id) FILTER (WHERE j.status = 'failed')                       AS failed,
 		    COUNT(j.id) FILTER (WHERE j.status = 'canceled')                     AS canceled,
-		    COUNT(j.id) FILTER (WHERE j.user_id IS NOT NULL)                     AS manual,
-		    COUNT(j.id) FILTER (WHERE j.user_id IS NULL)                         AS scheduled,
+		    COUNT(j.id) FILTER (WHERE j.trigger = 'manual')                      AS manual,
+		    COUNT(j.id) FILTER (WHERE j.trigger = 'schedule')                    AS scheduled,
+		    COUNT(j.id) FILTER (WHERE j.trigger = 'retry')                       AS retried,
+		    COUNT(j.id) FILTER (WHERE j.trigger = 'api')                         AS api,
 		    percentile_cont(0.5) WITHIN GROUP (
 		        ORDER BY EXTRACT(EPOCH FROM (j.completed_at - j.started_at))
 		    ) FILTER (WHERE j.started_at IS NOT NULL)                            AS p50,
@@ -441,6 +1013,7 @@ func (r *PostgresJobRepository) GetAutomationHealth(ctx context.Context, interva
 		    ON j.automation_id = a.id
 		   AND j.status IN ` + finished + `
 		   AND j.completed_at >= NOW() - $1::interval
+		   AND j.fan_out_param IS NULL
 		GROUP BY a.id, a.name
 		ORDER BY a.name`
 
@@ -456,7 +1029,7 @@ func (r *PostgresJobRepository) GetAutomationHealth(ctx context.Context, interva
 		var h models.AutomationHealth
 		if err := rows.Scan(
 			&h.AutomationID, &h.Name, &h.Total, &h.Succeeded, &h.Failed,
-			&h.Canceled, &h.Manual, &h.Scheduled, &h.DurationP50S, &h.DurationP95S,
+			&h.Canceled, &h.Manual, &h.Scheduled, &h.Retried, &h.API, &h.DurationP50S, &h.DurationP95S,
 		); err != nil {
 			return nil, fmt.Errorf("erro ao escanear saúde por automação: %w", err)
 		}
@@ -482,7 +1055,7 @@ func (r *PostgresJobRepository) GetAutomationHealth(ctx context.Context, interva
 		    SELECT automation_id, status, completed_at,
 		           row_number() OVER (PARTITION BY automation_id ORDER BY completed_at DESC) AS rn
 		    FROM jobs
-		    WHERE completed_at IS NOT NULL AND status IN ` + finished + `
+		    WHERE completed_at IS NOT NULL AND status IN ` + finished + ` AND fan_out_param IS NULL
 		) t
 		WHERE rn <= $1
 		ORDER BY automation_id, completed_at DESC`
@@ -529,6 +1102,7 @@ func (r *PostgresJobRepository) GetErrorClassDistribution(ctx context.Context, i
 		    COUNT(*) AS count
 		FROM jobs
 		WHERE status = 'failed'
+		  AND fan_out_param IS NULL
 		  AND completed_at >= NOW() - $1::interval
 		GROUP BY 1
 		ORDER BY count DESC, error_class`
@@ -552,3 +1126,38 @@ func (r *PostgresJobRepository) GetErrorClassDistribution(ctx context.Context, i
 	}
 	return out, nil
 }
+
+// GetLineage devolve a árvore de reexecuções (retry_of) que contém o job:
+// sobe até a primeira tentativa e desce por todas as reexecuções dela, em
+// ordem de criação. O primeiro job é a raiz.
+func (r *PostgresJobRepository) GetLineage(ctx context.Context, id uuid.UUID) ([]models.Job, error) {
+	sql := `WITH RECURSIVE up AS (
+	            SELECT id, retry_of, 0 AS depth FROM jobs WHERE id = $1
+	            UNION ALL
+	            SELECT j.id, j.retry_of, up.depth + 1
+	            FROM jobs j JOIN up ON j.id = up.retry_of
+	            WHERE up.depth < 1000
+	        ), root AS (
+	            SELECT id FROM up ORDER BY depth DESC LIMIT 1
+	        ), down AS (
+	            SELECT id, 0 AS depth FROM root
+	            UNION ALL
+	            SELECT j.id, down.depth + 1
+	            FROM jobs j JOIN down ON j.retry_of = down.id
+	            WHERE down.depth < 1000
+	        )
+	        SELECT ` + jobSelectColumns + `
+	        FROM jobs
+	        WHERE id IN (SELECT id FROM down)
+	        ORDER BY created_at`
+
+	rows, err := r.db.Query(ctx, sql, id)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar linhagem do job: %w", err)
+	}
+	jobs, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Job])
+	if err != nil {
+		return nil, fmt.Errorf("erro ao processar linhagem do job: %w", err)
+	}
+	return jobs, nil
+}
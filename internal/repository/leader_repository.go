@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// TryAcquire tenta pegar (ou renovar) o lease `name` para `holder` por `ttl`.
+// Numa única instrução: cria o lease se não existe; renova se o holder já é o
+// dono; toma se o lease do outro já expirou. Em qualquer outro caso o WHERE do
+// ON CONFLICT barra o update, nada volta do RETURNING e o resultado é false.
+//
+// acquired_at só muda quando o dono troca — renovação mantém o instante em que
+// a liderança começou. Os instantes vêm do relógio do Postgres, então réplicas
+// com relógios diferentes concordam sobre a expiração.
+func (r *PostgresLeaderLeaseRepository) TryAcquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	sql := `INSERT INTO leader_leases (name, holder, acquired_at, renewed_at, expires_at)
+	        VALUES ($1, $2, NOW(), NOW(), NOW() + $3::interval)
+	        ON CONFLICT (name) DO UPDATE
+	        SET holder      = EXCLUDED.holder,
+	            acquired_at = CASE WHEN leader_leases.holder = EXCLUDED.holder
+	                               THEN leader_leases.acquired_at ELSE NOW() END,
+	            renewed_at  = NOW(),
+	            expires_at  = EXCLUDED.expires_at
+	        WHERE leader_leases.holder = EXCLUDED.holder
+	           OR leader_leases.expires_at < NOW()
+	        RETURNING holder`
+
+	var got string
+	err := r.db.QueryRow(ctx, sql, name, holder, ttl.String()).Scan(&got)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("erro ao disputar lease de liderança: %w", err)
+	}
+	return got == holder, nil
+}
+
+// Release devolve o lease no shutdown gracioso, pra um standby assumir na
+// próxima tentativa em vez de esperar o TTL expirar. Só apaga se o holder ainda
+// for o dono — nunca derruba o lease de outra réplica.
+func (r *PostgresLeaderLeaseRepository) Release(ctx context.Context, name, holder string) error {
+	sql := `DELETE FROM leader_leases WHERE name = $1 AND holder = $2`
+	if _, err := r.db.Exec(ctx, sql, name, holder); err != nil {
+		return fmt.Errorf("erro ao liberar lease de liderança: %w", err)
+	}
+	return nil
+}
+
+// Get devolve o lease atual (expirado ou não). Retorna (nil, nil) se ninguém
+// nunca pegou o lease.
+func (r *PostgresLeaderLeaseRepository) Get(ctx context.Context, name string) (*models.LeaderLease, error) {
+	sql := `SELECT name, holder, acquired_at, renewed_at, expires_at
+	        FROM leader_leases WHERE name = $1`
+
+	l := &models.LeaderLease{}
+	err := r.db.QueryRow(ctx, sql, name).Scan(&l.Name, &l.Holder, &l.AcquiredAt, &l.RenewedAt, &l.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("erro ao buscar lease de liderança: %w", err)
+	}
+	return l, nil
+}
@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrPipelineRunNotRunning é devolvido ao cancelar uma execução que já
+// terminou (ou não existe).
+var ErrPipelineRunNotRunning = errors.New("execução do pipeline não está em andamento")
+
+const pipelineSelectColumns = `id, name, description, steps, edges, created_at, updated_at`
+
+const pipelineRunSelectColumns = `id, pipeline_id, user_id, status, parameters, created_at, completed_at`
+
+func (r *PostgresPipelineRepository) Create(ctx context.Context, p *models.Pipeline) error {
+	sql := `INSERT INTO pipelines (name, description, steps, edges)
+	        VALUES ($1, $2, $3, $4)
+	        RETURNING id, created_at, updated_at`
+
+	err := r.db.QueryRow(ctx, sql, p.Name, p.Description, p.Steps, p.Edges).
+		Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("erro ao criar pipeline: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresPipelineRepository) GetByID(ctx context.Context, id int) (*models.Pipeline, error) {
+	sql := `SELECT ` + pipelineSelectColumns + ` FROM pipelines WHERE id = $1`
+
+	rows, err := r.db.Query(ctx, sql, id)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar pipeline por ID: %w", err)
+	}
+	p, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByPos[models.Pipeline])
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar pipeline por ID: %w", err)
+	}
+	return p, nil
+}
+
+func (r *PostgresPipelineRepository) GetAll(ctx context.Context) ([]models.Pipeline, error) {
+	sql := `SELECT ` + pipelineSelectColumns + ` FROM pipelines ORDER BY name`
+
+	rows, err := r.db.Query(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar pipelines: %w", err)
+	}
+	pipelines, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Pipeline])
+	if err != nil {
+		return nil, fmt.Errorf("erro ao processar pipelines: %w", err)
+	}
+	return pipelines, nil
+}
+
+// Update troca a definição. Execuções em andamento passam a seguir a nova
+// definição no próximo avanço.
+func (r *PostgresPipelineRepository) Update(ctx context.Context, p *models.Pipeline) error {
+	sql := `UPDATE pipelines
+	        SET name = $1, description = $2, steps = $3, edges = $4, updated_at = NOW()
+	        WHERE id = $5
+	        RETURNING created_at, updated_at`
+
+	err := r.db.QueryRow(ctx, sql, p.Name, p.Description, p.Steps, p.Edges, p.ID).
+		Scan(&p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("erro ao atualizar pipeline: %w", err)
+	}
+	return nil
+}
+
+// Delete remove o pipeline e o histórico de execuções; os jobs dos passos
+// ficam, sem o vínculo (FK ON DELETE SET NULL).
+func (r *PostgresPipelineRepository) Delete(ctx context.Context, id int) error {
+	sql := `DELETE FROM pipelines WHERE id = $1`
+
+	cmdTag, err := r.db.Exec(ctx, sql, id)
+	if err != nil {
+		return fmt.Errorf("erro ao deletar pipeline: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("nenhum pipeline encontrado para deletar com ID %d", id)
+	}
+	return nil
+}
+
+// CreateRun cria, numa transação, a execução e os jobs dos passos iniciais
+// apontando pra ela. Os jobs voltam com ID, held e prioridade preenchidos.
+func (r *PostgresPipelineRepository) CreateRun(ctx context.Context, run *models.PipelineRun, jobs []models.Job) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("erro ao criar execução do pipeline: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	sql := `INSERT INTO pipeline_runs (pipeline_id, user_id, parameters)
+	        VALUES ($1, $2, $3)
+	        RETURNING id, status, created_at`
+	if err := tx.QueryRow(ctx, sql, run.PipelineID, run.UserID, run.Parameters).
+		Scan(&run.ID, &run.Status, &run.CreatedAt); err != nil {
+		return fmt.Errorf("erro ao criar execução do pipeline: %w", err)
+	}
+
+	for i := range jobs {
+		jobs[i].PipelineRunID = &run.ID
+		if err := insertJob(ctx, tx, &jobs[i], ""); err != nil {
+			return fmt.Errorf("erro ao criar job do passo: %w", err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("erro ao criar execução do pipeline: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresPipelineRepository) GetRun(ctx context.Context, id uuid.UUID) (*models.PipelineRun, error) {
+	sql := `SELECT ` + pipelineRunSelectColumns + ` FROM pipeline_runs WHERE id = $1`
+
+	rows, err := r.db.Query(ctx, sql, id)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar execução do pipeline: %w", err)
+	}
+	run, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByPos[models.PipelineRun])
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar execução do pipeline: %w", err)
+	}
+	return run, nil
+}
+
+// ListRuns devolve as últimas execuções do pipeline, mais recentes primeiro.
+func (r *PostgresPipelineRepository) ListRuns(ctx context.Context, pipelineID, limit int) ([]models.PipelineRun, error) {
+	sql := `SELECT ` + pipelineRunSelectColumns + `
+	        FROM pipeline_runs
+	        WHERE pipeline_id = $1
+	        ORDER BY created_at DESC
+	        LIMIT $2`
+
+	rows, err := r.db.Query(ctx, sql, pipelineID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar execuções do pipeline: %w", err)
+	}
+	runs, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.PipelineRun])
+	if err != nil {
+		return nil, fmt.Errorf("erro ao processar execuções do pipeline: %w", err)
+	}
+	return runs, nil
+}
+
+// ListRunJobs devolve os jobs da execução em ordem de criação — o último de
+// cada passo é o que vale (retry de um passo cria outro job no mesmo passo).
+func (r *PostgresPipelineRepository) ListRunJobs(ctx context.Context, runID uuid.UUID) ([]models.Job, error) {
+	sql := `SELECT ` + jobSelectColumns + `
+	        FROM jobs
+	        WHERE pipeline_run_id = $1
+	        ORDER BY created_at`
+
+	rows, err := r.db.Query(ctx, sql, runID)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar jobs da execução do pipeline: %w", err)
+	}
+	jobs, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Job])
+	if err != nil {
+		return nil, fmt.Errorf("erro ao processar jobs da execução do pipeline: %w", err)
+	}
+	return jobs, nil
+}
+
+// GetOpenRuns devolve as execuções que o scheduler precisa avançar: as em
+// running e as terminadas (menos as canceladas) com job de passo em aberto —
+// retry de um passo reabre a execução.
+func (r *PostgresPipelineRepository) GetOpenRuns(ctx context.Context) ([]models.PipelineRun, error) {
+	sql := `SELECT ` + pipelineRunSelectColumns + `
+	        FROM pipeline_runs pr
+	        WHERE pr.status = 'running'
+	           OR (pr.status <> 'canceled' AND EXISTS (
+	                SELECT 1 FROM jobs j
+	                WHERE j.pipeline_run_id = pr.id
+	                  AND j.status IN ('scheduled', 'pending', 'running')
+	           ))
+	        ORDER BY pr.created_at`
+
+	rows, err := r.db.Query(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar execuções de pipeline em aberto: %w", err)
+	}
+	runs, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.PipelineRun])
+	if err != nil {
+		return nil, fmt.Errorf("erro ao processar execuções de pipeline em aberto: %w", err)
+	}
+	return runs, nil
+}
+
+// UpdateRunStatus grava o status calculado da execução. Execução cancelada
+// não muda mais.
+func (r *PostgresPipelineRepository) UpdateRunStatus(ctx context.Context, id uuid.UUID, status string) error {
+	sql := `UPDATE pipeline_runs
+	        SET status = $2,
+	            completed_at = CASE WHEN $2 = 'running' THEN NULL ELSE NOW() END
+	        WHERE id = $1 AND status <> 'canceled' AND status <> $2`
+
+	if _, err := r.db.Exec(ctx, sql, id, status); err != nil {
+		return fmt.Errorf("erro ao atualizar status da execução do pipeline: %w", err)
+	}
+	return nil
+}
+
+// CancelRun marca a execução em andamento como cancelada; os jobs dos passos
+// em aberto são cancelados pelo caller.
+func (r *PostgresPipelineRepository) CancelRun(ctx context.Context, id uuid.UUID) error {
+	sql := `UPDATE pipeline_runs
+	        SET status = 'canceled', completed_at = NOW()
+	        WHERE id = $1 AND status = 'running'`
+
+	cmdTag, err := r.db.Exec(ctx, sql, id)
+	if err != nil {
+		return fmt.Errorf("erro ao cancelar execução do pipeline: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return ErrPipelineRunNotRunning
+	}
+	return nil
+}
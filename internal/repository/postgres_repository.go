@@ -48,6 +48,41 @@ type PostgresScheduleRepository struct {
 
 var _ ScheduleRepository = (*PostgresScheduleRepository)(nil)
 
+// Schedule Event Repository
+type PostgresScheduleEventRepository struct {
+	baseRepository
+}
+
+var _ ScheduleEventRepository = (*PostgresScheduleEventRepository)(nil)
+
+// Calendar Repository
+type PostgresCalendarRepository struct {
+	baseRepository
+}
+
+var _ CalendarRepository = (*PostgresCalendarRepository)(nil)
+
+// Blackout Window Repository
+type PostgresBlackoutWindowRepository struct {
+	baseRepository
+}
+
+var _ BlackoutWindowRepository = (*PostgresBlackoutWindowRepository)(nil)
+
+// Leader Lease Repository
+type PostgresLeaderLeaseRepository struct {
+	baseRepository
+}
+
+var _ LeaderLeaseRepository = (*PostgresLeaderLeaseRepository)(nil)
+
+// Pipeline Repository
+type PostgresPipelineRepository struct {
+	baseRepository
+}
+
+var _ PipelineRepository = (*PostgresPipelineRepository)(nil)
+
 // Holder de conexão para todos os repositórios
 type PostgresConnection struct {
 	db *pgxpool.Pool
@@ -107,6 +142,36 @@ func (pc *PostgresConnection) GetScheduleRepository() ScheduleRepository {
 	}
 }
 
+func (pc *PostgresConnection) GetScheduleEventRepository() ScheduleEventRepository {
+	return &PostgresScheduleEventRepository{
+		baseRepository: baseRepository{db: pc.db},
+	}
+}
+
+func (pc *PostgresConnection) GetCalendarRepository() CalendarRepository {
+	return &PostgresCalendarRepository{
+		baseRepository: baseRepository{db: pc.db},
+	}
+}
+
+func (pc *PostgresConnection) GetBlackoutWindowRepository() BlackoutWindowRepository {
+	return &PostgresBlackoutWindowRepository{
+		baseRepository: baseRepository{db: pc.db},
+	}
+}
+
+func (pc *PostgresConnection) GetLeaderLeaseRepository() LeaderLeaseRepository {
+	return &PostgresLeaderLeaseRepository{
+		baseRepository: baseRepository{db: pc.db},
+	}
+}
+
+func (pc *PostgresConnection) GetPipelineRepository() PipelineRepository {
+	return &PostgresPipelineRepository{
+		baseRepository: baseRepository{db: pc.db},
+	}
+}
+
 func (pc *PostgresConnection) Close() {
 	if pc.db != nil {
 		pc.db.Close()
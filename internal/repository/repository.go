@@ -26,6 +26,8 @@ type AutomationRepository interface {
 	GetAll(ctx context.Context) ([]models.Automation, error)
 	Update(ctx context.Context, automation *models.Automation) error
 	Delete(ctx context.Context, id int) error
+	GetRateBucket(ctx context.Context, automationID int) (*models.RateBucket, error)
+	SaveRateBucket(ctx context.Context, b *models.RateBucket) error
 }
 
 type JobRepository interface {
@@ -36,6 +38,19 @@ type JobRepository interface {
 	SetStarted(ctx context.Context, id uuid.UUID) error
 	SetCompleted(ctx context.Context, id uuid.UUID) error
 	GetStuckJobs(ctx context.Context, heartbeatTimeout, noHeartbeatTimeout time.Duration) ([]models.Job, error)
+	GetOverdueJobs(ctx context.Context) ([]models.Job, error)
+	FailRunning(ctx context.Context, id uuid.UUID, result []byte) (bool, error)
+	GetHeldJobs(ctx context.Context) ([]models.Job, error)
+	GetInFlightLoad(ctx context.Context) ([]models.JobLoad, error)
+	Dispatch(ctx context.Context, id uuid.UUID, resourceKey *string) (bool, error)
+	GetQueuePositions(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]int, error)
+	Requeue(ctx context.Context, id uuid.UUID) (bool, error)
+	CreateIdempotent(ctx context.Context, job *models.Job, ttl time.Duration) (*models.Job, bool, error)
+	CreateFanOut(ctx context.Context, parent *models.Job, children []models.Job, ttl time.Duration) (*models.Job, bool, error)
+	RefreshParent(ctx context.Context, parentID uuid.UUID) error
+	RefreshOpenParents(ctx context.Context) (int, error)
+	ListChildren(ctx context.Context, parentID uuid.UUID) ([]models.Job, error)
+	GetLineage(ctx context.Context, id uuid.UUID) ([]models.Job, error)
 	IncrementRetryCount(ctx context.Context, id uuid.UUID) error
 	UpdateHeartbeat(ctx context.Context, id uuid.UUID) error
 	List(ctx context.Context, filter models.JobListFilter) ([]models.Job, int, error)
@@ -46,6 +61,12 @@ type JobRepository interface {
 	GetAutomationHealth(ctx context.Context, interval string, recentN int) ([]models.AutomationHealth, error)
 	GetErrorClassDistribution(ctx context.Context, interval string) ([]models.ErrorClassCount, error)
 	GetLastParamsForUser(ctx context.Context, automationID, userID int) ([]byte, error)
+	GetActiveBySchedule(ctx context.Context, scheduleID int) ([]models.Job, error)
+	ReleaseHeldJobs(ctx context.Context) ([]models.Job, error)
+	GetScheduleRunStats(ctx context.Context, scheduleID int) (*models.ScheduleRunStats, error)
+	ClaimDueScheduled(ctx context.Context, limit int) ([]models.Job, error)
+	Reschedule(ctx context.Context, id uuid.UUID, runAt time.Time) error
+	ScheduleRetry(ctx context.Context, id uuid.UUID, runAt time.Time) (int, error)
 }
 
 type JobLogRepository interface {
@@ -58,7 +79,57 @@ type ScheduleRepository interface {
 	Create(ctx context.Context, schedule *models.Schedule) error
 	GetByID(ctx context.Context, id int) (*models.Schedule, error)
 	GetAllEnabled(ctx context.Context) ([]models.Schedule, error)
+	List(ctx context.Context, filter models.ScheduleListFilter) ([]models.Schedule, error)
 	UpdateNextRun(ctx context.Context, id int, nextRun *time.Time) error
 	Update(ctx context.Context, schedule *models.Schedule) error
+	SetPause(ctx context.Context, id int, until *time.Time, reason *string) error
+	SetEnabled(ctx context.Context, id int, enabled bool) error
 	Delete(ctx context.Context, id int) error
+	ListenChanges(ctx context.Context) (<-chan int, error)
+}
+
+type ScheduleEventRepository interface {
+	Create(ctx context.Context, event *models.ScheduleEvent) error
+	ListBySchedule(ctx context.Context, scheduleID int, limit int) ([]models.ScheduleEvent, error)
+}
+
+type CalendarRepository interface {
+	Create(ctx context.Context, cal *models.Calendar) error
+	GetByID(ctx context.Context, id int) (*models.Calendar, error)
+	GetAll(ctx context.Context) ([]models.Calendar, error)
+	Update(ctx context.Context, cal *models.Calendar) error
+	Delete(ctx context.Context, id int) error
+	AddHoliday(ctx context.Context, holiday *models.CalendarHoliday) error
+	DeleteHoliday(ctx context.Context, calendarID, holidayID int) error
+}
+
+type BlackoutWindowRepository interface {
+	Create(ctx context.Context, w *models.BlackoutWindow) error
+	GetByID(ctx context.Context, id int) (*models.BlackoutWindow, error)
+	ListBySchedule(ctx context.Context, scheduleID int) ([]models.BlackoutWindow, error)
+	ListByAutomation(ctx context.Context, automationID int) ([]models.BlackoutWindow, error)
+	ListEffective(ctx context.Context, scheduleID, automationID int) ([]models.BlackoutWindow, error)
+	Update(ctx context.Context, w *models.BlackoutWindow) error
+	Delete(ctx context.Context, id int) error
+}
+
+type PipelineRepository interface {
+	Create(ctx context.Context, p *models.Pipeline) error
+	GetByID(ctx context.Context, id int) (*models.Pipeline, error)
+	GetAll(ctx context.Context) ([]models.Pipeline, error)
+	Update(ctx context.Context, p *models.Pipeline) error
+	Delete(ctx context.Context, id int) error
+	CreateRun(ctx context.Context, run *models.PipelineRun, jobs []models.Job) error
+	GetRun(ctx context.Context, id uuid.UUID) (*models.PipelineRun, error)
+	ListRuns(ctx context.Context, pipelineID, limit int) ([]models.PipelineRun, error)
+	ListRunJobs(ctx context.Context, runID uuid.UUID) ([]models.Job, error)
+	GetOpenRuns(ctx context.Context) ([]models.PipelineRun, error)
+	UpdateRunStatus(ctx context.Context, id uuid.UUID, status string) error
+	CancelRun(ctx context.Context, id uuid.UUID) error
+}
+
+type LeaderLeaseRepository interface {
+	TryAcquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+	Release(ctx context.Context, name, holder string) error
+	Get(ctx context.Context, name string) (*models.LeaderLease, error)
 }
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+func (r *PostgresScheduleEventRepository) Create(ctx context.Context, event *models.ScheduleEvent) error {
+	sql := `INSERT INTO schedule_events (schedule_id, scheduled_for, kind, reason, job_id)
+	        VALUES ($1, $2, $3, $4, $5)
+	        RETURNING id, created_at`
+
+	err := r.db.QueryRow(ctx, sql,
+		event.ScheduleID, event.ScheduledFor, event.Kind, event.Reason, event.JobID,
+	).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("erro ao registrar evento do agendamento: %w", err)
+	}
+	return nil
+}
+
+// ListBySchedule devolve os eventos mais recentes do agendamento primeiro.
+func (r *PostgresScheduleEventRepository) ListBySchedule(ctx context.Context, scheduleID int, limit int) ([]models.ScheduleEvent, error) {
+	sql := `SELECT id, schedule_id, scheduled_for, kind, reason, job_id, created_at
+	        FROM schedule_events
+	        WHERE schedule_id = $1
+	        ORDER BY created_at DESC, id DESC
+	        LIMIT $2`
+
+	rows, err := r.db.Query(ctx, sql, scheduleID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao listar eventos do agendamento: %w", err)
+	}
+	events, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.ScheduleEvent])
+	if err != nil {
+		return nil, fmt.Errorf("erro ao processar eventos do agendamento: %w", err)
+	}
+	return events, nil
+}
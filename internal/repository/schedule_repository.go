@@ -3,47 +3,87 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
+
 	"github.com/EnzzoHosaki/rps-maestro/internal/models"
 	"github.com/jackc/pgx/v5"
 )
 
+// ScheduleChangesChannel é o canal NOTIFY das mudanças de agendamento, escrito
+// por Create/Update/Delete (e com "*" pelas escritas de calendário) e escutado
+// por todas as réplicas (ListenChanges). O payload é o ID do agendamento
+// alterado, ou "*" pra todos — útil depois de editar o banco na mão:
+// SELECT pg_notify('schedule_changes', '*').
+const ScheduleChangesChannel = "schedule_changes"
+
+// ErrScheduleNotFound é devolvido por GetByID quando o agendamento não existe.
+var ErrScheduleNotFound = errors.New("agendamento não encontrado")
+
+// scheduleSelectColumns mantém a ordem de colunas alinhada com o struct
+// models.Schedule (pgx.RowToStructByPos depende da ordem exata).
+const scheduleSelectColumns = `id, automation_id, cron_expression,
+	kind, interval_anchor, interval_minutes, active_from, active_to, timezone, parameters, next_run_at,
+	is_enabled, misfire_policy, misfire_max_lookback_minutes, overlap_policy, calendar_id, holiday_policy,
+	fan_out_param, paused_until, pause_reason, created_at, updated_at`
+
 func (r *PostgresScheduleRepository) Create(ctx context.Context, schedule *models.Schedule) error {
-	sql := `INSERT INTO schedules (automation_id, cron_expression, parameters, next_run_at, is_enabled)
-	        VALUES ($1, $2, $3, $4, $5)
+	sql := `INSERT INTO schedules (automation_id, cron_expression, parameters, next_run_at, is_enabled,
+	                               misfire_policy, misfire_max_lookback_minutes, timezone, overlap_policy,
+	                               calendar_id, holiday_policy, kind, interval_anchor, interval_minutes,
+	                               active_from, active_to, fan_out_param)
+	        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
 	        RETURNING id, created_at, updated_at`
 
-	err := r.db.QueryRow(ctx, sql,
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("erro ao criar agendamento: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, sql,
 		schedule.AutomationID,
 		schedule.CronExpression,
 		schedule.Parameters,
 		schedule.NextRunAt,
 		schedule.IsEnabled,
+		schedule.MisfirePolicy,
+		schedule.MisfireMaxLookbackMinutes,
+		schedule.Timezone,
+		schedule.OverlapPolicy,
+		schedule.CalendarID,
+		schedule.HolidayPolicy,
+		schedule.Kind,
+		schedule.IntervalAnchor,
+		schedule.IntervalMinutes,
+		schedule.ActiveFrom,
+		schedule.ActiveTo,
+		schedule.FanOutParam,
 	).Scan(&schedule.ID, &schedule.CreatedAt, &schedule.UpdatedAt)
 
 	if err != nil {
 		return fmt.Errorf("erro ao criar agendamento: %w", err)
 	}
+	if err := commitScheduleChange(ctx, tx, schedule.ID); err != nil {
+		return fmt.Errorf("erro ao criar agendamento: %w", err)
+	}
 	return nil
 }
 
 func (r *PostgresScheduleRepository) GetByID(ctx context.Context, id int) (*models.Schedule, error) {
-	sql := `SELECT id, automation_id, cron_expression, parameters, next_run_at, 
-	               is_enabled, created_at, updated_at
-	        FROM schedules WHERE id = $1`
-
-	s := &models.Schedule{}
-	err := r.db.QueryRow(ctx, sql, id).Scan(
-		&s.ID,
-		&s.AutomationID,
-		&s.CronExpression,
-		&s.Parameters,
-		&s.NextRunAt,
-		&s.IsEnabled,
-		&s.CreatedAt,
-		&s.UpdatedAt,
-	)
+	sql := `SELECT ` + scheduleSelectColumns + ` FROM schedules WHERE id = $1`
+
+	rows, err := r.db.Query(ctx, sql, id)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar agendamento por ID: %w", err)
+	}
+	s, err := pgx.CollectExactlyOneRow(rows, pgx.RowToAddrOfStructByPos[models.Schedule])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrScheduleNotFound
+	}
 	if err != nil {
 		return nil, fmt.Errorf("erro ao buscar agendamento por ID: %w", err)
 	}
@@ -51,8 +91,7 @@ func (r *PostgresScheduleRepository) GetByID(ctx context.Context, id int) (*mode
 }
 
 func (r *PostgresScheduleRepository) GetAllEnabled(ctx context.Context) ([]models.Schedule, error) {
-	sql := `SELECT id, automation_id, cron_expression, parameters, next_run_at, 
-	               is_enabled, created_at, updated_at
+	sql := `SELECT ` + scheduleSelectColumns + `
 	        FROM schedules
 	        WHERE is_enabled = TRUE
 	        ORDER BY next_run_at`
@@ -70,6 +109,54 @@ func (r *PostgresScheduleRepository) GetAllEnabled(ctx context.Context) ([]model
 	return schedules, nil
 }
 
+// List devolve todos os agendamentos — habilitados ou não — que passam nos
+// filtros, por próximo disparo (sem next_run_at por último) e ID.
+func (r *PostgresScheduleRepository) List(ctx context.Context, filter models.ScheduleListFilter) ([]models.Schedule, error) {
+	conditions := []string{}
+	args := []any{}
+	argIdx := 1
+
+	if filter.AutomationID != nil {
+		conditions = append(conditions, fmt.Sprintf("automation_id = $%d", argIdx))
+		args = append(args, *filter.AutomationID)
+		argIdx++
+	}
+	if filter.Enabled != nil {
+		conditions = append(conditions, fmt.Sprintf("is_enabled = $%d", argIdx))
+		args = append(args, *filter.Enabled)
+		argIdx++
+	}
+	if filter.NextRunFrom != nil {
+		conditions = append(conditions, fmt.Sprintf("next_run_at >= $%d", argIdx))
+		args = append(args, *filter.NextRunFrom)
+		argIdx++
+	}
+	if filter.NextRunTo != nil {
+		conditions = append(conditions, fmt.Sprintf("next_run_at <= $%d", argIdx))
+		args = append(args, *filter.NextRunTo)
+		argIdx++
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	sql := `SELECT ` + scheduleSelectColumns + ` FROM schedules ` + where + ` ORDER BY next_run_at NULLS LAST, id`
+
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao listar agendamentos: %w", err)
+	}
+
+	schedules, err := pgx.CollectRows(rows, pgx.RowToStructByPos[models.Schedule])
+	if err != nil {
+		return nil, fmt.Errorf("erro ao processar linhas de agendamentos: %w", err)
+	}
+
+	return schedules, nil
+}
+
 func (r *PostgresScheduleRepository) UpdateNextRun(ctx context.Context, id int, nextRun *time.Time) error {
 	sql := `UPDATE schedules SET next_run_at = $1, updated_at = NOW() WHERE id = $2`
 
@@ -86,35 +173,168 @@ func (r *PostgresScheduleRepository) UpdateNextRun(ctx context.Context, id int,
 func (r *PostgresScheduleRepository) Update(ctx context.Context, schedule *models.Schedule) error {
 	// next_run_at é propriedade exclusiva do scheduler (calculado no Reload),
 	// nunca escrito pela rota de update — senão um payload sem nextRunAt o zeraria.
+	// A única exceção é desabilitar: aí ele vira NULL, pra que reabilitar um
+	// agendamento parado há semanas não seja lido como misfire no Reload.
 	sql := `UPDATE schedules
 	        SET automation_id = $1, cron_expression = $2, parameters = $3,
-	            is_enabled = $4, updated_at = NOW()
-	        WHERE id = $5
+	            is_enabled = $4, misfire_policy = $5, misfire_max_lookback_minutes = $6,
+	            timezone = $8, overlap_policy = $9, calendar_id = $10, holiday_policy = $11,
+	            kind = $12, interval_anchor = $13, interval_minutes = $14,
+	            active_from = $15, active_to = $16, fan_out_param = $17,
+	            next_run_at = CASE WHEN $4 THEN next_run_at ELSE NULL END,
+	            updated_at = NOW()
+	        WHERE id = $7
 	        RETURNING updated_at`
 
-	err := r.db.QueryRow(ctx, sql,
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("erro ao atualizar agendamento: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, sql,
 		schedule.AutomationID,
 		schedule.CronExpression,
 		schedule.Parameters,
 		schedule.IsEnabled,
+		schedule.MisfirePolicy,
+		schedule.MisfireMaxLookbackMinutes,
 		schedule.ID,
+		schedule.Timezone,
+		schedule.OverlapPolicy,
+		schedule.CalendarID,
+		schedule.HolidayPolicy,
+		schedule.Kind,
+		schedule.IntervalAnchor,
+		schedule.IntervalMinutes,
+		schedule.ActiveFrom,
+		schedule.ActiveTo,
+		schedule.FanOutParam,
 	).Scan(&schedule.UpdatedAt)
 
 	if err != nil {
 		return fmt.Errorf("erro ao atualizar agendamento: %w", err)
 	}
+	if err := commitScheduleChange(ctx, tx, schedule.ID); err != nil {
+		return fmt.Errorf("erro ao atualizar agendamento: %w", err)
+	}
+	return nil
+}
+
+// SetPause grava (ou, com until nil, remove) a pausa do agendamento.
+func (r *PostgresScheduleRepository) SetPause(ctx context.Context, id int, until *time.Time, reason *string) error {
+	sql := `UPDATE schedules SET paused_until = $1, pause_reason = $2, updated_at = NOW() WHERE id = $3`
+
+	cmdTag, err := r.db.Exec(ctx, sql, until, reason, id)
+	if err != nil {
+		return fmt.Errorf("erro ao atualizar pausa do agendamento: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("nenhum agendamento encontrado para pausar com ID %d", id)
+	}
+	return nil
+}
+
+// SetEnabled liga ou desliga o agendamento sem mexer no resto da
+// configuração. Desligar zera o next_run_at, como no Update.
+func (r *PostgresScheduleRepository) SetEnabled(ctx context.Context, id int, enabled bool) error {
+	sql := `UPDATE schedules
+	        SET is_enabled = $1,
+	            next_run_at = CASE WHEN $1 THEN next_run_at ELSE NULL END,
+	            updated_at = NOW()
+	        WHERE id = $2`
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("erro ao atualizar status do agendamento: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	cmdTag, err := tx.Exec(ctx, sql, enabled, id)
+	if err != nil {
+		return fmt.Errorf("erro ao atualizar status do agendamento: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return ErrScheduleNotFound
+	}
+	if err := commitScheduleChange(ctx, tx, id); err != nil {
+		return fmt.Errorf("erro ao atualizar status do agendamento: %w", err)
+	}
 	return nil
 }
 
 func (r *PostgresScheduleRepository) Delete(ctx context.Context, id int) error {
 	sql := `DELETE FROM schedules WHERE id = $1`
 
-	cmdTag, err := r.db.Exec(ctx, sql, id)
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("erro ao deletar agendamento: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	cmdTag, err := tx.Exec(ctx, sql, id)
 	if err != nil {
 		return fmt.Errorf("erro ao deletar agendamento: %w", err)
 	}
 	if cmdTag.RowsAffected() == 0 {
 		return fmt.Errorf("nenhum agendamento encontrado para deletar com ID %d", id)
 	}
+	if err := commitScheduleChange(ctx, tx, id); err != nil {
+		return fmt.Errorf("erro ao deletar agendamento: %w", err)
+	}
 	return nil
-}
\ No newline at end of file
+}
+
+// commitScheduleChange notifica ScheduleChangesChannel com o ID do agendamento
+// e comita. O NOTIFY vai na mesma transação da escrita: só é entregue no
+// commit, então quem recebe já enxerga a linha nova, e uma escrita desfeita
+// não avisa ninguém.
+func commitScheduleChange(ctx context.Context, tx pgx.Tx, id int) error {
+	if _, err := tx.Exec(ctx, `SELECT pg_notify($1, $2)`, ScheduleChangesChannel, strconv.Itoa(id)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ListenChanges abre uma conexão dedicada com LISTEN em ScheduleChangesChannel
+// e devolve, depois que o LISTEN já vale, um canal com o ID de cada agendamento
+// alterado (0 = payload "*", todos). O canal fecha quando ctx acaba ou a
+// conexão cai — notificações desse intervalo se perdem, então quem chama deve
+// ressincronizar tudo ao reabrir.
+func (r *PostgresScheduleRepository) ListenChanges(ctx context.Context) (<-chan int, error) {
+	pooled, err := r.db.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao obter conexão para LISTEN: %w", err)
+	}
+	// Hijack tira a conexão do pool: ela fica presa no WaitForNotification e,
+	// ao fim, é fechada em vez de voltar pro pool ainda inscrita no canal.
+	conn := pooled.Hijack()
+	if _, err := conn.Exec(ctx, "LISTEN "+ScheduleChangesChannel); err != nil {
+		conn.Close(context.Background())
+		return nil, fmt.Errorf("erro ao escutar %s: %w", ScheduleChangesChannel, err)
+	}
+
+	changes := make(chan int, 64)
+	go func() {
+		defer close(changes)
+		defer conn.Close(context.Background())
+		for {
+			n, err := conn.WaitForNotification(ctx)
+			if err != nil {
+				return
+			}
+			id := 0
+			if n.Payload != "*" {
+				if id, err = strconv.Atoi(n.Payload); err != nil || id <= 0 {
+					continue
+				}
+			}
+			select {
+			case changes <- id:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return changes, nil
+}
@@ -0,0 +1,104 @@
+package retry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+)
+
+// defaultFailedItemField é o campo que identifica o item em
+// result.summary.failed[] (convenção da seção 5.3 de docs/automations.md).
+const defaultFailedItemField = "empresa"
+
+// ErrNothingToRetry é devolvido por FailedParams quando o result do job não
+// tem item falho pra refazer.
+var ErrNothingToRetry = errors.New("o job não tem itens com falha em result.summary.failed")
+
+// NormalizeFailedMapping apara os campos e preenche o ItemField padrão.
+func NormalizeFailedMapping(m *models.RetryFailedMapping) error {
+	m.Param = strings.TrimSpace(m.Param)
+	m.ItemField = strings.TrimSpace(m.ItemField)
+	if m.Param == "" {
+		return fmt.Errorf("param é obrigatório")
+	}
+	if m.ItemField == "" {
+		m.ItemField = defaultFailedItemField
+	}
+	return nil
+}
+
+// FailedParams monta os parâmetros do retry só dos itens que falharam: os do
+// job original com m.Param trocado pelos valores de m.ItemField em
+// result.summary.failed[], sem repetição e na ordem do result. Item que
+// aparece na lista original (comparando pela forma em texto) volta com o
+// valor original — "4814" no summary vira o número 4814 se era assim que o
+// job recebeu. Devolve também quantos itens entraram.
+func FailedParams(params map[string]interface{}, result json.RawMessage, m *models.RetryFailedMapping) (map[string]interface{}, int, error) {
+	field := m.ItemField
+	if field == "" {
+		field = defaultFailedItemField
+	}
+
+	var parsed struct {
+		Summary struct {
+			Failed []map[string]interface{} `json:"failed"`
+		} `json:"summary"`
+	}
+	if len(result) > 0 {
+		if err := json.Unmarshal(result, &parsed); err != nil {
+			return nil, 0, fmt.Errorf("result.summary.failed inválido: %w", err)
+		}
+	}
+
+	original := make(map[string]interface{})
+	if raw, ok := params[m.Param]; ok && raw != nil {
+		list, ok := raw.([]interface{})
+		if !ok {
+			return nil, 0, fmt.Errorf("o parâmetro %q do job original não é uma lista", m.Param)
+		}
+		for _, v := range list {
+			original[itemText(v)] = v
+		}
+	}
+
+	seen := make(map[string]bool)
+	items := make([]interface{}, 0, len(parsed.Summary.Failed))
+	for _, f := range parsed.Summary.Failed {
+		v, ok := f[field]
+		if !ok || v == nil {
+			continue
+		}
+		key := itemText(v)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if orig, ok := original[key]; ok {
+			v = orig
+		}
+		items = append(items, v)
+	}
+	if len(items) == 0 {
+		return nil, 0, ErrNothingToRetry
+	}
+
+	out := make(map[string]interface{}, len(params)+1)
+	for k, v := range params {
+		out[k] = v
+	}
+	out[m.Param] = items
+	return out, len(items), nil
+}
+
+// itemText é a forma de comparação de um item: string como está, o resto em
+// JSON.
+func itemText(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,57 @@
+package retry
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+)
+
+func TestFailedParams(t *testing.T) {
+	m := &models.RetryFailedMapping{Param: "lojas"}
+	if err := NormalizeFailedMapping(m); err != nil || m.ItemField != "empresa" {
+		t.Fatalf("NormalizeFailedMapping: %v, %+v", err, m)
+	}
+
+	params := map[string]interface{}{
+		"competencia": "2026-05",
+		"lojas":       []interface{}{float64(4814), float64(4815), float64(4816)},
+	}
+	result := json.RawMessage(`{"partial_success": true, "summary": {
+		"ok": ["4814"],
+		"failed": [
+			{"empresa": "4816", "error_class": "PORTAL_DOWN"},
+			{"empresa": "4815", "error_class": "RATE_LIMITED"},
+			{"empresa": "4816", "error_class": "PORTAL_DOWN"}
+		]}}`)
+
+	got, n, err := FailedParams(params, result, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{float64(4816), float64(4815)}
+	if n != 2 || !reflect.DeepEqual(got["lojas"], want) || got["competencia"] != "2026-05" {
+		t.Errorf("FailedParams = %v (%d), esperava lojas=%v", got, n, want)
+	}
+	if len(params["lojas"].([]interface{})) != 3 {
+		t.Error("FailedParams alterou os parâmetros originais")
+	}
+
+	// Item fora da lista original entra como veio no summary.
+	got, _, err = FailedParams(map[string]interface{}{}, json.RawMessage(`{"summary":{"failed":[{"empresa":"EMPRESA_C"}]}}`), m)
+	if err != nil || !reflect.DeepEqual(got["lojas"], []interface{}{"EMPRESA_C"}) {
+		t.Errorf("sem lista original: %v, %v", got, err)
+	}
+
+	for _, r := range []string{``, `{"status":"ok"}`, `{"summary":{"failed":[]}}`, `{"summary":{"failed":[{"cnpj":"1"}]}}`} {
+		if _, _, err := FailedParams(params, json.RawMessage(r), m); !errors.Is(err, ErrNothingToRetry) {
+			t.Errorf("result %q: esperava ErrNothingToRetry, veio %v", r, err)
+		}
+	}
+
+	if _, _, err := FailedParams(map[string]interface{}{"lojas": "4814"}, result, m); err == nil {
+		t.Error("parâmetro que não é lista deveria dar erro")
+	}
+}
@@ -0,0 +1,120 @@
+package retry
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+)
+
+// Limites e padrões da RetryPolicy. Campos omitidos (zero) no cadastro recebem
+// os padrões em Normalize.
+const (
+	defaultInitialDelaySeconds = 60
+	defaultMaxDelaySeconds     = 60 * 60
+	defaultMultiplier          = 2.0
+	maxPolicyAttempts          = 20
+	maxPolicyDelaySeconds      = 24 * 60 * 60
+)
+
+// nonRetryableClasses nunca entram numa política: repetir não resolve e, no
+// caso de credencial, pode bloquear a conta no portal.
+var nonRetryableClasses = map[string]bool{
+	"CREDENTIAL_INVALID": true,
+	"INVALID_PARAMETERS": true,
+}
+
+// Normalize preenche os padrões de p e valida os limites. As classes de erro
+// são aparadas e passadas pra maiúsculas.
+func Normalize(p *models.RetryPolicy) error {
+	if p.MaxAttempts < 1 || p.MaxAttempts > maxPolicyAttempts {
+		return fmt.Errorf("maxAttempts deve estar entre 1 e %d", maxPolicyAttempts)
+	}
+	if p.InitialDelaySeconds == 0 {
+		p.InitialDelaySeconds = defaultInitialDelaySeconds
+	}
+	if p.MaxDelaySeconds == 0 {
+		p.MaxDelaySeconds = defaultMaxDelaySeconds
+	}
+	if p.Multiplier == 0 {
+		p.Multiplier = defaultMultiplier
+	}
+	if p.InitialDelaySeconds < 1 || p.InitialDelaySeconds > maxPolicyDelaySeconds {
+		return fmt.Errorf("initialDelaySeconds deve estar entre 1 e %d", maxPolicyDelaySeconds)
+	}
+	if p.MaxDelaySeconds < p.InitialDelaySeconds || p.MaxDelaySeconds > maxPolicyDelaySeconds {
+		return fmt.Errorf("maxDelaySeconds deve estar entre initialDelaySeconds e %d", maxPolicyDelaySeconds)
+	}
+	if p.Multiplier < 1 || p.Multiplier > 10 {
+		return fmt.Errorf("multiplier deve estar entre 1 e 10")
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("jitter deve estar entre 0 e 1")
+	}
+
+	classes := make([]string, 0, len(p.RetryableErrorClasses))
+	for _, c := range p.RetryableErrorClasses {
+		c = strings.ToUpper(strings.TrimSpace(c))
+		if c == "" {
+			continue
+		}
+		if nonRetryableClasses[c] {
+			return fmt.Errorf("error_class %s não pode ser retentada", c)
+		}
+		classes = append(classes, c)
+	}
+	p.RetryableErrorClasses = classes
+	return nil
+}
+
+// MaxRetries devolve quantos re-enfileiramentos um job da automação admite
+// além da primeira execução. Sem política, o limite histórico de 3.
+func MaxRetries(p *models.RetryPolicy) int {
+	if p == nil {
+		return maxRetries
+	}
+	return p.MaxAttempts - 1
+}
+
+// Decide diz se um job que terminou em failed com errorClass, depois de
+// retryCount re-enfileiramentos, deve ser retentado — e, se sim, a espera até
+// a próxima tentativa.
+func Decide(p *models.RetryPolicy, errorClass string, retryCount int) (time.Duration, bool) {
+	if p == nil || retryCount >= MaxRetries(p) || !retryable(p, errorClass) {
+		return 0, false
+	}
+	return Backoff(p, retryCount+1, rand.Float64()), true
+}
+
+func retryable(p *models.RetryPolicy, errorClass string) bool {
+	errorClass = strings.ToUpper(strings.TrimSpace(errorClass))
+	if errorClass == "" {
+		return false
+	}
+	for _, c := range p.RetryableErrorClasses {
+		if c == errorClass {
+			return true
+		}
+	}
+	return false
+}
+
+// Backoff é a espera antes da tentativa n+1, depois de n execuções com falha
+// (n >= 1): InitialDelaySeconds * Multiplier^(n-1), limitada a
+// MaxDelaySeconds, com ±Jitter aplicado a partir de r (0 <= r < 1; 0.5 = sem
+// desvio). O jitter espalha os retries de vários jobs que falharam juntos
+// (portal fora do ar) em vez de voltarem todos no mesmo segundo.
+func Backoff(p *models.RetryPolicy, n int, r float64) time.Duration {
+	if n < 1 {
+		n = 1
+	}
+	delay := float64(p.InitialDelaySeconds) * math.Pow(p.Multiplier, float64(n-1))
+	if limit := float64(p.MaxDelaySeconds); delay > limit {
+		delay = limit
+	}
+	delay *= 1 + p.Jitter*(2*r-1)
+	return time.Duration(delay * float64(time.Second))
+}
@@ -0,0 +1,51 @@
+package retry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+)
+
+func TestPolicy(t *testing.T) {
+	p := &models.RetryPolicy{MaxAttempts: 4, RetryableErrorClasses: []string{" rate_limited", "PORTAL_DOWN"}}
+	if err := Normalize(p); err != nil {
+		t.Fatal(err)
+	}
+
+	// 60s, 120s, 240s… até o teto de 1h; r=0.5 anula o jitter.
+	for n, want := range map[int]time.Duration{1: time.Minute, 2: 2 * time.Minute, 3: 4 * time.Minute, 10: time.Hour} {
+		if got := Backoff(p, n, 0.5); got != want {
+			t.Errorf("Backoff(%d) = %s, esperava %s", n, got, want)
+		}
+	}
+	p.Jitter = 0.2
+	if lo, hi := Backoff(p, 1, 0), Backoff(p, 1, 0.999); lo != 48*time.Second || hi <= 71*time.Second || hi > 72*time.Second {
+		t.Errorf("jitter fora de ±20%%: %s..%s", lo, hi)
+	}
+
+	cases := []struct {
+		class   string
+		retries int
+		want    bool
+	}{
+		{"RATE_LIMITED", 0, true},
+		{"portal_down", 2, true},
+		{"PORTAL_DOWN", 3, false}, // 4ª execução foi a última
+		{"CAPTCHA_FAILED", 0, false},
+		{"", 0, false},
+	}
+	for _, c := range cases {
+		if _, ok := Decide(p, c.class, c.retries); ok != c.want {
+			t.Errorf("Decide(%q, %d) = %v, esperava %v", c.class, c.retries, ok, c.want)
+		}
+	}
+	if _, ok := Decide(nil, "RATE_LIMITED", 0); ok {
+		t.Error("sem política não deveria retentar")
+	}
+
+	bad := &models.RetryPolicy{MaxAttempts: 3, RetryableErrorClasses: []string{"CREDENTIAL_INVALID"}}
+	if err := Normalize(bad); err == nil {
+		t.Error("CREDENTIAL_INVALID deveria ser rejeitada")
+	}
+}
@@ -10,11 +10,19 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// maxRetries é o limite de re-enfileiramentos de job travado pra automação
+// sem RetryPolicy; com política vale MaxAttempts - 1 (ver MaxRetries).
 const maxRetries = 3
 
+// LeaderChecker diz se esta réplica é a líder. Só a líder varre stuck jobs —
+// com duas réplicas varrendo, o mesmo job seria re-enfileirado duas vezes.
+type LeaderChecker interface {
+	IsLeader() bool
+}
+
 // RetryWorker detecta jobs travados (worker provavelmente morto) e os
-// re-enfileira. Após maxRetries tentativas sem sucesso, marca o job como
-// failed.
+// re-enfileira. Após MaxRetries tentativas sem sucesso (a RetryPolicy da
+// automação ou 3), marca o job como failed.
 //
 // Usa dois timeouts pra evitar falso positivo em jobs longos legítimos:
 //
@@ -25,26 +33,37 @@ const maxRetries = 3
 //   - noHeartbeatTimeout: fallback pra workers antigos que não polam o
 //     endpoint de cancelamento. Threshold longo o suficiente pra não pegar
 //     job legítimo de duração média (o bot-xml-gms hoje leva ~45min).
+//
+// O mesmo loop aplica o MaxDurationMinutes das automações (ver checkTimeouts):
+// worker travado que continua polando nunca perde o heartbeat.
 type RetryWorker struct {
 	jobRepo            repository.JobRepository
+	jobLogRepo         repository.JobLogRepository
 	automationRepo     repository.AutomationRepository
 	queueClient        *queue.RabbitMQClient
+	leader             LeaderChecker
 	heartbeatTimeout   time.Duration
 	noHeartbeatTimeout time.Duration
+	timeoutGrace       time.Duration
 	checkInterval      time.Duration
 }
 
 func New(
 	jobRepo repository.JobRepository,
+	jobLogRepo repository.JobLogRepository,
 	automationRepo repository.AutomationRepository,
 	queueClient *queue.RabbitMQClient,
+	leader LeaderChecker,
 ) *RetryWorker {
 	return &RetryWorker{
 		jobRepo:            jobRepo,
+		jobLogRepo:         jobLogRepo,
 		automationRepo:     automationRepo,
 		queueClient:        queueClient,
+		leader:             leader,
 		heartbeatTimeout:   5 * time.Minute,
 		noHeartbeatTimeout: 2 * time.Hour,
+		timeoutGrace:       5 * time.Minute,
 		checkInterval:      1 * time.Minute,
 	}
 }
@@ -54,6 +73,7 @@ func (w *RetryWorker) Start(ctx context.Context) {
 	log.Info().
 		Dur("heartbeat_timeout", w.heartbeatTimeout).
 		Dur("no_heartbeat_timeout", w.noHeartbeatTimeout).
+		Dur("timeout_grace", w.timeoutGrace).
 		Dur("check_interval", w.checkInterval).
 		Msg("[retry] worker iniciado")
 
@@ -66,7 +86,11 @@ func (w *RetryWorker) Start(ctx context.Context) {
 			log.Info().Msg("[retry] worker encerrado")
 			return
 		case <-ticker.C:
+			if w.leader != nil && !w.leader.IsLeader() {
+				continue
+			}
 			w.checkAndRetry(ctx)
+			w.checkTimeouts(ctx)
 		}
 	}
 }
@@ -85,7 +109,13 @@ func (w *RetryWorker) checkAndRetry(ctx context.Context) {
 	log.Info().Int("count", len(jobs)).Msg("[retry] stuck jobs encontrados")
 
 	for _, job := range jobs {
-		if job.RetryCount >= maxRetries {
+		automation, err := w.automationRepo.GetByID(ctx, job.AutomationID)
+		if err != nil {
+			log.Error().Err(err).Str("job_id", job.ID.String()).Msg("[retry] automação não encontrada")
+			continue
+		}
+
+		if job.RetryCount >= MaxRetries(automation.RetryPolicy) {
 			result, _ := json.Marshal(map[string]string{"error": "max retries exceeded"})
 			if err := w.jobRepo.SetResult(ctx, job.ID, result); err != nil {
 				log.Error().Err(err).Str("job_id", job.ID.String()).Msg("[retry] erro ao salvar resultado de falha")
@@ -97,21 +127,21 @@ func (w *RetryWorker) checkAndRetry(ctx context.Context) {
 			continue
 		}
 
-		automation, err := w.automationRepo.GetByID(ctx, job.AutomationID)
-		if err != nil {
-			log.Error().Err(err).Str("job_id", job.ID.String()).Msg("[retry] automação não encontrada")
-			continue
-		}
-
 		if err := w.jobRepo.IncrementRetryCount(ctx, job.ID); err != nil {
 			log.Error().Err(err).Str("job_id", job.ID.String()).Msg("[retry] erro ao incrementar retry_count")
 			continue
 		}
 
-		if err := w.jobRepo.UpdateStatus(ctx, job.ID, "pending"); err != nil {
+		held, err := w.jobRepo.Requeue(ctx, job.ID)
+		if err != nil {
 			log.Error().Err(err).Str("job_id", job.ID.String()).Msg("[retry] erro ao resetar status")
 			continue
 		}
+		if held {
+			// Automação com limite de concorrência/taxa: o dispatcher publica.
+			log.Info().Str("job_id", job.ID.String()).Int("attempt", job.RetryCount+1).Msg("[retry] job devolvido à fila retida")
+			continue
+		}
 
 		var params map[string]interface{}
 		if len(job.Parameters) > 0 {
@@ -128,6 +158,7 @@ func (w *RetryWorker) checkAndRetry(ctx context.Context) {
 			AutomationID: automation.ID,
 			ScriptPath:   automation.ScriptPath,
 			Parameters:   params,
+			Priority:     job.Priority,
 		}
 
 		if err := w.queueClient.PublishJob(ctx, queueName, msg); err != nil {
@@ -0,0 +1,73 @@
+package retry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// TimeoutErrorClass é a error_class gravada no result do job encerrado à força
+// por estourar o MaxDurationMinutes — a mesma que o worker usa pro próprio
+// timeout, então cai na mesma linha da distribuição de erros.
+const TimeoutErrorClass = "JOB_TIMEOUT"
+
+// checkTimeouts aplica o MaxDurationMinutes em duas etapas: no primeiro tick
+// acima do limite pede cancelamento (o worker vê no próximo poll e encerra
+// limpo); se timeoutGrace depois do pedido o job ainda está em running, marca
+// failed sem esperar o worker.
+func (w *RetryWorker) checkTimeouts(ctx context.Context) {
+	jobs, err := w.jobRepo.GetOverdueJobs(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("[retry] erro ao buscar jobs acima do tempo máximo")
+		return
+	}
+
+	for _, job := range jobs {
+		automation, err := w.automationRepo.GetByID(ctx, job.AutomationID)
+		if err != nil || automation.MaxDurationMinutes == nil {
+			continue
+		}
+		limit := *automation.MaxDurationMinutes
+
+		if job.CancellationRequestedAt == nil {
+			if err := w.jobRepo.RequestCancellation(ctx, job.ID); err != nil {
+				log.Error().Err(err).Str("job_id", job.ID.String()).Msg("[retry] erro ao pedir cancelamento por timeout")
+				continue
+			}
+			w.jobLog(ctx, job.ID, "WARNING", fmt.Sprintf(
+				"Tempo máximo de execução (%d min) excedido — cancelamento solicitado ao worker", limit))
+			log.Warn().Str("job_id", job.ID.String()).Int("max_duration_minutes", limit).Msg("[retry] job acima do tempo máximo, cancelamento solicitado")
+			continue
+		}
+
+		// Pedido de cancelamento (nosso ou do usuário) ainda dentro da carência.
+		if time.Since(*job.CancellationRequestedAt) < w.timeoutGrace {
+			continue
+		}
+
+		message := fmt.Sprintf("Tempo máximo de execução (%d min) excedido e o worker não atendeu o cancelamento", limit)
+		result, _ := json.Marshal(map[string]string{"error": message, "error_class": TimeoutErrorClass})
+		failed, err := w.jobRepo.FailRunning(ctx, job.ID, result)
+		if err != nil {
+			log.Error().Err(err).Str("job_id", job.ID.String()).Msg("[retry] erro ao encerrar job por timeout")
+			continue
+		}
+		if !failed {
+			continue // worker finalizou entre a busca e o update
+		}
+		w.jobLog(ctx, job.ID, "ERROR", message)
+		log.Warn().Str("job_id", job.ID.String()).Int("max_duration_minutes", limit).Msg("[retry] job marcado como failed por timeout")
+	}
+}
+
+func (w *RetryWorker) jobLog(ctx context.Context, jobID uuid.UUID, level, message string) {
+	entry := &models.JobLog{JobID: jobID, Level: level, Message: message}
+	if err := w.jobLogRepo.Create(ctx, entry); err != nil {
+		log.Error().Err(err).Msg("[retry] erro ao registrar log do job")
+	}
+}
@@ -0,0 +1,200 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+	"github.com/EnzzoHosaki/rps-maestro/internal/ratelimit"
+)
+
+// concurrencyDispatchInterval é a cadência com que os jobs retidos pelos
+// limites de concorrência e de taxa são conferidos — também o atraso máximo
+// entre uma vaga (ou ficha) abrir e o próximo job sair pra fila.
+const concurrencyDispatchInterval = 3 * time.Second
+
+// ResolveResourceKey monta a chave de recurso do job a partir do modelo da
+// automação ("cert:{{cnpj}}") e dos parâmetros. Devolve "" — job sem chave,
+// limitado só pelo MaxConcurrency — quando algum parâmetro citado falta ou
+// está vazio: agrupar todos esses sob "cert:" travaria jobs sem relação.
+func ResolveResourceKey(tmpl string, params map[string]interface{}) string {
+	missing := false
+	key := placeholderRe.ReplaceAllStringFunc(tmpl, func(tok string) string {
+		name := strings.TrimSpace(placeholderRe.FindStringSubmatch(tok)[1])
+		v, ok := params[name]
+		if !ok || v == nil {
+			missing = true
+			return ""
+		}
+		s := strings.TrimSpace(fmt.Sprint(v))
+		if s == "" {
+			missing = true
+		}
+		return s
+	})
+	if missing {
+		return ""
+	}
+	return strings.TrimSpace(key)
+}
+
+// concurrencyLoad conta os jobs em voo por automação e por chave de recurso
+// durante uma rodada do dispatcher.
+type concurrencyLoad struct {
+	byAutomation map[int]int
+	byResource   map[string]int
+}
+
+func newConcurrencyLoad(inFlight []models.JobLoad) *concurrencyLoad {
+	l := &concurrencyLoad{byAutomation: make(map[int]int), byResource: make(map[string]int)}
+	for _, j := range inFlight {
+		key := ""
+		if j.ResourceKey != nil {
+			key = *j.ResourceKey
+		}
+		l.add(j.AutomationID, key)
+	}
+	return l
+}
+
+// admits diz se mais um job da automação (com a chave resolvida) cabe nos
+// limites. Chave vazia não disputa recurso.
+func (l *concurrencyLoad) admits(a *models.Automation, key string) bool {
+	if a.MaxConcurrency != nil && l.byAutomation[a.ID] >= *a.MaxConcurrency {
+		return false
+	}
+	if key != "" {
+		limit := 1
+		if a.ResourceConcurrency != nil {
+			limit = *a.ResourceConcurrency
+		}
+		if l.byResource[key] >= limit {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *concurrencyLoad) add(automationID int, key string) {
+	l.byAutomation[automationID]++
+	if key != "" {
+		l.byResource[key]++
+	}
+}
+
+// runConcurrencyDispatcher publica, na réplica líder, os jobs retidos pelos
+// limites de concorrência e de taxa conforme abrem vagas. Bloqueante até o ctx
+// ser cancelado — chamado em goroutine pelo Start.
+func (s *Scheduler) runConcurrencyDispatcher(ctx context.Context) {
+	ticker := time.NewTicker(concurrencyDispatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if s.isLeader() {
+				s.dispatchHeld(ctx)
+			}
+		}
+	}
+}
+
+// dispatchHeld percorre os retidos do mais antigo pro mais novo e publica os
+// que cabem. Um job barrado pela chave de recurso não trava os seguintes da
+// mesma automação com outra chave (outro CNPJ segue rodando). O limite de taxa
+// é conferido por último: ficha só é gasta com job que vai sair de fato.
+func (s *Scheduler) dispatchHeld(ctx context.Context) {
+	held, err := s.jobRepo.GetHeldJobs(ctx)
+	if err != nil {
+		log.Printf("[scheduler] %v", err)
+		return
+	}
+	if len(held) == 0 {
+		return
+	}
+
+	inFlight, err := s.jobRepo.GetInFlightLoad(ctx)
+	if err != nil {
+		log.Printf("[scheduler] %v", err)
+		return
+	}
+	load := newConcurrencyLoad(inFlight)
+
+	automations := make(map[int]*models.Automation)
+	buckets := make(map[int]*models.RateBucket)
+	now := time.Now()
+	for i := range held {
+		job := &held[i]
+		automation, ok := automations[job.AutomationID]
+		if !ok {
+			automation, err = s.automationRepo.GetByID(ctx, job.AutomationID)
+			if err != nil {
+				log.Printf("[scheduler] job retido %s: automação %d não encontrada: %v", job.ID, job.AutomationID, err)
+				continue
+			}
+			automations[job.AutomationID] = automation
+		}
+
+		params, err := parseParams(job.Parameters)
+		if err != nil {
+			log.Printf("[scheduler] job retido %s: parâmetros inválidos: %v", job.ID, err)
+			continue
+		}
+
+		key := ""
+		if automation.ResourceKey != nil {
+			key = ResolveResourceKey(*automation.ResourceKey, params)
+		}
+		if !load.admits(automation, key) {
+			continue
+		}
+
+		var bucket *models.RateBucket
+		if automation.RateLimit != nil {
+			current, ok := buckets[automation.ID]
+			if !ok {
+				current, err = s.automationRepo.GetRateBucket(ctx, automation.ID)
+				if err != nil {
+					log.Printf("[scheduler] %v", err)
+					continue
+				}
+				buckets[automation.ID] = current
+			}
+			if bucket, ok = ratelimit.Take(current, automation.ID, automation.RateLimit, now); !ok {
+				continue
+			}
+		}
+
+		var keyRef *string
+		if key != "" {
+			keyRef = &key
+		}
+		dispatched, err := s.jobRepo.Dispatch(ctx, job.ID, keyRef)
+		if err != nil {
+			log.Printf("[scheduler] %v", err)
+			continue
+		}
+		if !dispatched {
+			continue // cancelado entre a busca e a liberação
+		}
+		load.add(automation.ID, key)
+		if bucket != nil {
+			buckets[automation.ID] = bucket
+			if err := s.automationRepo.SaveRateBucket(ctx, bucket); err != nil {
+				log.Printf("[scheduler] %v", err)
+			}
+		}
+
+		if err := s.publish(ctx, automation, job, params); err != nil {
+			log.Printf("[scheduler] %v", err)
+			continue
+		}
+		log.Printf("[scheduler] job %s liberado pelo dispatcher — automação %q, recurso %q",
+			job.ID, automation.Name, key)
+	}
+}
@@ -0,0 +1,51 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+)
+
+func TestResolveResourceKey(t *testing.T) {
+	params := map[string]interface{}{"cnpj": "12345678000199", "loja": float64(4814), "vazio": " "}
+	cases := map[string]string{
+		"cert:{{cnpj}}":             "cert:12345678000199",
+		"{{ cnpj }}/{{loja}}":       "12345678000199/4814",
+		"sefaz":                     "sefaz",
+		"cert:{{certificado}}":      "",
+		"cert:{{vazio}}":            "",
+		"cert:{{cnpj}}:{{ausente}}": "",
+	}
+	for tmpl, want := range cases {
+		if got := ResolveResourceKey(tmpl, params); got != want {
+			t.Errorf("%q: esperava %q, veio %q", tmpl, want, got)
+		}
+	}
+}
+
+func TestConcurrencyLoad(t *testing.T) {
+	two := 2
+	key := "cert:{{cnpj}}"
+	a := &models.Automation{ID: 1, MaxConcurrency: &two, ResourceKey: &key}
+	other := &models.Automation{ID: 2, ResourceKey: &key}
+
+	cert := "cert:111"
+	l := newConcurrencyLoad([]models.JobLoad{{AutomationID: 1, ResourceKey: &cert}})
+
+	// Mesmo certificado, ainda que por outra automação: ocupado (limite 1).
+	if l.admits(a, "cert:111") || l.admits(other, "cert:111") {
+		t.Fatal("cert:111 já está em uso")
+	}
+	if !l.admits(a, "cert:222") {
+		t.Fatal("cert:222 deveria caber")
+	}
+	l.add(a.ID, "cert:222")
+
+	// Automação 1 chegou ao MaxConcurrency; a 2 não tem limite próprio.
+	if l.admits(a, "cert:333") || l.admits(a, "") {
+		t.Fatal("automação 1 deveria estar no limite")
+	}
+	if !l.admits(other, "cert:333") {
+		t.Fatal("automação 2 com cert:333 deveria caber")
+	}
+}
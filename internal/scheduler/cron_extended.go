@@ -0,0 +1,421 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sintaxe estendida (estilo Quartz) aceita pelo parser, além do cron padrão:
+//
+//   - 6 campos: segundos antes do minuto ("30 0 8 * * *" = 08:00:30).
+//   - dia-do-mês: `L` (último dia), `L-3` (3 dias antes do último), `15W`
+//     (dia útil — seg a sex — mais próximo do dia 15, sem sair do mês), `LW`
+//     (último dia de seg a sex do mês). Combinam em lista com dias comuns e
+//     com qualquer mês ("0 8 8,15,L 1-6 *").
+//   - dia-da-semana: `6#1` (1º sábado do mês), `5L` (última sexta do mês).
+//     Domingo é 0 (ou 7); nomes SUN-SAT também valem ("SAT#1").
+//   - `?` em dia-do-mês/dia-da-semana = `*`.
+//
+// Tokens especiais de dia-do-mês não combinam com dia-da-semana restrito (e
+// vice-versa): "último dia OU segunda" é ambíguo demais — o Quartz também
+// rejeita. Com dias comuns nos dois campos vale a regra do cron padrão
+// (dispara se qualquer um casar).
+
+// cronField descreve um campo da expressão pra parse e mensagens de erro.
+type cronField struct {
+	name   string
+	lo, hi int
+	names  map[string]int
+}
+
+var (
+	fieldSecond = cronField{name: "segundo", lo: 0, hi: 59}
+	fieldMinute = cronField{name: "minuto", lo: 0, hi: 59}
+	fieldHour   = cronField{name: "hora", lo: 0, hi: 23}
+	fieldDom    = cronField{name: "dia-do-mês", lo: 1, hi: 31}
+	fieldMonth  = cronField{name: "mês", lo: 1, hi: 12, names: map[string]int{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}}
+	fieldDow = cronField{name: "dia-da-semana", lo: 0, hi: 7, names: map[string]int{
+		"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+	}}
+)
+
+func (f cronField) errorf(format string, args ...any) error {
+	return fmt.Errorf("campo %s: "+format, append([]any{f.name}, args...)...)
+}
+
+// cronSpec é a expressão já decomposta. Os slices de valores vêm ordenados.
+type cronSpec struct {
+	seconds, minutes, hours []int
+	months                  [13]bool
+
+	domStar      bool
+	days         [32]bool
+	last         bool  // L
+	lastOffsets  []int // L-n
+	lastWeekday  bool  // LW
+	nearWeekdays []int // nW
+
+	dowStar      bool
+	weekdays     [7]bool
+	nthWeekdays  [][2]int // {dia-da-semana, n} de n#k
+	lastWeekdays []int    // dia-da-semana de nL
+
+	hasSeconds bool
+}
+
+// extended diz se a expressão usa algo que o ParseStandard do robfig não
+// entende — aí o runtime usa o próprio cronSpec.
+func (s *cronSpec) extended() bool {
+	return s.hasSeconds || s.last || s.lastWeekday || len(s.lastOffsets) > 0 || len(s.nearWeekdays) > 0 ||
+		len(s.nthWeekdays) > 0 || len(s.lastWeekdays) > 0
+}
+
+// parseCronSpec decompõe uma expressão de 5 ou 6 campos, com erro apontando o
+// campo problemático.
+func parseCronSpec(fields []string) (*cronSpec, error) {
+	spec := &cronSpec{}
+	switch len(fields) {
+	case 5:
+		spec.seconds = []int{0}
+	case 6:
+		spec.hasSeconds = true
+		secs, err := parseValues(fieldSecond, fields[0])
+		if err != nil {
+			return nil, err
+		}
+		spec.seconds = secs
+		fields = fields[1:]
+	default:
+		return nil, fmt.Errorf("esperados 5 campos (minuto hora dia-do-mês mês dia-da-semana) ou 6 (com segundos no início), veio %d", len(fields))
+	}
+
+	var err error
+	if spec.minutes, err = parseValues(fieldMinute, fields[0]); err != nil {
+		return nil, err
+	}
+	if spec.hours, err = parseValues(fieldHour, fields[1]); err != nil {
+		return nil, err
+	}
+	months, err := parseValues(fieldMonth, fields[3])
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range months {
+		spec.months[m] = true
+	}
+	if err := spec.parseDom(fields[2]); err != nil {
+		return nil, err
+	}
+	if err := spec.parseDow(fields[4]); err != nil {
+		return nil, err
+	}
+
+	domSpecial := spec.last || spec.lastWeekday || len(spec.lastOffsets) > 0 || len(spec.nearWeekdays) > 0
+	dowSpecial := len(spec.nthWeekdays) > 0 || len(spec.lastWeekdays) > 0
+	if domSpecial && !spec.dowStar {
+		return nil, fieldDom.errorf("L/W não combinam com dia-da-semana restrito (use * ou ? no dia-da-semana)")
+	}
+	if dowSpecial && !spec.domStar {
+		return nil, fieldDow.errorf("# e L não combinam com dia-do-mês restrito (use * ou ? no dia-do-mês)")
+	}
+	return spec, nil
+}
+
+func (s *cronSpec) parseDom(expr string) error {
+	if expr == "*" || expr == "?" {
+		s.domStar = true
+		return nil
+	}
+	for _, part := range strings.Split(strings.ToUpper(expr), ",") {
+		switch {
+		case part == "L":
+			s.last = true
+		case part == "LW":
+			s.lastWeekday = true
+		case strings.HasPrefix(part, "L-"):
+			n, err := strconv.Atoi(part[2:])
+			if err != nil || n < 0 || n > 30 {
+				return fieldDom.errorf("%q inválido (use L-n com n entre 0 e 30)", part)
+			}
+			s.lastOffsets = append(s.lastOffsets, n)
+		case strings.HasSuffix(part, "W"):
+			n, err := strconv.Atoi(strings.TrimSuffix(part, "W"))
+			if err != nil || n < 1 || n > 31 {
+				return fieldDom.errorf("%q inválido (use nW com n entre 1 e 31)", part)
+			}
+			s.nearWeekdays = append(s.nearWeekdays, n)
+		default:
+			days, err := parseValues(fieldDom, part)
+			if err != nil {
+				return err
+			}
+			for _, d := range days {
+				s.days[d] = true
+			}
+		}
+	}
+	return nil
+}
+
+func (s *cronSpec) parseDow(expr string) error {
+	if expr == "*" || expr == "?" {
+		s.dowStar = true
+		return nil
+	}
+	for _, part := range strings.Split(strings.ToUpper(expr), ",") {
+		switch {
+		case strings.Contains(part, "#"):
+			wd, nth, _ := strings.Cut(part, "#")
+			d, err := parseWeekday(wd)
+			if err != nil {
+				return err
+			}
+			n, err := strconv.Atoi(nth)
+			if err != nil || n < 1 || n > 5 {
+				return fieldDow.errorf("%q inválido (use d#n com n entre 1 e 5, ex.: 6#1 = 1º sábado)", part)
+			}
+			s.nthWeekdays = append(s.nthWeekdays, [2]int{d, n})
+		case part == "L":
+			return fieldDow.errorf("L sozinho é ambíguo; use dL (ex.: 5L = última sexta do mês)")
+		case len(part) > 1 && strings.HasSuffix(part, "L"):
+			d, err := parseWeekday(strings.TrimSuffix(part, "L"))
+			if err != nil {
+				return err
+			}
+			s.lastWeekdays = append(s.lastWeekdays, d)
+		default:
+			days, err := parseValues(fieldDow, part)
+			if err != nil {
+				return err
+			}
+			for _, d := range days {
+				s.weekdays[d%7] = true
+			}
+		}
+	}
+	return nil
+}
+
+func parseWeekday(v string) (int, error) {
+	if n, ok := fieldDow.names[v]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 || n > 7 {
+		return 0, fieldDow.errorf("%q não é um dia da semana (0-7 ou SUN-SAT)", v)
+	}
+	return n % 7, nil
+}
+
+// parseValues expande um campo comum — `*`, listas, faixas e passos (`*/15`,
+// `1-5`, `10-40/10`, `5/15`), com nomes quando o campo tem — nos valores
+// ordenados e sem repetição.
+func parseValues(f cronField, expr string) ([]int, error) {
+	if expr == "" {
+		return nil, f.errorf("vazio")
+	}
+	set := map[int]bool{}
+	for _, part := range strings.Split(strings.ToUpper(expr), ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n < 1 {
+				return nil, f.errorf("passo inválido em %q", part)
+			}
+			step = n
+		}
+
+		lo, hi := f.lo, f.hi
+		switch {
+		case rng == "*" || rng == "?":
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(a); err != nil {
+				return nil, err
+			}
+			if hi, err = f.value(b); err != nil {
+				return nil, err
+			}
+			if lo > hi {
+				return nil, f.errorf("faixa invertida %q", part)
+			}
+		default:
+			v, err := f.value(rng)
+			if err != nil {
+				return nil, err
+			}
+			lo = v
+			if !hasStep {
+				hi = v
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+
+	out := make([]int, 0, len(set))
+	for v := range set {
+		out = append(out, v)
+	}
+	sort.Ints(out)
+	return out, nil
+}
+
+func (f cronField) value(v string) (int, error) {
+	if n, ok := f.names[v]; ok {
+		return n, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, f.errorf("%q não é um valor válido", v)
+	}
+	if n < f.lo || n > f.hi {
+		return 0, f.errorf("%d fora do intervalo %d-%d", n, f.lo, f.hi)
+	}
+	return n, nil
+}
+
+// cronSpecSchedule é o cron.Schedule da sintaxe estendida. loc nil = usa o
+// fuso do `t` recebido no Next.
+type cronSpecSchedule struct {
+	spec *cronSpec
+	loc  *time.Location
+}
+
+// maxCronSpecDays limita a busca do Next: 8 anos cobre até "29 de fevereiro"
+// atravessando um ano secular não bissexto (2096 → 2104).
+const maxCronSpecDays = 8*366 + 1
+
+// Next devolve o próximo disparo estritamente depois de t, varrendo dia a dia
+// e, no dia que casa, os horários em ordem. Horário que não existe no dia
+// (pulo do horário de verão) é ignorado.
+func (s cronSpecSchedule) Next(t time.Time) time.Time {
+	orig := t.Location()
+	if s.loc != nil {
+		t = t.In(s.loc)
+	}
+	loc := t.Location()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	for i := 0; i < maxCronSpecDays; i++ {
+		if s.spec.matchesDay(day) {
+			if fire, ok := s.firstFireAfter(day, t, i == 0); ok {
+				return fire.In(orig)
+			}
+		}
+		day = time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, loc)
+	}
+	return time.Time{}
+}
+
+func (s cronSpecSchedule) firstFireAfter(day, t time.Time, sameDay bool) (time.Time, bool) {
+	th, tm := t.Hour(), t.Minute()
+	for _, h := range s.spec.hours {
+		if sameDay && h < th {
+			continue
+		}
+		for _, m := range s.spec.minutes {
+			if sameDay && h == th && m < tm {
+				continue
+			}
+			for _, sec := range s.spec.seconds {
+				fire := time.Date(day.Year(), day.Month(), day.Day(), h, m, sec, 0, day.Location())
+				if fire.Hour() != h || fire.Minute() != m {
+					continue
+				}
+				if fire.After(t) {
+					return fire, true
+				}
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func (s *cronSpec) matchesDay(d time.Time) bool {
+	if !s.months[d.Month()] {
+		return false
+	}
+	switch {
+	case s.domStar && s.dowStar:
+		return true
+	case s.domStar:
+		return s.matchesDow(d)
+	case s.dowStar:
+		return s.matchesDom(d)
+	default:
+		return s.matchesDom(d) || s.matchesDow(d)
+	}
+}
+
+func (s *cronSpec) matchesDom(d time.Time) bool {
+	day, last := d.Day(), lastDayOfMonth(d)
+	if s.days[day] || (s.last && day == last) {
+		return true
+	}
+	for _, n := range s.lastOffsets {
+		if day == last-n {
+			return true
+		}
+	}
+	if s.lastWeekday && day == nearestWeekday(d, last) {
+		return true
+	}
+	for _, n := range s.nearWeekdays {
+		if n <= last && day == nearestWeekday(d, n) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *cronSpec) matchesDow(d time.Time) bool {
+	wd, day := int(d.Weekday()), d.Day()
+	if s.weekdays[wd] {
+		return true
+	}
+	for _, nth := range s.nthWeekdays {
+		if wd == nth[0] && (day-1)/7+1 == nth[1] {
+			return true
+		}
+	}
+	for _, w := range s.lastWeekdays {
+		if wd == w && day+7 > lastDayOfMonth(d) {
+			return true
+		}
+	}
+	return false
+}
+
+// nearestWeekday devolve o dia de seg a sex mais próximo do dia n no mês de
+// d, sem sair do mês: sábado recua pra sexta (ou, no dia 1, avança pra
+// segunda), domingo avança pra segunda (ou, no último dia, recua pra sexta).
+func nearestWeekday(d time.Time, n int) int {
+	last := lastDayOfMonth(d)
+	switch time.Date(d.Year(), d.Month(), n, 0, 0, 0, 0, d.Location()).Weekday() {
+	case time.Saturday:
+		if n == 1 {
+			return 3
+		}
+		return n - 1
+	case time.Sunday:
+		if n == last {
+			return n - 2
+		}
+		return n + 1
+	}
+	return n
+}
+
+// lastDayOfMonth usa o truque do dia 0 do mês seguinte (= último do atual).
+func lastDayOfMonth(d time.Time) int {
+	return time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, d.Location()).Day()
+}
@@ -6,32 +6,95 @@ import (
 	"strings"
 	"time"
 
+	"github.com/EnzzoHosaki/rps-maestro/internal/calendar"
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
 	"github.com/robfig/cron/v3"
 )
 
-// ParseSchedule converte uma expressão de 5 campos numa cron.Schedule, sem
-// fixar fuso (o SpecSchedule herda time.Local). Use pra VALIDAÇÃO, onde o fuso
-// não importa. Pro runtime do scheduler use ParseScheduleTZ.
+// ParseSchedule converte uma expressão cron numa cron.Schedule, sem fixar
+// fuso (o SpecSchedule herda time.Local). Use pra VALIDAÇÃO, onde o fuso não
+// importa. Pro runtime do scheduler use ParseScheduleTZ.
 func ParseSchedule(expr string) (cron.Schedule, error) {
 	return ParseScheduleTZ(expr, "")
 }
 
 // ParseScheduleTZ é como ParseSchedule, mas com fuso explícito.
 //
-// O cron padrão (robfig ParseStandard) NÃO entende o token `L` (último dia do
-// mês). Pra suportar agendamentos tipo "dias 8,15,22 e o último dia", quando o
-// campo dia-do-mês contém `L` a gente usa um Schedule próprio (monthDaysSchedule),
-// cujo Next respeita o fuso do `t` que o runner passa (cron.WithLocation);
-// caso contrário cai no ParseStandard normal (que já cobre listas como 8,15,22).
+// Aceita 5 campos ou 6 (segundos no início) e a sintaxe estendida estilo
+// Quartz — `L`, `L-3`, `15W`, `LW` no dia-do-mês, `6#1` e `5L` no
+// dia-da-semana (ver cron_extended.go). Toda expressão passa pelo parser
+// próprio, que aponta o campo inválido no erro; as que só usam cron padrão
+// rodam no ParseStandard do robfig, as demais num Schedule próprio
+// (cronSpecSchedule), cujo Next respeita o fuso do `t` que o runner passa
+// (cron.WithLocation). Descritores (`@daily`, `@every 1h`) vão direto pro robfig.
 //
 // Quando tzName != "" injeta o prefixo CRON_TZ= na expressão. Sem isso, o
 // SpecSchedule do robfig herda time.Local — que no container alpine é UTC (sem
 // tzdata/TZ), fazendo o cron disparar 3h adiantado. Com o prefixo o disparo fica
 // preso ao fuso pretendido, independente do TZ do container.
+//
+// O fuso é por agendamento: o runner do cron roda num fuso só (o padrão do
+// processo), mas cada Schedule converte o `t` recebido pro próprio fuso antes
+// de calcular o Next — então "0 8 * * *" em America/Manaus dispara às 08:00 de
+// Manaus mesmo num processo em America/Sao_Paulo.
+//
+// Dia útil no campo dia-do-mês (`BD`, `5BD`, `LBD`) sem calendário considera só
+// fim de semana como dia não útil — use ParseScheduleWithCalendar pro runtime.
 func ParseScheduleTZ(expr, tzName string) (cron.Schedule, error) {
+	return parseSchedule(expr, tzName, nil)
+}
+
+// ParseScheduleWithCalendar é o parser do runtime pra agendamentos com
+// calendário: os tokens de dia útil contam os feriados de `cal`, e a
+// holidayPolicy (models.Holiday*) decide o que fazer com disparos que caem em
+// dia não útil. cal nil = só fim de semana é dia não útil.
+func ParseScheduleWithCalendar(expr, tzName string, cal *calendar.Calendar, holidayPolicy string) (cron.Schedule, error) {
+	sched, err := parseSchedule(expr, tzName, cal)
+	if err != nil {
+		return nil, err
+	}
+	return withHolidayPolicy(sched, tzName, cal, holidayPolicy)
+}
+
+// withHolidayPolicy embrulha sched num calendarSchedule quando a policy pede
+// (skip / next_business_day); ignore devolve sched como está.
+func withHolidayPolicy(sched cron.Schedule, tzName string, cal *calendar.Calendar, holidayPolicy string) (cron.Schedule, error) {
+	if holidayPolicy != models.HolidaySkip && holidayPolicy != models.HolidayNextBusinessDay {
+		return sched, nil
+	}
+	loc, err := loadLocation(tzName)
+	if err != nil {
+		return nil, err
+	}
+	return calendarSchedule{inner: sched, cal: cal, policy: holidayPolicy, loc: loc}, nil
+}
+
+func parseSchedule(expr, tzName string, cal *calendar.Calendar) (cron.Schedule, error) {
 	fields := strings.Fields(expr)
-	if len(fields) == 5 && strings.ContainsAny(fields[2], "Ll") {
-		return parseMonthDaysSchedule(fields)
+	if len(fields) == 5 && strings.Contains(strings.ToUpper(fields[2]), "BD") {
+		loc, err := loadLocation(tzName)
+		if err != nil {
+			return nil, err
+		}
+		return parseBusinessDaySchedule(fields, cal, loc)
+	}
+	if strings.HasPrefix(strings.TrimSpace(expr), "@") {
+		if tzName != "" {
+			expr = "CRON_TZ=" + tzName + " " + expr
+		}
+		return cron.ParseStandard(expr)
+	}
+
+	spec, err := parseCronSpec(fields)
+	if err != nil {
+		return nil, err
+	}
+	if spec.extended() {
+		loc, err := loadLocation(tzName)
+		if err != nil {
+			return nil, err
+		}
+		return cronSpecSchedule{spec: spec, loc: loc}, nil
 	}
 	if tzName != "" {
 		expr = "CRON_TZ=" + tzName + " " + expr
@@ -39,16 +102,29 @@ func ParseScheduleTZ(expr, tzName string) (cron.Schedule, error) {
 	return cron.ParseStandard(expr)
 }
 
-// monthDaysSchedule dispara em HH:MM nos dias do mês listados em `days` e/ou no
-// último dia do mês quando `last` é true. Mês e dia-da-semana são ignorados
-// (sempre "*" nesse modo).
-type monthDaysSchedule struct {
+// loadLocation devolve nil pra tzName vazio (= usar o fuso do `t` no Next).
+func loadLocation(tzName string) (*time.Location, error) {
+	if tzName == "" {
+		return nil, nil
+	}
+	loc, err := time.LoadLocation(tzName)
+	if err != nil {
+		return nil, fmt.Errorf("fuso horário inválido %q: %w", tzName, err)
+	}
+	return loc, nil
+}
+
+// businessDaySchedule dispara em HH:MM em dias úteis do calendário: todos
+// (`BD`), o n-ésimo do mês (`5BD`) ou o último do mês (`LBD`). Mês e
+// dia-da-semana precisam ser "*". loc nil = usa o fuso do `t` recebido no Next.
+type businessDaySchedule struct {
 	minute, hour int
-	days         map[int]bool
-	last         bool
+	nth          int // 0 = todo dia útil; -1 = último do mês; n = n-ésimo
+	cal          *calendar.Calendar
+	loc          *time.Location
 }
 
-func parseMonthDaysSchedule(f []string) (cron.Schedule, error) {
+func parseBusinessDaySchedule(f []string, cal *calendar.Calendar, loc *time.Location) (cron.Schedule, error) {
 	minute, err := atoiRange(f[0], 0, 59)
 	if err != nil {
 		return nil, fmt.Errorf("minuto inválido: %w", err)
@@ -57,40 +133,37 @@ func parseMonthDaysSchedule(f []string) (cron.Schedule, error) {
 	if err != nil {
 		return nil, fmt.Errorf("hora inválida: %w", err)
 	}
-	// `L` só é suportado no modo mensal por dia-do-mês — mês e dia-da-semana
-	// precisam ser curinga.
 	if f[3] != "*" || f[4] != "*" {
-		return nil, fmt.Errorf("`L` (último dia) só vale com mês e dia-da-semana = *")
+		return nil, fmt.Errorf("dia útil (`BD`) só vale com mês e dia-da-semana = *")
 	}
 
-	sched := monthDaysSchedule{minute: minute, hour: hour, days: map[int]bool{}}
-	for _, part := range strings.Split(f[2], ",") {
-		if strings.EqualFold(part, "L") {
-			sched.last = true
-			continue
-		}
-		d, err := atoiRange(part, 1, 31)
-		if err != nil {
-			return nil, fmt.Errorf("dia do mês inválido %q: %w", part, err)
+	sched := businessDaySchedule{minute: minute, hour: hour, cal: cal, loc: loc}
+	switch token := strings.ToUpper(f[2]); token {
+	case "BD":
+	case "LBD":
+		sched.nth = -1
+	default:
+		n, err := atoiRange(strings.TrimSuffix(token, "BD"), 1, 23)
+		if err != nil || !strings.HasSuffix(token, "BD") {
+			return nil, fmt.Errorf("dia útil inválido %q: use BD, nBD (1-23) ou LBD", f[2])
 		}
-		sched.days[d] = true
-	}
-	if len(sched.days) == 0 && !sched.last {
-		return nil, fmt.Errorf("nenhum dia do mês especificado")
+		sched.nth = n
 	}
 	return sched, nil
 }
 
-// Next devolve o próximo disparo estritamente depois de t. Varre dia a dia
-// (via AddDate, que normaliza fim de mês / ano bissexto), no máximo ~13 meses.
-func (s monthDaysSchedule) Next(t time.Time) time.Time {
+func (s businessDaySchedule) Next(t time.Time) time.Time {
+	orig := t.Location()
+	if s.loc != nil {
+		t = t.In(s.loc)
+	}
 	loc := t.Location()
 	d := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
 	for i := 0; i < 400; i++ {
 		if s.matches(d) {
 			fire := time.Date(d.Year(), d.Month(), d.Day(), s.hour, s.minute, 0, 0, loc)
 			if fire.After(t) {
-				return fire
+				return fire.In(orig)
 			}
 		}
 		d = d.AddDate(0, 0, 1)
@@ -98,16 +171,97 @@ func (s monthDaysSchedule) Next(t time.Time) time.Time {
 	return time.Time{}
 }
 
-func (s monthDaysSchedule) matches(d time.Time) bool {
-	if s.days[d.Day()] {
+func (s businessDaySchedule) matches(d time.Time) bool {
+	switch {
+	case !s.cal.IsBusinessDay(d):
+		return false
+	case s.nth == 0:
 		return true
+	case s.nth < 0:
+		return s.cal.NextBusinessDay(d).Month() != d.Month()
+	default:
+		return s.cal.BusinessDayOfMonth(d) == s.nth
+	}
+}
+
+// maxHolidayShift é o maior adiamento possível de next_business_day: nenhuma
+// sequência real de dias não úteis (fim de semana + feriados + pontes) chega
+// perto disso.
+const maxHolidayShift = 10 * 24 * time.Hour
+
+// calendarSchedule aplica a holiday_policy sobre outra Schedule: disparos em
+// dia não útil são pulados (skip) ou adiados pro próximo dia útil no mesmo
+// horário (next_business_day).
+type calendarSchedule struct {
+	inner  cron.Schedule
+	cal    *calendar.Calendar
+	policy string
+	loc    *time.Location
+}
+
+func (s calendarSchedule) Next(t time.Time) time.Time {
+	if s.policy == models.HolidaySkip {
+		c := t
+		for i := 0; i < 5000; i++ {
+			c = s.inner.Next(c)
+			if c.IsZero() {
+				break
+			}
+			day := s.local(c)
+			if s.cal.IsBusinessDay(day) {
+				return c
+			}
+			// Dia não útil: pula o resto do dia de uma vez.
+			c = nextDayStart(day).Add(-time.Nanosecond)
+		}
+		return time.Time{}
+	}
+
+	// next_business_day: um disparo original ANTERIOR a t (sábado 08:00) pode
+	// virar um disparo adiado DEPOIS de t (segunda 08:00), e adiamentos não
+	// preservam a ordem. Então varre os originais desde t-maxHolidayShift e
+	// fica com o menor adiado > t; para quando o original passa do melhor
+	// (adiar nunca antecipa).
+	var best time.Time
+	c := t.Add(-maxHolidayShift)
+	for i := 0; i < 5000; i++ {
+		c = s.inner.Next(c)
+		if c.IsZero() || (!best.IsZero() && !c.Before(best)) {
+			break
+		}
+		day := s.local(c)
+		if s.cal.IsBusinessDay(day) {
+			if !c.After(t) {
+				// Dia útil inteiro antes de t não gera candidato: pula pro fim
+				// do dia em vez de iterar disparo a disparo (crons de minuto).
+				if end := nextDayStart(day); !end.After(t) {
+					c = end.Add(-time.Nanosecond)
+				}
+				continue
+			}
+			if best.IsZero() || c.Before(best) {
+				best = c
+			}
+			continue
+		}
+		shifted := s.cal.NextBusinessDay(day).In(c.Location())
+		if shifted.After(t) && (best.IsZero() || shifted.Before(best)) {
+			best = shifted
+		}
 	}
-	return s.last && d.Day() == lastDayOfMonth(d)
+	return best
+}
+
+// nextDayStart devolve a meia-noite do dia seguinte a d, no fuso de d.
+func nextDayStart(d time.Time) time.Time {
+	return time.Date(d.Year(), d.Month(), d.Day()+1, 0, 0, 0, 0, d.Location())
 }
 
-// lastDayOfMonth usa o truque do dia 0 do mês seguinte (= último do atual).
-func lastDayOfMonth(d time.Time) int {
-	return time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, d.Location()).Day()
+func (s calendarSchedule) local(t time.Time) time.Time {
+	if s.loc != nil {
+		return t.In(s.loc)
+	}
+	return t
 }
 
 func atoiRange(s string, lo, hi int) (int, error) {
@@ -121,35 +275,95 @@ func atoiRange(s string, lo, hi int) (int, error) {
 	return n, nil
 }
 
+// prevFireMaxBack é até onde PrevFire procura um disparo anterior.
+const prevFireMaxBack = 400 * 24 * time.Hour
+
 // PrevFire devolve o disparo mais recente ANTES de `now` para uma schedule
-// qualquer (cron.Schedule só expõe Next, não Prev). Faz uma busca com janela
-// exponencial: tenta 1d, 7d, 40d, 200d atrás e, na primeira janela que contém
-// um disparo, anda pra frente guardando o último antes de `now`. Isso é
-// eficiente tanto pra schedules sub-diárias (janela 1d) quanto mensais (40d).
-// Devolve zero se não achar (ex.: schedule sem disparo no último ~ano).
+// qualquer (cron.Schedule só expõe Next, não Prev). Dobra a janela pra trás a
+// partir de 1s até achar um disparo dentro dela e depois faz busca binária
+// entre a última janela vazia e a primeira com disparo — o custo é
+// logarítmico tanto pra schedules de segundos quanto mensais, sem andar
+// disparo a disparo. Devolve zero se não achar (ex.: schedule sem disparo no
+// último ~ano).
 func PrevFire(sched cron.Schedule, now time.Time) time.Time {
 	if sched == nil {
 		return time.Time{}
 	}
-	for _, back := range []time.Duration{
-		24 * time.Hour,
-		7 * 24 * time.Hour,
-		40 * 24 * time.Hour,
-		200 * 24 * time.Hour,
-	} {
+	// hasFire(t): há disparo em (t, now). Vale pra todo t antes do último
+	// disparo e pra nenhum a partir dele.
+	hasFire := func(t time.Time) bool {
+		next := sched.Next(t)
+		return !next.IsZero() && next.Before(now)
+	}
+
+	hi := now
+	var lo time.Time
+	for back := time.Second; ; back *= 2 {
+		if back > prevFireMaxBack {
+			back = prevFireMaxBack
+		}
 		t := now.Add(-back)
-		var prev time.Time
-		for i := 0; i < 5000; i++ {
-			next := sched.Next(t)
-			if next.IsZero() || !next.Before(now) {
-				break
-			}
-			prev = next
-			t = next
+		if hasFire(t) {
+			lo = t
+			break
+		}
+		if back == prevFireMaxBack {
+			return time.Time{}
+		}
+		hi = t
+	}
+
+	for hi.Sub(lo) > time.Second {
+		mid := lo.Add(hi.Sub(lo) / 2)
+		if hasFire(mid) {
+			lo = mid
+		} else {
+			hi = mid
 		}
-		if !prev.IsZero() {
+	}
+
+	prev := sched.Next(lo)
+	for {
+		next := sched.Next(prev)
+		if next.IsZero() || !next.Before(now) {
 			return prev
 		}
+		prev = next
 	}
-	return time.Time{}
+}
+
+// MissedFires devolve os disparos de `sched` no intervalo [from, now), em
+// ordem cronológica — usado pra recuperar execuções perdidas com o backend
+// fora do ar (from = next_run_at salvo antes da queda).
+//
+// lookback > 0 corta disparos mais antigos que now-lookback. Se sobrarem mais
+// que `limit` disparos, ficam os `limit` mais recentes (os antigos são os
+// menos úteis de repor e uma schedule de minuto em minuto geraria milhares).
+func MissedFires(sched cron.Schedule, from, now time.Time, lookback time.Duration, limit int) []time.Time {
+	if sched == nil || limit <= 0 || !from.Before(now) {
+		return nil
+	}
+	start := from
+	if lookback > 0 {
+		if floor := now.Add(-lookback); floor.After(start) {
+			start = floor
+		}
+	}
+
+	// Next é estritamente depois de t; recuar 1s inclui `start` se ele mesmo
+	// for um disparo (o caso normal: start = next_run_at).
+	t := start.Add(-time.Second)
+	var out []time.Time
+	for i := 0; i < 100000; i++ {
+		next := sched.Next(t)
+		if next.IsZero() || !next.Before(now) {
+			break
+		}
+		out = append(out, next)
+		if len(out) > limit {
+			out = out[1:]
+		}
+		t = next
+	}
+	return out
 }
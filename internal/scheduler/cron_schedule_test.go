@@ -1,8 +1,12 @@
 package scheduler
 
 import (
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/calendar"
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
 )
 
 func mustParse(t *testing.T, expr string) interface{ Next(time.Time) time.Time } {
@@ -55,7 +59,7 @@ func TestParseSchedule_routesStandard(t *testing.T) {
 }
 
 func TestParseSchedule_invalid(t *testing.T) {
-	for _, expr := range []string{"0 8 8,L 6 *", "0 8 L * 1", "xx"} {
+	for _, expr := range []string{"0 8 32 * *", "0 8 L * 1", "0 8 15W * MON", "0 8 * * 6#6", "xx"} {
 		if _, err := ParseSchedule(expr); err == nil {
 			t.Errorf("ParseSchedule(%q) deveria falhar", expr)
 		}
@@ -83,6 +87,39 @@ func TestPrevFire(t *testing.T) {
 	}
 }
 
+// Com campo de segundos a schedule dispara milhares de vezes por dia; o
+// PrevFire tem que achar o disparo imediatamente anterior mesmo assim.
+func TestPrevFire_seconds(t *testing.T) {
+	now := time.Date(2026, time.June, 1, 8, 0, 10, 0, time.Local)
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/10 * * * * *", time.Date(2026, time.June, 1, 8, 0, 0, 0, time.Local)},
+		{"*/5 * * * * *", time.Date(2026, time.June, 1, 8, 0, 5, 0, time.Local)},
+		{"30 0 8 * * *", time.Date(2026, time.May, 31, 8, 0, 30, 0, time.Local)},
+	}
+	for _, c := range cases {
+		sc, err := ParseSchedule(c.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := PrevFire(sc, now); !got.Equal(c.want) {
+			t.Errorf("%s: PrevFire = %s; quer %s", c.expr, got, c.want)
+		}
+	}
+
+	// Tick alguns ms depois do disparo das 08:00:10, truncado ao segundo.
+	sec := &models.Schedule{CronExpression: "*/10 * * * * *"}
+	tick := now.Add(3 * time.Millisecond)
+	if got, want := PrevFire(mustParse(t, sec.CronExpression), tick.Truncate(fireResolution(sec))), cases[0].want; !got.Equal(want) {
+		t.Errorf("tick: PrevFire = %s; quer %s", got, want)
+	}
+	if r := fireResolution(&models.Schedule{CronExpression: "0 8 * * *"}); r != time.Minute {
+		t.Errorf("fireResolution de 5 campos = %s; quer 1m", r)
+	}
+}
+
 func TestExpandDatePlaceholders_prevRun(t *testing.T) {
 	now := d(2026, time.June, 8, 8, 0)
 	prev := d(2026, time.May, 31, 8, 0)
@@ -91,7 +128,7 @@ func TestExpandDatePlaceholders_prevRun(t *testing.T) {
 		"fim":    "{{yesterday}}",
 		"hoje":   "{{today}}",
 	}
-	out := ExpandDatePlaceholders(params, now, prev)
+	out := ExpandDatePlaceholders(params, DateContext{Now: now, PrevRun: prev})
 	if out["inicio"] != "01/06/2026" {
 		t.Errorf("prev_run+1 = %v; quer 01/06/2026", out["inicio"])
 	}
@@ -103,7 +140,7 @@ func TestExpandDatePlaceholders_prevRun(t *testing.T) {
 	}
 
 	// prev_run com zero (execução manual) cai pra hoje, sem vazar o token
-	out2 := ExpandDatePlaceholders(map[string]interface{}{"x": "{{prev_run}}"}, now, time.Time{})
+	out2 := ExpandDatePlaceholders(map[string]interface{}{"x": "{{prev_run}}"}, DateContext{Now: now})
 	if out2["x"] != "08/06/2026" {
 		t.Errorf("prev_run sem contexto = %v; quer fallback 08/06/2026", out2["x"])
 	}
@@ -150,3 +187,188 @@ func TestParseScheduleTZ_pinnedTimezone(t *testing.T) {
 		t.Errorf("sem fuso deveria diferir do com-fuso quando time.Local=UTC")
 	}
 }
+
+func TestMissedFires(t *testing.T) {
+	sc, err := ParseSchedule("0 6 * * *") // todo dia às 06:00
+	if err != nil {
+		t.Fatal(err)
+	}
+	// backend caiu antes do disparo de 10/06 06:00 e voltou em 13/06 09:00:
+	// perdeu 10, 11, 12 e 13.
+	from := d(2026, time.June, 10, 6, 0)
+	now := d(2026, time.June, 13, 9, 0)
+
+	got := MissedFires(sc, from, now, 0, 50)
+	want := []time.Time{
+		d(2026, time.June, 10, 6, 0),
+		d(2026, time.June, 11, 6, 0),
+		d(2026, time.June, 12, 6, 0),
+		d(2026, time.June, 13, 6, 0),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("MissedFires = %v; quer %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("MissedFires[%d] = %s; quer %s", i, got[i], want[i])
+		}
+	}
+
+	// lookback de 48h corta 10 e 11
+	if got := MissedFires(sc, from, now, 48*time.Hour, 50); len(got) != 2 || !got[0].Equal(d(2026, time.June, 12, 6, 0)) {
+		t.Errorf("MissedFires com lookback 48h = %v; quer [12/06 06:00, 13/06 06:00]", got)
+	}
+
+	// limit mantém os mais recentes
+	if got := MissedFires(sc, from, now, 0, 1); len(got) != 1 || !got[0].Equal(d(2026, time.June, 13, 6, 0)) {
+		t.Errorf("MissedFires com limit 1 = %v; quer [13/06 06:00]", got)
+	}
+
+	// next_run_at ainda no futuro → nada perdido
+	if got := MissedFires(sc, d(2026, time.June, 14, 6, 0), now, 0, 50); len(got) != 0 {
+		t.Errorf("MissedFires com from no futuro = %v; quer vazio", got)
+	}
+}
+
+func TestParseScheduleTZ_perScheduleZone(t *testing.T) {
+	// O runner passa `t` no fuso padrão (São Paulo); a schedule de Manaus
+	// (UTC-4) tem que disparar às 08:00 de Manaus = 12:00 UTC.
+	sp, _ := time.LoadLocation("America/Sao_Paulo")
+	from := time.Date(2026, time.June, 10, 0, 0, 0, 0, sp)
+	want := time.Date(2026, time.June, 10, 12, 0, 0, 0, time.UTC)
+
+	for _, expr := range []string{"0 8 * * *", "0 8 10,L * *"} {
+		s, err := ParseScheduleTZ(expr, "America/Manaus")
+		if err != nil {
+			t.Fatalf("ParseScheduleTZ(%q) erro: %v", expr, err)
+		}
+		if got := s.Next(from); !got.Equal(want) {
+			t.Errorf("%q: Next = %s; quer %s", expr, got.UTC(), want)
+		}
+	}
+
+	if _, err := ParseScheduleTZ("0 8 L * *", "America/Nowhere"); err == nil {
+		t.Error("fuso inexistente deveria falhar")
+	}
+}
+
+func TestBusinessDaySchedules(t *testing.T) {
+	cal := calendar.New(models.Calendar{Name: "BR", IncludeNational: true}, nil)
+
+	// 5º dia útil de jan/2026: 1º é feriado → 2,5,6,7,8 → dia 8.
+	s, err := ParseScheduleWithCalendar("0 8 5BD * *", "", cal, models.HolidayIgnore)
+	if err != nil {
+		t.Fatalf("erro: %v", err)
+	}
+	if got, want := s.Next(d(2026, time.January, 1, 0, 0)), d(2026, time.January, 8, 8, 0); !got.Equal(want) {
+		t.Errorf("5BD: Next = %s; quer %s", got, want)
+	}
+
+	// Todo dia útil: sexta-feira santa (03/04/2026) e o fim de semana são pulados.
+	s, _ = ParseScheduleWithCalendar("0 8 * * *", "", cal, models.HolidaySkip)
+	if got, want := s.Next(d(2026, time.April, 2, 9, 0)), d(2026, time.April, 6, 8, 0); !got.Equal(want) {
+		t.Errorf("skip: Next = %s; quer %s", got, want)
+	}
+
+	// Dia 21 (Tiradentes, terça em 2026) adiado pra quarta, mesmo consultando
+	// depois do horário original.
+	s, _ = ParseScheduleWithCalendar("0 8 21 * *", "", cal, models.HolidayNextBusinessDay)
+	if got, want := s.Next(d(2026, time.April, 21, 12, 0)), d(2026, time.April, 22, 8, 0); !got.Equal(want) {
+		t.Errorf("next_business_day: Next = %s; quer %s", got, want)
+	}
+	if got, want := PrevFire(s, d(2026, time.May, 1, 0, 0)), d(2026, time.April, 22, 8, 0); !got.Equal(want) {
+		t.Errorf("next_business_day: PrevFire = %s; quer %s", got, want)
+	}
+}
+
+func TestExpandDatePlaceholders_formatsAndAnchors(t *testing.T) {
+	cal := calendar.New(models.Calendar{Name: "BR", IncludeNational: true}, nil)
+	// quarta 08/04/2026; sexta 03/04 é feriado (Sexta-feira Santa)
+	dc := DateContext{Now: d(2026, time.April, 8, 8, 0), Calendar: cal}
+
+	cases := map[string]string{
+		"{{yesterday|2006-01-02}}":       "2026-04-07",
+		"{{first_of_last_month|yyyyMM}}": "202603",
+		"{{today|iso}}":                  "2026-04-08",
+		"{{today|unix}}":                 "1775606400",
+		"{{today-2bd}}":                  "06/04/2026",
+		"{{today-3bd}}":                  "02/04/2026", // pula fim de semana e a sexta santa
+		"{{first_of_month-1bd}}":         "31/03/2026",
+		"{{start_of_week}}":              "06/04/2026",
+		"{{end_of_last_week}}":           "05/04/2026",
+		"{{start_of_quarter}}":           "01/04/2026",
+		"{{end_of_last_quarter|dd.MM.yy}}": "31.03.26",
+		"{{desconhecido}} e {{today}}":   "{{desconhecido}} e 08/04/2026",
+	}
+	for in, want := range cases {
+		out := ExpandDatePlaceholders(map[string]interface{}{"x": in}, dc)
+		if out["x"] != want {
+			t.Errorf("%s = %v; quer %s", in, out["x"], want)
+		}
+	}
+}
+
+func TestValidatePlaceholders(t *testing.T) {
+	errs := ValidatePlaceholders(map[string]interface{}{
+		"ok":     "{{today-2bd|yyyyMMdd}} a {{end_of_quarter}}",
+		"ruim":   "{{ontem}}",
+		"lista":  []interface{}{"{{today|}}"},
+		"numero": 3,
+		"layout": "{{yesterday|2006-01-02}}",
+		"fmt":    "{{today|foo}}",
+	})
+	if len(errs) != 3 {
+		t.Fatalf("esperava 3 erros, veio %d: %v", len(errs), errs)
+	}
+}
+
+func TestExtendedCron(t *testing.T) {
+	cases := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		// L combinado com lista de meses: último dia de jun (30), depois dez (31).
+		{"0 8 8,L 6,12 *", d(2026, time.June, 10, 0, 0), d(2026, time.June, 30, 8, 0)},
+		{"0 8 8,L 6,12 *", d(2026, time.June, 30, 9, 0), d(2026, time.December, 8, 8, 0)},
+		// L-3: 3 dias antes do último (fev/2026 tem 28 → 25).
+		{"0 8 L-3 * *", d(2026, time.February, 1, 0, 0), d(2026, time.February, 25, 8, 0)},
+		// 15W: 15/08/2026 é sábado → sexta 14.
+		{"0 8 15W * *", d(2026, time.August, 1, 0, 0), d(2026, time.August, 14, 8, 0)},
+		// 1W: 01/08/2026 é sábado → segunda 3 (não volta pro mês anterior).
+		{"0 8 1W * *", d(2026, time.July, 31, 9, 0), d(2026, time.August, 3, 8, 0)},
+		// LW: 31/05/2026 é domingo → sexta 29.
+		{"0 8 LW * *", d(2026, time.May, 1, 0, 0), d(2026, time.May, 29, 8, 0)},
+		// 6#1: primeiro sábado de ago/2026 é o dia 1.
+		{"0 8 * * 6#1", d(2026, time.July, 5, 0, 0), d(2026, time.August, 1, 8, 0)},
+		{"0 8 ? * SAT#1", d(2026, time.August, 1, 9, 0), d(2026, time.September, 5, 8, 0)},
+		// 5L: última sexta de jul/2026 é 31.
+		{"0 18 * * 5L", d(2026, time.July, 1, 0, 0), d(2026, time.July, 31, 18, 0)},
+		// 6 campos: segundos.
+		{"30 */15 9 * * MON-FRI", d(2026, time.June, 1, 9, 0), time.Date(2026, time.June, 1, 9, 0, 30, 0, time.UTC)},
+		{"30 */15 9 * * MON-FRI", time.Date(2026, time.June, 1, 9, 0, 30, 0, time.UTC), time.Date(2026, time.June, 1, 9, 15, 30, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		s := mustParse(t, c.expr)
+		if got := s.Next(c.from); !got.Equal(c.want) {
+			t.Errorf("%q: Next(%s) = %s; quer %s", c.expr, c.from.Format(time.RFC3339), got.Format(time.RFC3339), c.want.Format(time.RFC3339))
+		}
+	}
+}
+
+func TestParseSchedule_fieldErrors(t *testing.T) {
+	cases := map[string]string{
+		"0 25 * * *":    "campo hora",
+		"61 * * * * *":  "campo segundo",
+		"0 8 L-40 * *":  "campo dia-do-mês",
+		"0 8 * 13 *":    "campo mês",
+		"0 8 * * FUN#1": "campo dia-da-semana",
+		"0 8 * *":       "esperados 5 campos",
+	}
+	for expr, want := range cases {
+		_, err := ParseSchedule(expr)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseSchedule(%q) erro = %v; quer mencionando %q", expr, err, want)
+		}
+	}
+}
@@ -1,137 +1,311 @@
 package scheduler
 
 import (
+	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/calendar"
 )
 
-// datePlaceholderRe casa tokens como {{today}}, {{yesterday}}, {{tomorrow}},
-// {{prev_run}} e suas variantes com offset ({{today-N}}, {{prev_run+1}}…).
-// `prev_run` = data da execução AGENDADA anterior (calculada do cron do próprio
-// agendamento); útil pra janelas tipo "{{prev_run+1}} a {{yesterday}}".
-var datePlaceholderRe = regexp.MustCompile(`\{\{(today|yesterday|tomorrow|prev_run)([+-]\d+)?\}\}`)
+// placeholderRe casa qualquer {{...}} — o conteúdo é interpretado por
+// parsePlaceholder. Tokens que não são de data ficam intactos na expansão e
+// são reportados por ValidatePlaceholders.
+var placeholderRe = regexp.MustCompile(`\{\{([^{}]*)\}\}`)
 
-// monthPlaceholderRe casa tokens ancorados no mês corrente da execução:
-// {{first_of_month}}, {{last_of_month}}, {{first_of_last_month}},
-// {{last_of_last_month}}. Esses tokens não aceitam offset numérico — a
-// semântica de "1º dia do mês anterior, menos 3 dias" seria ambígua.
-var monthPlaceholderRe = regexp.MustCompile(`\{\{(first_of_month|last_of_month|first_of_last_month|last_of_last_month)\}\}`)
+// placeholderBodyRe é a gramática de um token de data:
+//
+//	<âncora>[<offset>][|<formato>]
+//
+// offset = +N / -N (dias corridos) ou +Nbd / -Nbd (dias úteis do calendário
+// do agendamento). Espaços em volta são tolerados.
+var placeholderBodyRe = regexp.MustCompile(`^\s*([a-z_]+)\s*(?:([+-]\d+)(bd)?)?\s*(?:\|(.*))?$`)
 
 // dateLayoutBR é o formato padrão do projeto (mesmo aceito pelos workers
-// de NFe/NFCe). Se mais formatos virarem necessários, expandir a sintaxe
-// pra {{today-1|2006-01-02}} ou similar — hoje não tem demanda.
+// de NFe/NFCe), usado quando o token não tem |formato.
 const dateLayoutBR = "02/01/2006"
 
+// dateAnchors resolve cada âncora pra uma data (00:00 no fuso de `now`).
+var dateAnchors = map[string]func(dc DateContext) time.Time{
+	"today":     func(dc DateContext) time.Time { return startOfDay(dc.Now) },
+	"yesterday": func(dc DateContext) time.Time { return startOfDay(dc.Now).AddDate(0, 0, -1) },
+	"tomorrow":  func(dc DateContext) time.Time { return startOfDay(dc.Now).AddDate(0, 0, 1) },
+	// prev_run = data da execução AGENDADA anterior (calculada do cron do
+	// próprio agendamento); útil pra janelas tipo "{{prev_run+1}} a {{yesterday}}".
+	// Sem PrevRun (ex.: execução manual) cai pra hoje como melhor esforço, pra
+	// nunca vazar o token literal pro worker.
+	"prev_run": func(dc DateContext) time.Time {
+		if dc.PrevRun.IsZero() {
+			return startOfDay(dc.Now)
+		}
+		return startOfDay(dc.PrevRun.In(dc.Now.Location()))
+	},
+
+	// Mês: usa o truque do time.Date com dia=0 (= último dia do mês anterior)
+	// e mês+1 (Go normaliza overflow) — funciona pra fevereiro bissexto também.
+	"first_of_month":      func(dc DateContext) time.Time { return monthAnchor(dc.Now, 0, 1) },
+	"last_of_month":       func(dc DateContext) time.Time { return monthAnchor(dc.Now, 1, 0) },
+	"first_of_last_month": func(dc DateContext) time.Time { return monthAnchor(dc.Now, -1, 1) },
+	"last_of_last_month":  func(dc DateContext) time.Time { return monthAnchor(dc.Now, 0, 0) },
+
+	// Semana: segunda a domingo (padrão brasileiro/ISO).
+	"start_of_week":      func(dc DateContext) time.Time { return weekStart(dc.Now) },
+	"end_of_week":        func(dc DateContext) time.Time { return weekStart(dc.Now).AddDate(0, 0, 6) },
+	"start_of_last_week": func(dc DateContext) time.Time { return weekStart(dc.Now).AddDate(0, 0, -7) },
+	"end_of_last_week":   func(dc DateContext) time.Time { return weekStart(dc.Now).AddDate(0, 0, -1) },
+
+	// Trimestre civil (jan-mar, abr-jun, jul-set, out-dez).
+	"start_of_quarter":      func(dc DateContext) time.Time { return quarterStart(dc.Now, 0) },
+	"end_of_quarter":        func(dc DateContext) time.Time { return quarterStart(dc.Now, 3).AddDate(0, 0, -1) },
+	"start_of_last_quarter": func(dc DateContext) time.Time { return quarterStart(dc.Now, -3) },
+	"end_of_last_quarter":   func(dc DateContext) time.Time { return quarterStart(dc.Now, 0).AddDate(0, 0, -1) },
+}
+
+// DateContext é a referência da expansão: Now é o instante do disparo (já no
+// fuso do agendamento), PrevRun o disparo agendado anterior (zero quando
+// indisponível) e Calendar o calendário pros offsets em dias úteis (nil = só
+// fim de semana é dia não útil).
+type DateContext struct {
+	Now      time.Time
+	PrevRun  time.Time
+	Calendar *calendar.Calendar
+}
+
 // ExpandDatePlaceholders percorre o map de parâmetros recursivamente e
-// substitui qualquer ocorrência de placeholder de data por uma string
-// formatada em dd/MM/yyyy.
+// substitui qualquer placeholder de data pela data formatada.
+//
+// O cálculo é feito relativo a dc.Now — passar o instante do disparo no
+// caller. Isso facilita testar e evita que a expansão fique presa a um
+// instante salvo em outro lugar.
+//
+// Âncoras suportadas:
 //
-// O cálculo é feito relativo a `now` — passar time.Now() no caller. Isso
-// facilita testar e evita que a expansão fique presa a um instante salvo
-// em outro lugar.
+//	{{today}} {{yesterday}} {{tomorrow}}
+//	{{prev_run}}                                → execução agendada anterior
+//	{{first_of_month}} {{last_of_month}}
+//	{{first_of_last_month}} {{last_of_last_month}}
+//	{{start_of_week}} {{end_of_week}}           → segunda / domingo
+//	{{start_of_last_week}} {{end_of_last_week}}
+//	{{start_of_quarter}} {{end_of_quarter}}
+//	{{start_of_last_quarter}} {{end_of_last_quarter}}
 //
-// Tokens suportados:
+// Qualquer âncora aceita offset e formato:
 //
-//	{{today}}                 → hoje
-//	{{yesterday}}             → ontem (alias de {{today-1}})
-//	{{tomorrow}}              → amanhã (alias de {{today+1}})
-//	{{today-N}}               → N dias antes de hoje (N inteiro)
-//	{{today+N}}               → N dias depois de hoje
-//	{{prev_run}}              → data da execução agendada anterior
-//	{{prev_run+N}} / -N       → N dias depois/antes da execução anterior
-//	{{first_of_month}}        → 1º dia do mês corrente
-//	{{last_of_month}}         → último dia do mês corrente
-//	{{first_of_last_month}}   → 1º dia do mês anterior
-//	{{last_of_last_month}}    → último dia do mês anterior
+//	{{today-2}}                  → 2 dias corridos antes
+//	{{today-2bd}}                → 2 dias úteis antes
+//	{{first_of_month-1bd}}       → último dia útil do mês anterior
+//	{{yesterday|2006-01-02}}     → layout Go
+//	{{first_of_last_month|yyyyMM}} → tokens yyyy yy MM dd HH mm ss
+//	{{today|iso}} {{today|br}} {{today|unix}}
 //
-// Combinações em uma mesma string são suportadas: "{{today-2}} a {{yesterday}}"
-// vira "17/05/2026 a 18/05/2026" se hoje for 19/05/2026.
-// prevRun é a data da execução agendada anterior (zero quando indisponível —
-// ex.: execução manual sem contexto de cron). Quando zero, {{prev_run}} cai
-// pra `now` como melhor esforço, pra nunca vazar o token literal pro worker.
-func ExpandDatePlaceholders(params map[string]interface{}, now time.Time, prevRun time.Time) map[string]interface{} {
+// Sem formato sai em dd/MM/yyyy. Combinações em uma mesma string são
+// suportadas: "{{today-2}} a {{yesterday}}" vira "17/05/2026 a 18/05/2026" se
+// hoje for 19/05/2026. Tokens desconhecidos ficam intactos — a validação no
+// cadastro do agendamento (ValidatePlaceholders) impede que cheguem aqui.
+func ExpandDatePlaceholders(params map[string]interface{}, dc DateContext) map[string]interface{} {
 	if params == nil {
 		return nil
 	}
 	out := make(map[string]interface{}, len(params))
 	for k, v := range params {
-		out[k] = expandValue(v, now, prevRun)
+		out[k] = expandValue(v, dc)
 	}
 	return out
 }
 
-func expandValue(v interface{}, now time.Time, prevRun time.Time) interface{} {
+func expandValue(v interface{}, dc DateContext) interface{} {
 	switch x := v.(type) {
 	case string:
-		return expandString(x, now, prevRun)
+		return expandString(x, dc)
 	case []interface{}:
 		out := make([]interface{}, len(x))
 		for i, item := range x {
-			out[i] = expandValue(item, now, prevRun)
+			out[i] = expandValue(item, dc)
 		}
 		return out
 	case map[string]interface{}:
-		return ExpandDatePlaceholders(x, now, prevRun)
+		return ExpandDatePlaceholders(x, dc)
 	default:
 		return v
 	}
 }
 
-func expandString(s string, now time.Time, prevRun time.Time) string {
-	s = datePlaceholderRe.ReplaceAllStringFunc(s, func(match string) string {
-		sub := datePlaceholderRe.FindStringSubmatch(match)
-		keyword := sub[1]
-		offsetStr := sub[2]
-
-		// prev_run tem base própria (a execução anterior); os demais são
-		// relativos a hoje.
-		base := now
-		days := 0
-		switch keyword {
-		case "yesterday":
-			days = -1
-		case "tomorrow":
-			days = 1
-		case "prev_run":
-			if !prevRun.IsZero() {
-				base = prevRun
+func expandString(s string, dc DateContext) string {
+	return placeholderRe.ReplaceAllStringFunc(s, func(match string) string {
+		p, err := parsePlaceholder(placeholderRe.FindStringSubmatch(match)[1])
+		if err != nil {
+			return match
+		}
+		return p.render(dc)
+	})
+}
+
+// placeholder é um token de data já interpretado.
+type placeholder struct {
+	anchor   string
+	offset   int
+	business bool
+	format   string
+}
+
+func parsePlaceholder(body string) (placeholder, error) {
+	sub := placeholderBodyRe.FindStringSubmatch(body)
+	if sub == nil {
+		return placeholder{}, fmt.Errorf("sintaxe inválida")
+	}
+	p := placeholder{anchor: sub[1], business: sub[3] != "", format: strings.TrimSpace(sub[4])}
+	if _, ok := dateAnchors[p.anchor]; !ok {
+		return placeholder{}, fmt.Errorf("âncora desconhecida %q", p.anchor)
+	}
+	if sub[2] != "" {
+		n, err := strconv.Atoi(sub[2])
+		if err != nil || n < -3660 || n > 3660 {
+			return placeholder{}, fmt.Errorf("offset inválido %q", sub[2])
+		}
+		p.offset = n
+	}
+	if strings.Contains(body, "|") && p.format == "" {
+		return placeholder{}, fmt.Errorf("formato vazio após |")
+	}
+	if !knownFormat(p.format) {
+		return placeholder{}, fmt.Errorf("formato desconhecido %q (use br, iso, unix, tokens yyyy/MM/dd ou layout Go)", p.format)
+	}
+	return p, nil
+}
+
+// formatProbe é uma data qualquer pra conferir se um layout Go tem algum
+// elemento de data.
+var formatProbe = time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC)
+
+// knownFormat diz se o formato produz alguma data: um dos nomes, algum token
+// yyyy/MM/dd ou um layout Go com pelo menos um elemento. Sem isso, {{today|foo}}
+// chegaria ao worker como "foo".
+func knownFormat(format string) bool {
+	switch format {
+	case "", "br", "iso", "unix":
+		return true
+	}
+	layout := goLayout(format)
+	return formatProbe.Format(layout) != layout
+}
+
+func (p placeholder) render(dc DateContext) string {
+	d := dateAnchors[p.anchor](dc)
+	if p.business {
+		d = dc.Calendar.AddBusinessDays(d, p.offset)
+	} else {
+		d = d.AddDate(0, 0, p.offset)
+	}
+
+	switch p.format {
+	case "":
+		return d.Format(dateLayoutBR)
+	case "br":
+		return d.Format(dateLayoutBR)
+	case "iso":
+		return d.Format("2006-01-02")
+	case "unix":
+		return strconv.FormatInt(d.Unix(), 10)
+	}
+	return d.Format(goLayout(p.format))
+}
+
+// formatTokens traduz os tokens estilo yyyy/MM/dd pro layout Go, do mais
+// longo pro mais curto (yyyy antes de yy).
+var formatTokens = []struct{ token, layout string }{
+	{"yyyy", "2006"},
+	{"yy", "06"},
+	{"MM", "01"},
+	{"dd", "02"},
+	{"HH", "15"},
+	{"mm", "04"},
+	{"ss", "05"},
+}
+
+// goLayout aceita tanto um layout Go ("2006-01-02") quanto tokens
+// ("yyyyMM", "dd.MM.yyyy"). Se o formato tem algum token, traduz; senão usa
+// como layout Go.
+func goLayout(format string) string {
+	hasToken := false
+	for _, t := range formatTokens {
+		if strings.Contains(format, t.token) {
+			hasToken = true
+			break
+		}
+	}
+	if !hasToken {
+		return format
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(format); {
+		matched := false
+		for _, t := range formatTokens {
+			if strings.HasPrefix(format[i:], t.token) {
+				b.WriteString(t.layout)
+				i += len(t.token)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			b.WriteByte(format[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+// ValidatePlaceholders devolve um erro por token {{...}} inválido encontrado
+// nos parâmetros (recursivo), com o caminho do campo — ex.:
+// `start_date: {{ontem}}: âncora desconhecida "ontem"`. Vazio = tudo válido.
+func ValidatePlaceholders(params map[string]interface{}) []string {
+	var errs []string
+	validateValue("", params, &errs)
+	sort.Strings(errs)
+	return errs
+}
+
+func validateValue(path string, v interface{}, errs *[]string) {
+	switch x := v.(type) {
+	case string:
+		for _, m := range placeholderRe.FindAllStringSubmatch(x, -1) {
+			if _, err := parsePlaceholder(m[1]); err != nil {
+				*errs = append(*errs, fmt.Sprintf("%s: %s: %v", path, m[0], err))
 			}
 		}
-		if offsetStr != "" {
-			n, err := strconv.Atoi(offsetStr)
-			if err == nil {
-				days += n
+	case []interface{}:
+		for i, item := range x {
+			validateValue(fmt.Sprintf("%s[%d]", path, i), item, errs)
+		}
+	case map[string]interface{}:
+		for k, item := range x {
+			p := k
+			if path != "" {
+				p = path + "." + k
 			}
+			validateValue(p, item, errs)
 		}
+	}
+}
 
-		return base.AddDate(0, 0, days).Format(dateLayoutBR)
-	})
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
 
-	s = monthPlaceholderRe.ReplaceAllStringFunc(s, func(match string) string {
-		sub := monthPlaceholderRe.FindStringSubmatch(match)
-		return resolveMonthToken(sub[1], now).Format(dateLayoutBR)
-	})
+func monthAnchor(now time.Time, monthOffset, day int) time.Time {
+	return time.Date(now.Year(), now.Month()+time.Month(monthOffset), day, 0, 0, 0, 0, now.Location())
+}
 
-	return s
-}
-
-// resolveMonthToken devolve o time.Time correspondente ao token de mês.
-// Usa o truque do time.Date com dia=0 (= último dia do mês anterior) e
-// mês+1 (Go normaliza overflow) pra não precisar calcular comprimento de
-// mês na mão — funciona pra fevereiro bissexto também.
-func resolveMonthToken(token string, now time.Time) time.Time {
-	y, m, _ := now.Date()
-	loc := now.Location()
-	switch token {
-	case "first_of_month":
-		return time.Date(y, m, 1, 0, 0, 0, 0, loc)
-	case "last_of_month":
-		return time.Date(y, m+1, 0, 0, 0, 0, 0, loc)
-	case "first_of_last_month":
-		return time.Date(y, m-1, 1, 0, 0, 0, 0, loc)
-	case "last_of_last_month":
-		return time.Date(y, m, 0, 0, 0, 0, 0, loc)
-	}
-	return now
+func weekStart(now time.Time) time.Time {
+	back := (int(now.Weekday()) + 6) % 7 // segunda = 0
+	return startOfDay(now).AddDate(0, 0, -back)
+}
+
+func quarterStart(now time.Time, monthOffset int) time.Time {
+	first := (int(now.Month())-1)/3*3 + 1
+	return time.Date(now.Year(), time.Month(first+monthOffset), 1, 0, 0, 0, 0, now.Location())
 }
@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const (
+	// delayedDispatchInterval é a cadência com que os jobs scheduled (execução
+	// única com runAt) vencidos são publicados — também o atraso máximo sobre
+	// o horário pedido.
+	delayedDispatchInterval = 5 * time.Second
+	// delayedDispatchBatch limita quantos jobs vencidos são reivindicados por
+	// rodada; o excedente sai na rodada seguinte.
+	delayedDispatchBatch = 100
+)
+
+// runDelayedDispatcher publica, na réplica líder, os jobs scheduled cujo
+// run_at já chegou. Bloqueante até o ctx ser cancelado — chamado em goroutine
+// pelo Start. Jobs que venceram com o backend fora do ar saem no primeiro tick.
+func (s *Scheduler) runDelayedDispatcher(ctx context.Context) {
+	ticker := time.NewTicker(delayedDispatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if s.isLeader() {
+				s.dispatchDelayed(ctx)
+			}
+		}
+	}
+}
+
+func (s *Scheduler) dispatchDelayed(ctx context.Context) {
+	for {
+		jobs, err := s.jobRepo.ClaimDueScheduled(ctx, delayedDispatchBatch)
+		if err != nil {
+			log.Printf("[scheduler] %v", err)
+			return
+		}
+
+		for i := range jobs {
+			job := &jobs[i]
+			if job.Held {
+				// Fica pro runConcurrencyDispatcher.
+				log.Printf("[scheduler] job agendado %s aguardando vaga de concorrência", job.ID)
+				continue
+			}
+			// Daqui em diante o job já é pending: qualquer falha precisa
+			// marcá-lo failed, senão ele fica órfão (nada republica pending).
+			automation, err := s.automationRepo.GetByID(ctx, job.AutomationID)
+			if err != nil {
+				s.failUnpublished(ctx, job.ID, "Automação não encontrada no disparo agendado: "+err.Error())
+				log.Printf("[scheduler] job agendado %s: automação %d não encontrada: %v", job.ID, job.AutomationID, err)
+				continue
+			}
+			params, err := parseParams(job.Parameters)
+			if err != nil {
+				s.failUnpublished(ctx, job.ID, "Parâmetros inválidos no disparo agendado: "+err.Error())
+				log.Printf("[scheduler] job agendado %s: parâmetros inválidos: %v", job.ID, err)
+				continue
+			}
+			if err := s.publish(ctx, automation, job, params); err != nil {
+				log.Printf("[scheduler] %v", err)
+				continue
+			}
+			log.Printf("[scheduler] job agendado %s publicado — automação %q, runAt %s",
+				job.ID, automation.Name, job.RunAt.Format(time.RFC3339))
+		}
+
+		if len(jobs) < delayedDispatchBatch {
+			return
+		}
+	}
+}
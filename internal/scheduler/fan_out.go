@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/fanout"
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+)
+
+// fanOutReconcileInterval é a cadência da reagregação dos pais de fan-out em
+// aberto. A API já reagrega na hora quando um filho termina ou é cancelado;
+// a varredura cobre os filhos finalizados por fora dela (timeout, falha de
+// publicação).
+const fanOutReconcileInterval = 15 * time.Second
+
+// fireFanOut cria o disparo como job pai com um filho por item de
+// params[sc.FanOutParam] e publica os filhos que não ficaram retidos. O
+// overlap vale pro disparo inteiro: o pai carrega o schedule_id, e com
+// queue_after pai e filhos esperam juntos o job anterior.
+func (s *Scheduler) fireFanOut(ctx context.Context, sc *models.Schedule, automation *models.Automation, parent *models.Job, params map[string]interface{}, fireTime time.Time, overlap overlapDecision) (*models.Job, error) {
+	items, err := fanout.Split(params, *sc.FanOutParam)
+	if err != nil {
+		return nil, fmt.Errorf("fan-out inválido: %w", err)
+	}
+
+	children := make([]models.Job, 0, len(items))
+	for _, item := range items {
+		childParams, err := json.Marshal(item.Params)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao serializar parâmetros: %w", err)
+		}
+		key := item.Key
+		children = append(children, models.Job{
+			AutomationID: parent.AutomationID,
+			UserID:       parent.UserID,
+			Status:       "pending",
+			Parameters:   childParams,
+			AfterJobID:   parent.AfterJobID,
+			Trigger:      parent.Trigger,
+			FanOutItem:   &key,
+		})
+	}
+	parent.Status = "running"
+	parent.FanOutParam = sc.FanOutParam
+
+	if _, _, err := s.jobRepo.CreateFanOut(ctx, parent, children, 0); err != nil {
+		return nil, fmt.Errorf("erro ao criar job: %w", err)
+	}
+	s.finishOverlap(ctx, sc, overlap, parent.ID)
+
+	if parent.AfterJobID != nil {
+		log.Printf("[scheduler] fan-out %s (%d filho(s)) criado retido atrás do job %s — automação %q (agendamento %d, disparo de %s)",
+			parent.ID, len(children), *parent.AfterJobID, automation.Name, sc.ID, fireTime.Format("2006-01-02 15:04:05 MST"))
+		return parent, nil
+	}
+
+	failed := false
+	for i := range parent.Children {
+		child := &parent.Children[i]
+		if child.Held {
+			continue
+		}
+		if err := s.publish(ctx, automation, child, items[i].Params); err != nil {
+			log.Printf("[scheduler] %v", err)
+			failed = true
+		}
+	}
+	if failed {
+		if err := s.jobRepo.RefreshParent(ctx, parent.ID); err != nil {
+			log.Printf("[scheduler] %v", err)
+		}
+	}
+
+	log.Printf("[scheduler] fan-out %s criado com %d filho(s) — automação %q (agendamento %d, disparo de %s)",
+		parent.ID, len(children), automation.Name, sc.ID, fireTime.Format("2006-01-02 15:04:05 MST"))
+	return parent, nil
+}
+
+// runFanOutReconciler reagrega, na réplica líder, os pais de fan-out em
+// aberto. Bloqueante até o ctx ser cancelado — chamado em goroutine pelo
+// Start.
+func (s *Scheduler) runFanOutReconciler(ctx context.Context) {
+	ticker := time.NewTicker(fanOutReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.isLeader() {
+				continue
+			}
+			n, err := s.jobRepo.RefreshOpenParents(ctx)
+			if err != nil {
+				log.Printf("[scheduler] %v", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("[scheduler] %d job(s) pai de fan-out reagregado(s)", n)
+			}
+		}
+	}
+}
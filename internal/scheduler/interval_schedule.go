@@ -0,0 +1,157 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/calendar"
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+	"github.com/robfig/cron/v3"
+)
+
+// BuildSchedule monta a cron.Schedule de um agendamento de qualquer kind —
+// expressão cron (ParseScheduleWithCalendar) ou intervalo fixo
+// (NewIntervalSchedule) — com a holidayPolicy aplicada por cima. É o que o
+// Reload registra, então PrevFire, misfire e {{prev_run}} valem pros dois.
+func BuildSchedule(sc *models.Schedule, tzName string, cal *calendar.Calendar) (cron.Schedule, error) {
+	if sc.Kind != models.ScheduleKindInterval {
+		return ParseScheduleWithCalendar(sc.CronExpression, tzName, cal, sc.HolidayPolicy)
+	}
+	if sc.IntervalAnchor == nil || sc.IntervalMinutes == nil {
+		return nil, errors.New("agendamento de intervalo sem intervalAnchor/intervalMinutes")
+	}
+	sched, err := NewIntervalSchedule(*sc.IntervalAnchor, *sc.IntervalMinutes, sc.ActiveFrom, sc.ActiveTo, tzName)
+	if err != nil {
+		return nil, err
+	}
+	return withHolidayPolicy(sched, tzName, cal, sc.HolidayPolicy)
+}
+
+// NewIntervalSchedule cria uma Schedule que dispara em anchor + k*every
+// (k >= 0) — "a cada 90 minutos a partir das 07:15". activeFrom/activeTo
+// ("HH:MM", opcionais, mas os dois juntos) restringem os disparos a uma
+// janela diária no fuso tzName: disparos da grade fora de [from, to) são
+// pulados, sem deslocar a grade. to < from atravessa a meia-noite
+// ("22:00"–"06:00").
+func NewIntervalSchedule(anchor time.Time, everyMinutes int, activeFrom, activeTo *string, tzName string) (cron.Schedule, error) {
+	if everyMinutes < 1 {
+		return nil, fmt.Errorf("intervalo deve ser de pelo menos 1 minuto (recebido %d)", everyMinutes)
+	}
+	loc, err := loadLocation(tzName)
+	if err != nil {
+		return nil, err
+	}
+	s := intervalSchedule{anchor: anchor, every: time.Duration(everyMinutes) * time.Minute, loc: loc}
+
+	if (activeFrom == nil) != (activeTo == nil) {
+		return nil, errors.New("activeFrom e activeTo devem ser informados juntos")
+	}
+	if activeFrom != nil {
+		if s.from, err = parseClock(*activeFrom); err != nil {
+			return nil, fmt.Errorf("activeFrom: %w", err)
+		}
+		if s.to, err = parseClock(*activeTo); err != nil {
+			return nil, fmt.Errorf("activeTo: %w", err)
+		}
+		if s.from == s.to {
+			return nil, errors.New("activeFrom e activeTo não podem ser iguais")
+		}
+		s.window = true
+	}
+	return s, nil
+}
+
+// parseClock converte "HH:MM" em minutos desde a meia-noite.
+func parseClock(v string) (int, error) {
+	hh, mm, ok := strings.Cut(strings.TrimSpace(v), ":")
+	if !ok || len(hh) != 2 || len(mm) != 2 {
+		return 0, fmt.Errorf("horário inválido %q: use HH:MM", v)
+	}
+	h, err := strconv.Atoi(hh)
+	if err != nil || h < 0 || h > 23 {
+		return 0, fmt.Errorf("horário inválido %q: use HH:MM", v)
+	}
+	m, err := strconv.Atoi(mm)
+	if err != nil || m < 0 || m > 59 {
+		return 0, fmt.Errorf("horário inválido %q: use HH:MM", v)
+	}
+	return h*60 + m, nil
+}
+
+// maxIntervalWindowSkips limita as voltas do Next atrás de um disparo dentro
+// da janela ativa (cada volta avança pelo menos um dia). Uma grade que nunca
+// cai na janela (a cada 24h às 03:00 com janela 08:00–18:00) devolve zero —
+// o cadastro rejeita esse caso.
+const maxIntervalWindowSkips = 8*366 + 1
+
+// intervalSchedule dispara em anchor + k*every. window = janela ativa diária
+// [from, to) em minutos desde 00:00 no fuso loc. loc nil = usa o fuso do `t`
+// recebido no Next.
+type intervalSchedule struct {
+	anchor   time.Time
+	every    time.Duration
+	window   bool
+	from, to int
+	loc      *time.Location
+}
+
+func (s intervalSchedule) Next(t time.Time) time.Time {
+	loc := s.loc
+	if loc == nil {
+		loc = t.Location()
+	}
+	next := s.gridAfter(t).In(loc)
+	if !s.window {
+		return next
+	}
+	for i := 0; i < maxIntervalWindowSkips; i++ {
+		if s.active(next) {
+			return next
+		}
+		next = s.gridAfter(s.windowStart(next).Add(-time.Nanosecond)).In(loc)
+	}
+	return time.Time{}
+}
+
+// gridAfter devolve o primeiro ponto da grade estritamente depois de t.
+func (s intervalSchedule) gridAfter(t time.Time) time.Time {
+	if t.Before(s.anchor) {
+		return s.anchor
+	}
+	k := t.Sub(s.anchor)/s.every + 1
+	return s.anchor.Add(k * s.every)
+}
+
+func (s intervalSchedule) active(local time.Time) bool {
+	m := local.Hour()*60 + local.Minute()
+	if s.from < s.to {
+		return m >= s.from && m < s.to
+	}
+	return m >= s.from || m < s.to
+}
+
+// windowStart devolve o próximo início de janela depois de local (que está
+// fora dela): hoje às `from` ou, se já passou, amanhã.
+func (s intervalSchedule) windowStart(local time.Time) time.Time {
+	y, mo, d := local.Date()
+	start := time.Date(y, mo, d, s.from/60, s.from%60, 0, 0, local.Location())
+	if !start.After(local) {
+		start = time.Date(y, mo, d+1, s.from/60, s.from%60, 0, 0, local.Location())
+	}
+	return start
+}
+
+// describeSchedule resume a regra de disparo pra logs: a expressão cron ou
+// "a cada N min desde <âncora>".
+func describeSchedule(sc *models.Schedule) string {
+	if sc.Kind != models.ScheduleKindInterval {
+		return strconv.Quote(sc.CronExpression)
+	}
+	if sc.IntervalAnchor == nil || sc.IntervalMinutes == nil {
+		return "intervalo incompleto"
+	}
+	return fmt.Sprintf("a cada %d min desde %s", *sc.IntervalMinutes, sc.IntervalAnchor.Format(time.RFC3339))
+}
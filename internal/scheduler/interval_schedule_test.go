@@ -0,0 +1,70 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+)
+
+func TestIntervalSchedule(t *testing.T) {
+	loc, _ := time.LoadLocation("America/Sao_Paulo")
+	anchor := time.Date(2026, 6, 1, 7, 15, 0, 0, loc)
+	from, to := "07:00", "12:00"
+	sc := &models.Schedule{
+		Kind:            models.ScheduleKindInterval,
+		IntervalAnchor:  &anchor,
+		IntervalMinutes: func(n int) *int { return &n }(90),
+		ActiveFrom:      &from,
+		ActiveTo:        &to,
+	}
+	sched, err := BuildSchedule(sc, "America/Sao_Paulo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 07:15, 08:45, 10:15, 11:45 — 13:15 … 05:45 ficam fora da janela; a grade
+	// não se desloca, então o dia seguinte começa em 07:15 + 16*90min = 07:15.
+	want := []string{
+		"2026-06-01 07:15", "2026-06-01 08:45", "2026-06-01 10:15", "2026-06-01 11:45",
+		"2026-06-02 07:15",
+	}
+	at := anchor.Add(-time.Hour)
+	for _, w := range want {
+		at = sched.Next(at)
+		if got := at.Format("2006-01-02 15:04"); got != w {
+			t.Fatalf("Next: esperava %s, veio %s", w, got)
+		}
+	}
+	if prev := PrevFire(sched, time.Date(2026, 6, 2, 6, 0, 0, 0, loc)); !prev.Equal(time.Date(2026, 6, 1, 11, 45, 0, 0, loc)) {
+		t.Errorf("PrevFire: esperava 11:45 do dia 1, veio %s", prev)
+	}
+
+	// Janela que atravessa a meia-noite.
+	from, to = "22:00", "02:00"
+	sched, err = BuildSchedule(sc, "America/Sao_Paulo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sched.Next(anchor); !got.Equal(time.Date(2026, 6, 1, 22, 15, 0, 0, loc)) {
+		t.Errorf("janela noturna: esperava 22:15, veio %s", got)
+	}
+
+	// Grade que nunca cai na janela.
+	never := func(n int) *int { return &n }(24 * 60)
+	from, to = "08:00", "12:00"
+	early := time.Date(2026, 6, 1, 3, 0, 0, 0, loc)
+	s2, err := NewIntervalSchedule(early, *never, &from, &to, "America/Sao_Paulo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s2.Next(early); !got.IsZero() {
+		t.Errorf("esperava zero, veio %s", got)
+	}
+
+	for _, bad := range [][2]string{{"7:00", "12:00"}, {"08:00", "24:00"}, {"08:00", "08:00"}} {
+		if _, err := NewIntervalSchedule(anchor, 90, &bad[0], &bad[1], ""); err == nil {
+			t.Errorf("janela %v deveria ser rejeitada", bad)
+		}
+	}
+}
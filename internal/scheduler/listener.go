@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// listenRetryDelay é a espera antes de tentar reabrir a escuta de mudanças
+// depois de uma falha.
+const listenRetryDelay = 5 * time.Second
+
+// runChangeListener aplica as mudanças de agendamento notificadas pelo
+// Postgres (ScheduleRepository.ListenChanges) — feitas por esta réplica, por
+// outra ou na mão com pg_notify — uma a uma via ReloadSchedule. Roda em
+// TODAS as réplicas, líder ou não: os standbys também mantêm os crons
+// registrados.
+//
+// Se a conexão de LISTEN cair, as notificações do intervalo se perdem; ao
+// reabrir faz um Reload completo pra convergir.
+func (s *Scheduler) runChangeListener(ctx context.Context) {
+	synced := true // o Start acabou de fazer o Reload
+	for {
+		changes, err := s.scheduleRepo.ListenChanges(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("[scheduler] erro ao escutar mudanças de agendamentos (nova tentativa em %s): %v", listenRetryDelay, err)
+			synced = false
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(listenRetryDelay):
+			}
+			continue
+		}
+
+		if !synced {
+			if err := s.Reload(ctx); err != nil {
+				log.Printf("[scheduler] erro ao ressincronizar agendamentos: %v", err)
+			}
+			synced = true
+		}
+
+		for id := range changes {
+			var err error
+			if id == 0 {
+				err = s.Reload(ctx)
+			} else {
+				err = s.ReloadSchedule(ctx, id)
+			}
+			if err != nil {
+				log.Printf("[scheduler] erro ao aplicar mudança do agendamento %d: %v", id, err)
+			}
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("[scheduler] escuta de mudanças de agendamentos caiu — reabrindo")
+		synced = false
+	}
+}
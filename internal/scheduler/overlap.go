@@ -0,0 +1,213 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+	"github.com/EnzzoHosaki/rps-maestro/internal/queue"
+	"github.com/google/uuid"
+)
+
+// heldReleaseInterval é a cadência com que os jobs retidos pela overlap_policy
+// são conferidos. Os jobs que motivam a política duram dezenas de minutos,
+// então alguns segundos de atraso na liberação não importam.
+const heldReleaseInterval = 15 * time.Second
+
+// overlapDecision é o que a overlap_policy decidiu pra um disparo.
+type overlapDecision struct {
+	skip   bool                  // não criar job
+	after  *uuid.UUID            // criar retido atrás deste job
+	event  *models.ScheduleEvent // registrar em schedule_events (job_id preenchido depois)
+	cancel []models.Job          // cancelar depois que o job novo existir (cancel_previous)
+}
+
+// checkOverlap aplica a overlap_policy do agendamento contra os jobs dele que
+// ainda estão em aberto (pending, running ou scheduled aguardando retry).
+//
+//   - allow: cria o job normalmente.
+//   - skip: não cria; registra o tick pulado com o motivo.
+//   - queue_after: cria retido atrás do job ativo mais recente. Se já houver
+//     um disparo retido, pula — no máximo um disparo aguardando por vez.
+//   - cancel_previous: cancela os ativos, mas só em finishOverlap, depois
+//     que o job novo foi criado — se a criação falhar, a execução anterior
+//     segue. Pending cancela na hora; se algum estava running, o novo fica
+//     retido até ele parar de fato (o cancelamento é cooperativo), pra não
+//     sobrepor no portal.
+func (s *Scheduler) checkOverlap(ctx context.Context, sc *models.Schedule, fireTime time.Time) (overlapDecision, error) {
+	if sc.OverlapPolicy == "" || sc.OverlapPolicy == models.OverlapAllow {
+		return overlapDecision{}, nil
+	}
+
+	active, err := s.jobRepo.GetActiveBySchedule(ctx, sc.ID)
+	if err != nil {
+		return overlapDecision{}, fmt.Errorf("erro ao verificar jobs anteriores: %w", err)
+	}
+	if len(active) == 0 {
+		return overlapDecision{}, nil
+	}
+	last := active[len(active)-1]
+
+	event := &models.ScheduleEvent{ScheduleID: sc.ID, ScheduledFor: fireTime}
+
+	switch sc.OverlapPolicy {
+	case models.OverlapSkip:
+		event.Kind = models.ScheduleEventSkipped
+		event.Reason = fmt.Sprintf("job anterior %s ainda %s (overlap_policy=skip)", last.ID, last.Status)
+		s.recordEvent(ctx, event)
+		return overlapDecision{skip: true}, nil
+
+	case models.OverlapQueueAfter:
+		for _, j := range active {
+			if j.AfterJobID != nil {
+				event.Kind = models.ScheduleEventSkipped
+				event.Reason = fmt.Sprintf("já existe um disparo aguardando (job %s) — overlap_policy=queue_after", j.ID)
+				s.recordEvent(ctx, event)
+				return overlapDecision{skip: true}, nil
+			}
+		}
+		event.Kind = models.ScheduleEventQueuedAfter
+		event.Reason = fmt.Sprintf("aguardando o job anterior %s (%s) terminar", last.ID, last.Status)
+		return overlapDecision{after: &last.ID, event: event}, nil
+
+	case models.OverlapCancelPrevious:
+		var running *uuid.UUID
+		for i := range active {
+			if active[i].Status == "running" {
+				running = &active[i].ID
+			}
+		}
+		event.Kind = models.ScheduleEventCanceledPrevious
+		return overlapDecision{after: running, event: event, cancel: active}, nil
+	}
+
+	return overlapDecision{}, nil
+}
+
+// finishOverlap roda, com o job novo já criado, o que a decisão deixou pra
+// depois: pede o cancelamento dos anteriores (cancel_previous) e grava o
+// evento apontando pro job novo. Se o anterior running terminar antes do
+// cancelamento, o job novo fica retido atrás de um job encerrado e o
+// runHeldReleaser o libera no próximo ciclo.
+func (s *Scheduler) finishOverlap(ctx context.Context, sc *models.Schedule, d overlapDecision, jobID uuid.UUID) {
+	if d.event == nil {
+		return
+	}
+	if d.event.Kind == models.ScheduleEventCanceledPrevious {
+		var canceled []string
+		for _, j := range d.cancel {
+			if err := s.jobRepo.RequestCancellation(ctx, j.ID); err != nil {
+				// Corrida normal: o job terminou entre a consulta e o cancelamento.
+				log.Printf("[scheduler] agendamento %d: não foi possível cancelar o job %s: %v", sc.ID, j.ID, err)
+				continue
+			}
+			canceled = append(canceled, j.ID.String())
+		}
+		if len(canceled) == 0 {
+			return
+		}
+		d.event.Reason = "cancelamento solicitado para: " + strings.Join(canceled, ", ")
+		if d.after != nil {
+			d.event.Reason += fmt.Sprintf(" — novo job aguarda o job %s parar", *d.after)
+		}
+	}
+	d.event.JobID = &jobID
+	s.recordEvent(ctx, d.event)
+}
+
+// recordEvent grava o evento sem interromper o disparo em caso de erro — o
+// histórico é informativo.
+func (s *Scheduler) recordEvent(ctx context.Context, event *models.ScheduleEvent) {
+	if err := s.eventRepo.Create(ctx, event); err != nil {
+		log.Printf("[scheduler] erro ao registrar evento %s do agendamento %d: %v", event.Kind, event.ScheduleID, err)
+		return
+	}
+	log.Printf("[scheduler] agendamento %d: %s — %s", event.ScheduleID, event.Kind, event.Reason)
+}
+
+// runHeldReleaser publica, na réplica líder, os jobs retidos cujo job anterior
+// já terminou. Bloqueante até o ctx ser cancelado — chamado em goroutine pelo
+// Start.
+func (s *Scheduler) runHeldReleaser(ctx context.Context) {
+	ticker := time.NewTicker(heldReleaseInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if s.isLeader() {
+				s.releaseHeld(ctx)
+			}
+		}
+	}
+}
+
+func (s *Scheduler) releaseHeld(ctx context.Context) {
+	jobs, err := s.jobRepo.ReleaseHeldJobs(ctx)
+	if err != nil {
+		log.Printf("[scheduler] %v", err)
+		return
+	}
+
+	for i := range jobs {
+		job := &jobs[i]
+		if job.Held || job.IsFanOutParent() {
+			// Segue retido, agora só pelo limite de concorrência — ou é o pai
+			// de um fan-out, que não vai pra fila (os filhos vêm junto).
+			continue
+		}
+		automation, err := s.automationRepo.GetByID(ctx, job.AutomationID)
+		if err != nil {
+			log.Printf("[scheduler] job retido %s: automação %d não encontrada: %v", job.ID, job.AutomationID, err)
+			continue
+		}
+		params, err := parseParams(job.Parameters)
+		if err != nil {
+			log.Printf("[scheduler] job retido %s: parâmetros inválidos: %v", job.ID, err)
+			continue
+		}
+		if err := s.publish(ctx, automation, job, params); err != nil {
+			log.Printf("[scheduler] %v", err)
+			continue
+		}
+		log.Printf("[scheduler] job retido %s liberado — automação %q", job.ID, automation.Name)
+	}
+}
+
+// publish enfileira o job. Se o broker recusar, marca o job failed (mesma
+// compensação do ExecuteAutomation): um pending sem mensagem na fila ficaria
+// órfão pra sempre.
+func (s *Scheduler) publish(ctx context.Context, automation *models.Automation, job *models.Job, params map[string]interface{}) error {
+	queueName := automation.QueueName
+	if queueName == "" {
+		queueName = "automation_jobs"
+	}
+
+	msg := queue.JobMessage{
+		JobID:        job.ID.String(),
+		AutomationID: automation.ID,
+		ScriptPath:   automation.ScriptPath,
+		Parameters:   params,
+		Priority:     job.Priority,
+	}
+
+	if err := s.queueClient.PublishJob(ctx, queueName, msg); err != nil {
+		s.failUnpublished(ctx, job.ID, "Falha ao enfileirar o job no broker: "+err.Error())
+		return fmt.Errorf("erro ao enfileirar job %s: %w", job.ID, err)
+	}
+	return nil
+}
+
+// failUnpublished marca failed, com o motivo no result, um job pending que não
+// chegou à fila.
+func (s *Scheduler) failUnpublished(ctx context.Context, jobID uuid.UUID, reason string) {
+	failResult, _ := json.Marshal(map[string]string{"error": reason})
+	_ = s.jobRepo.SetResult(ctx, jobID, failResult)
+	_ = s.jobRepo.UpdateStatus(ctx, jobID, "failed")
+}
@@ -0,0 +1,84 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+)
+
+// ActivePause devolve a pausa que cobre o instante t no agendamento — a pausa
+// pontual (pausedUntil) ou uma janela de bloqueio em curso — ou nil se um
+// disparo em t sairia normalmente.
+func (s *Scheduler) ActivePause(ctx context.Context, sc *models.Schedule, t time.Time) (*models.SchedulePause, error) {
+	windows, err := s.blackoutRepo.ListEffective(ctx, sc.ID, sc.AutomationID)
+	if err != nil {
+		return nil, err
+	}
+	return s.activePause(sc, windows, t), nil
+}
+
+// activePause é o ActivePause com as janelas já carregadas (o preview carrega
+// uma vez pra todos os disparos). A pausa pontual tem precedência; entre
+// janelas sobrepostas vale a que termina por último.
+func (s *Scheduler) activePause(sc *models.Schedule, windows []models.BlackoutWindow, t time.Time) *models.SchedulePause {
+	if sc.PausedUntil != nil && t.Before(*sc.PausedUntil) {
+		p := &models.SchedulePause{Kind: models.PauseKindPaused, Until: *sc.PausedUntil}
+		if sc.PauseReason != nil {
+			p.Reason = *sc.PauseReason
+		}
+		return p
+	}
+
+	var best *models.SchedulePause
+	for i := range windows {
+		w := &windows[i]
+		end, ok := s.windowEnd(w, t)
+		if !ok || (best != nil && !end.After(best.Until)) {
+			continue
+		}
+		id := w.ID
+		best = &models.SchedulePause{Kind: models.PauseKindBlackout, Until: end, Reason: w.Name, WindowID: &id}
+	}
+	return best
+}
+
+// windowEnd diz se a janela está em curso no instante t e, se estiver, quando
+// termina: a última abertura (disparo do cron da janela) até t, mais a duração.
+func (s *Scheduler) windowEnd(w *models.BlackoutWindow, t time.Time) (time.Time, bool) {
+	loc, locName := s.Location(w.Timezone)
+	sched, err := ParseScheduleTZ(w.CronExpression, locName)
+	if err != nil {
+		// Só acontece editando o banco na mão — a API valida a expressão.
+		log.Printf("[scheduler] janela de bloqueio %d: expressão inválida %q: %v", w.ID, w.CronExpression, err)
+		return time.Time{}, false
+	}
+	// PrevFire devolve o último disparo ANTES do instante dado; +1ns inclui
+	// uma janela que abre exatamente em t.
+	start := PrevFire(sched, t.In(loc).Add(time.Nanosecond))
+	if start.IsZero() {
+		return time.Time{}, false
+	}
+	end := start.Add(time.Duration(w.DurationMinutes) * time.Minute)
+	return end, t.Before(end)
+}
+
+// pauseEvent monta o registro em schedule_events de um disparo suprimido.
+func pauseEvent(sc *models.Schedule, fireTime time.Time, p *models.SchedulePause) *models.ScheduleEvent {
+	until := p.Until.In(fireTime.Location()).Format("2006-01-02 15:04 MST")
+	event := &models.ScheduleEvent{ScheduleID: sc.ID, ScheduledFor: fireTime}
+	switch p.Kind {
+	case models.PauseKindBlackout:
+		event.Kind = models.ScheduleEventBlackout
+		event.Reason = fmt.Sprintf("janela de bloqueio %q até %s", p.Reason, until)
+	default:
+		event.Kind = models.ScheduleEventPaused
+		event.Reason = "agendamento pausado até " + until
+		if p.Reason != "" {
+			event.Reason += " (" + p.Reason + ")"
+		}
+	}
+	return event
+}
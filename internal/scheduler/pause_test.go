@@ -0,0 +1,49 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+)
+
+func TestActivePause(t *testing.T) {
+	loc, _ := time.LoadLocation("America/Sao_Paulo")
+	s := &Scheduler{loc: loc, locName: "America/Sao_Paulo"}
+
+	// Sábado das 22:00 às 02:00 (manutenção SEFAZ).
+	windows := []models.BlackoutWindow{{ID: 7, Name: "SEFAZ", CronExpression: "0 22 * * 6", DurationMinutes: 240}}
+	sc := &models.Schedule{}
+
+	sat := time.Date(2026, 5, 16, 0, 0, 0, 0, loc) // sábado
+	cases := []struct {
+		at   time.Time
+		want *time.Time
+	}{
+		{sat.Add(21*time.Hour + 59*time.Minute), nil},
+		{sat.Add(22 * time.Hour), ptr(sat.Add(26 * time.Hour))},
+		{sat.Add(25*time.Hour + 59*time.Minute), ptr(sat.Add(26 * time.Hour))},
+		{sat.Add(26 * time.Hour), nil},
+	}
+	for _, c := range cases {
+		p := s.activePause(sc, windows, c.at)
+		switch {
+		case c.want == nil && p != nil:
+			t.Errorf("%s: esperava sem pausa, veio %+v", c.at, p)
+		case c.want != nil && (p == nil || !p.Until.Equal(*c.want) || p.Kind != models.PauseKindBlackout || *p.WindowID != 7):
+			t.Errorf("%s: esperava janela 7 até %s, veio %+v", c.at, c.want, p)
+		}
+	}
+
+	// pausedUntil tem precedência sobre a janela e expira sozinho.
+	until := sat.Add(23 * time.Hour)
+	sc.PausedUntil = &until
+	if p := s.activePause(sc, windows, sat.Add(22*time.Hour+30*time.Minute)); p == nil || p.Kind != models.PauseKindPaused {
+		t.Errorf("esperava pausa pontual, veio %+v", p)
+	}
+	if p := s.activePause(sc, nil, until); p != nil {
+		t.Errorf("pausa deveria ter expirado em %s, veio %+v", until, p)
+	}
+}
+
+func ptr(t time.Time) *time.Time { return &t }
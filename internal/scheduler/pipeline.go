@@ -0,0 +1,230 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+	"github.com/EnzzoHosaki/rps-maestro/internal/pipeline"
+)
+
+// pipelineAdvanceInterval é a cadência com que a réplica líder avança as
+// execuções de pipeline em aberto: cria os jobs dos passos liberados e fecha
+// as execuções sem nada pendente.
+const pipelineAdvanceInterval = 5 * time.Second
+
+// StartPipelineRun cria a execução com os jobs dos passos iniciais (sem aresta
+// de entrada) e publica os que não ficaram retidos. overrides sobrescreve,
+// por chave de passo, os parâmetros da definição. Roda em qualquer réplica;
+// os passos seguintes saem pelo runPipelineAdvancer da líder.
+func (s *Scheduler) StartPipelineRun(ctx context.Context, p *models.Pipeline, userID *int, overrides map[string]map[string]interface{}) (*models.PipelineRun, error) {
+	plan := pipeline.Evaluate(p.Steps, p.Edges, nil)
+
+	automations := make(map[int]*models.Automation)
+	jobs := make([]models.Job, 0, len(plan.Ready))
+	params := make([]map[string]interface{}, 0, len(plan.Ready))
+	for _, key := range plan.Ready {
+		step := findStep(p, key)
+		automation, err := s.stepAutomation(ctx, automations, step)
+		if err != nil {
+			return nil, err
+		}
+		stepParams := mergeStepParams(step, overrides[key], nil)
+		job, err := stepJob(automation, step, userID, stepParams)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+		params = append(params, stepParams)
+	}
+
+	run := &models.PipelineRun{PipelineID: p.ID, UserID: userID, Parameters: overrides}
+	if err := s.pipelineRepo.CreateRun(ctx, run, jobs); err != nil {
+		return nil, err
+	}
+
+	for i := range jobs {
+		if jobs[i].Held {
+			continue
+		}
+		if err := s.publish(ctx, automations[jobs[i].AutomationID], &jobs[i], params[i]); err != nil {
+			log.Printf("[scheduler] pipeline %q: %v", p.Name, err)
+		}
+	}
+	log.Printf("[scheduler] execução %s do pipeline %q iniciada com %d passo(s)", run.ID, p.Name, len(jobs))
+	return run, nil
+}
+
+// runPipelineAdvancer avança, na réplica líder, as execuções de pipeline em
+// aberto. Bloqueante até o ctx ser cancelado — chamado em goroutine pelo
+// Start.
+func (s *Scheduler) runPipelineAdvancer(ctx context.Context) {
+	ticker := time.NewTicker(pipelineAdvanceInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.isLeader() {
+				continue
+			}
+			runs, err := s.pipelineRepo.GetOpenRuns(ctx)
+			if err != nil {
+				log.Printf("[scheduler] %v", err)
+				continue
+			}
+			for i := range runs {
+				if err := s.advanceRun(ctx, &runs[i]); err != nil {
+					log.Printf("[scheduler] execução %s do pipeline: %v", runs[i].ID, err)
+				}
+			}
+		}
+	}
+}
+
+// advanceRun cria e publica os jobs dos passos liberados e grava o status da
+// execução. O passo cujo input não resolve nasce failed com error_class
+// INVALID_PARAMETERS — as arestas on_failure dele valem normalmente.
+func (s *Scheduler) advanceRun(ctx context.Context, run *models.PipelineRun) error {
+	p, err := s.pipelineRepo.GetByID(ctx, run.PipelineID)
+	if err != nil {
+		return err
+	}
+	jobs, err := s.pipelineRepo.ListRunJobs(ctx, run.ID)
+	if err != nil {
+		return err
+	}
+	latest, results := LatestStepJobs(jobs)
+	plan := pipeline.Evaluate(p.Steps, p.Edges, latest)
+
+	automations := make(map[int]*models.Automation)
+	for _, key := range plan.Ready {
+		step := findStep(p, key)
+		automation, err := s.stepAutomation(ctx, automations, step)
+		if err != nil {
+			return err
+		}
+
+		inputs, inputErr := pipeline.ResolveInputs(*step, results)
+		stepParams := mergeStepParams(step, run.Parameters[key], inputs)
+		job, err := stepJob(automation, step, run.UserID, stepParams)
+		if err != nil {
+			return err
+		}
+		job.PipelineRunID = &run.ID
+		if inputErr != nil {
+			job.Status = "failed"
+		}
+		if err := s.jobRepo.Create(ctx, job); err != nil {
+			return fmt.Errorf("erro ao criar job do passo %q: %w", key, err)
+		}
+
+		switch {
+		case inputErr != nil:
+			// Fecha como o finish do worker: sem completed_at o job ficaria
+			// fora da saúde da automação, das séries e das últimas execuções.
+			failResult, _ := json.Marshal(map[string]string{"error": inputErr.Error(), "error_class": "INVALID_PARAMETERS"})
+			if err := s.jobRepo.SetCompleted(ctx, job.ID); err != nil {
+				log.Printf("[scheduler] %v", err)
+			}
+			if err := s.jobRepo.SetResult(ctx, job.ID, failResult); err != nil {
+				log.Printf("[scheduler] %v", err)
+			}
+			log.Printf("[scheduler] passo %q da execução %s falhou sem sair: %v", key, run.ID, inputErr)
+		case job.Held:
+		default:
+			if err := s.publish(ctx, automation, job, stepParams); err != nil {
+				log.Printf("[scheduler] %v", err)
+			}
+		}
+	}
+
+	// Passo criado agora reabre a avaliação no próximo tick; até lá a
+	// execução segue em running.
+	status := plan.Status
+	if len(plan.Ready) > 0 {
+		status = "running"
+	}
+	if status != run.Status {
+		if err := s.pipelineRepo.UpdateRunStatus(ctx, run.ID, status); err != nil {
+			return err
+		}
+		if status != "running" {
+			log.Printf("[scheduler] execução %s do pipeline %q terminou: %s", run.ID, p.Name, status)
+		}
+	}
+	return nil
+}
+
+// LatestStepJobs separa, dos jobs de uma execução (em ordem de criação), o
+// mais recente de cada passo e o result dele.
+func LatestStepJobs(jobs []models.Job) (map[string]pipeline.StepJob, map[string]json.RawMessage) {
+	latest := make(map[string]pipeline.StepJob)
+	results := make(map[string]json.RawMessage)
+	for _, j := range jobs {
+		if j.PipelineStep == nil {
+			continue
+		}
+		latest[*j.PipelineStep] = pipeline.StepJob{Status: j.Status, Result: j.Result}
+		results[*j.PipelineStep] = j.Result
+	}
+	return latest, results
+}
+
+func findStep(p *models.Pipeline, key string) *models.PipelineStep {
+	for i := range p.Steps {
+		if p.Steps[i].Key == key {
+			return &p.Steps[i]
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) stepAutomation(ctx context.Context, cache map[int]*models.Automation, step *models.PipelineStep) (*models.Automation, error) {
+	if a, ok := cache[step.AutomationID]; ok {
+		return a, nil
+	}
+	a, err := s.automationRepo.GetByID(ctx, step.AutomationID)
+	if err != nil {
+		return nil, fmt.Errorf("automação %d do passo %q não encontrada: %w", step.AutomationID, step.Key, err)
+	}
+	cache[a.ID] = a
+	return a, nil
+}
+
+// mergeStepParams monta os parâmetros do passo: os da definição, depois os
+// da execução e por fim os inputs resolvidos.
+func mergeStepParams(step *models.PipelineStep, overrides, inputs map[string]interface{}) map[string]interface{} {
+	params := make(map[string]interface{}, len(step.Parameters)+len(overrides)+len(inputs))
+	for _, layer := range []map[string]interface{}{step.Parameters, overrides, inputs} {
+		for k, v := range layer {
+			params[k] = v
+		}
+	}
+	return params
+}
+
+func stepJob(automation *models.Automation, step *models.PipelineStep, userID *int, params map[string]interface{}) (*models.Job, error) {
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao serializar parâmetros do passo %q: %w", step.Key, err)
+	}
+	trigger := models.TriggerManual
+	if userID == nil {
+		trigger = models.TriggerAPI
+	}
+	key := step.Key
+	return &models.Job{
+		AutomationID: automation.ID,
+		UserID:       userID,
+		Status:       "pending",
+		Parameters:   paramsJSON,
+		Trigger:      trigger,
+		PipelineStep: &key,
+	}, nil
+}
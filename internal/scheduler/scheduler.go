@@ -3,13 +3,17 @@ package scheduler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/EnzzoHosaki/rps-maestro/internal/calendar"
 	"github.com/EnzzoHosaki/rps-maestro/internal/models"
+	"github.com/EnzzoHosaki/rps-maestro/internal/paramschema"
 	"github.com/EnzzoHosaki/rps-maestro/internal/queue"
 	"github.com/EnzzoHosaki/rps-maestro/internal/repository"
 	"github.com/robfig/cron/v3"
@@ -22,20 +26,32 @@ import (
 // adiantado).
 const defaultSchedulerTZ = "America/Sao_Paulo"
 
+// LeaderChecker diz se esta réplica é a líder. Com várias réplicas do backend,
+// todas registram os crons (pra manter next_run_at e o Reload funcionando em
+// qualquer uma), mas só a líder cria jobs — senão cada disparo sairia em dobro.
+type LeaderChecker interface {
+	IsLeader() bool
+}
+
 type Scheduler struct {
 	cron           *cron.Cron
 	scheduleRepo   repository.ScheduleRepository
 	automationRepo repository.AutomationRepository
 	jobRepo        repository.JobRepository
+	eventRepo      repository.ScheduleEventRepository
+	calendarRepo   repository.CalendarRepository
+	blackoutRepo   repository.BlackoutWindowRepository
+	pipelineRepo   repository.PipelineRepository
 	queueClient    *queue.RabbitMQClient
+	leader         LeaderChecker
 	entries        map[int]cron.EntryID
 	mu             sync.Mutex
-	loc            *time.Location
-	locName        string // nome IANA pra injetar via CRON_TZ; "" se caiu no fallback
+	loc            *time.Location // fuso padrão — agendamentos sem timezone próprio
+	locName        string         // nome IANA pra injetar via CRON_TZ; "" se caiu no fallback
 }
 
-// resolveLocation escolhe o fuso do scheduler: SCHEDULER_TZ > TZ > default
-// (America/Sao_Paulo). Cai em time.Local com aviso se o nome não carregar (o
+// resolveLocation escolhe o fuso padrão do scheduler: SCHEDULER_TZ > TZ > default
+// (America/Sao_Paulo). Vale pros agendamentos sem timezone próprio. Cai em time.Local com aviso se o nome não carregar (o
 // binário embute time/tzdata, então em condições normais sempre carrega).
 func resolveLocation() (*time.Location, string) {
 	name := os.Getenv("SCHEDULER_TZ")
@@ -58,7 +74,12 @@ func New(
 	scheduleRepo repository.ScheduleRepository,
 	automationRepo repository.AutomationRepository,
 	jobRepo repository.JobRepository,
+	eventRepo repository.ScheduleEventRepository,
+	calendarRepo repository.CalendarRepository,
+	blackoutRepo repository.BlackoutWindowRepository,
+	pipelineRepo repository.PipelineRepository,
 	queueClient *queue.RabbitMQClient,
+	leader LeaderChecker,
 ) *Scheduler {
 	loc, locName := resolveLocation()
 	return &Scheduler{
@@ -66,19 +87,85 @@ func New(
 		scheduleRepo:   scheduleRepo,
 		automationRepo: automationRepo,
 		jobRepo:        jobRepo,
+		eventRepo:      eventRepo,
+		calendarRepo:   calendarRepo,
+		blackoutRepo:   blackoutRepo,
+		pipelineRepo:   pipelineRepo,
 		queueClient:    queueClient,
+		leader:         leader,
 		entries:        make(map[int]cron.EntryID),
 		loc:            loc,
 		locName:        locName,
 	}
 }
 
+// Location devolve o fuso efetivo de um agendamento: o timezone dele ou, se
+// vazio, o padrão do processo. O nome volta "" quando o padrão caiu no
+// fallback time.Local (ver resolveLocation). Um timezone que não carrega (só
+// possível editando o banco na mão — a API valida) cai no padrão com aviso.
+func (s *Scheduler) Location(timezone *string) (*time.Location, string) {
+	if timezone == nil || *timezone == "" {
+		return s.loc, s.locName
+	}
+	loc, err := time.LoadLocation(*timezone)
+	if err != nil {
+		log.Printf("[scheduler] timezone %q inválida (%v) — usando o fuso padrão", *timezone, err)
+		return s.loc, s.locName
+	}
+	return loc, *timezone
+}
+
+// scheduleFor monta a cron.Schedule de um agendamento — expressão cron ou
+// intervalo, fuso e calendário/holiday_policy — e devolve também o fuso
+// efetivo. cals é um cache opcional de calendários já carregados (o Reload
+// carrega cada um uma vez só); nil = busca no banco.
+func (s *Scheduler) scheduleFor(ctx context.Context, sc *models.Schedule, cals map[int]*calendar.Calendar) (cron.Schedule, *time.Location, error) {
+	loc, locName := s.Location(sc.Timezone)
+
+	cal, err := s.calendarFor(ctx, sc, cals)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sched, err := BuildSchedule(sc, locName, cal)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sched, loc, nil
+}
+
+// calendarFor carrega o calendário do agendamento (nil se não tiver), usando
+// e preenchendo o cache cals quando não-nil.
+func (s *Scheduler) calendarFor(ctx context.Context, sc *models.Schedule, cals map[int]*calendar.Calendar) (*calendar.Calendar, error) {
+	if sc.CalendarID == nil {
+		return nil, nil
+	}
+	if cal := cals[*sc.CalendarID]; cal != nil {
+		return cal, nil
+	}
+	c, err := s.calendarRepo.GetByID(ctx, *sc.CalendarID)
+	if err != nil {
+		return nil, fmt.Errorf("calendário %d: %w", *sc.CalendarID, err)
+	}
+	cal := calendar.New(*c, c.Holidays)
+	if cals != nil {
+		cals[*sc.CalendarID] = cal
+	}
+	return cal, nil
+}
+
 // Start carrega os agendamentos do banco e inicia o cron runner.
 func (s *Scheduler) Start(ctx context.Context) {
 	if err := s.Reload(ctx); err != nil {
 		log.Printf("[scheduler] erro ao carregar agendamentos iniciais: %v", err)
 	}
 	s.cron.Start()
+	go s.runHeldReleaser(ctx)
+	go s.runDelayedDispatcher(ctx)
+	go s.runConcurrencyDispatcher(ctx)
+	go s.runFanOutReconciler(ctx)
+	go s.runPipelineAdvancer(ctx)
+	go s.runChangeListener(ctx)
 	log.Printf("[scheduler] iniciado com %d agendamento(s) ativo(s)", len(s.entries))
 }
 
@@ -87,54 +174,186 @@ func (s *Scheduler) Stop() {
 	s.cron.Stop()
 }
 
-// Reload sincroniza os agendamentos do banco com o cron runner.
-// Deve ser chamado após criar, atualizar ou deletar um agendamento via API.
+// misfireGrace é a folga antes de um next_run_at no passado ser tratado como
+// disparo perdido. Cobre a corrida normal entre o tick do cron e o
+// UpdateNextRun do runSchedule (milissegundos) com boa margem.
+const misfireGrace = time.Minute
+
+// maxMisfireRuns limita quantos disparos perdidos um agendamento em run_all
+// enfileira numa recuperação.
+const maxMisfireRuns = 50
+
+// misfire é um agendamento com disparos perdidos a recuperar depois do Reload.
+type misfire struct {
+	schedule models.Schedule
+	fires    []time.Time
+}
+
+// Reload sincroniza TODOS os agendamentos do banco com o cron runner. É
+// chamado no Start, quando esta réplica assume a liderança, quando um
+// calendário muda e quando a escuta de mudanças reconecta — mudanças num
+// agendamento só passam por ReloadSchedule.
+//
+// Antes de recalcular o next_run_at, compara o valor salvo com agora: se ficou
+// mais de misfireGrace no passado, o backend perdeu disparos (deploy, queda,
+// líder morto). Na réplica líder esses disparos são recuperados conforme a
+// misfire_policy do agendamento; num standby o next_run_at antigo é preservado
+// pra que o futuro líder ainda consiga recuperá-los.
 func (s *Scheduler) Reload(ctx context.Context) error {
+	// A leitura fica DENTRO do lock: dois Reloads concorrentes (ex.: o do Start
+	// e o do OnElected) não podem ler o mesmo next_run_at antigo, senão os dois
+	// recuperariam os mesmos disparos perdidos.
+	s.mu.Lock()
 	schedules, err := s.scheduleRepo.GetAllEnabled(ctx)
 	if err != nil {
+		s.mu.Unlock()
 		return fmt.Errorf("erro ao buscar agendamentos: %w", err)
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	leader := s.isLeader()
+	var misfires []misfire
+	cals := make(map[int]*calendar.Calendar)
 
 	// Remove TODAS as entradas registradas e re-registra do zero. Isso garante
-	// que edições na expressão cron de um agendamento existente passem a valer
-	// imediatamente (sem reiniciar o backend) e que o next_run_at seja sempre
-	// recalculado — incluindo agendamentos desabilitados/deletados, que
-	// simplesmente não voltam a ser registrados por não estarem em GetAllEnabled.
-	for id, entryID := range s.entries {
-		s.cron.Remove(entryID)
-		delete(s.entries, id)
+	// que o next_run_at seja sempre recalculado — incluindo agendamentos
+	// desabilitados/deletados, que simplesmente não voltam a ser registrados
+	// por não estarem em GetAllEnabled.
+	for id := range s.entries {
+		s.unregister(id)
 	}
 
 	// Registra todos os agendamentos habilitados
-	for _, sc := range schedules {
-		scheduleID := sc.ID
-		// ParseScheduleTZ (não AddFunc/ParseStandard) pra (a) aceitar o `L`
-		// (último dia do mês) via Schedule customizada e (b) fixar o fuso na
-		// expressão, senão o disparo herda o TZ do container (UTC).
-		sched, err := ParseScheduleTZ(sc.CronExpression, s.locName)
-		if err != nil {
-			log.Printf("[scheduler] expressão cron inválida no agendamento %d (%q): %v", sc.ID, sc.CronExpression, err)
-			continue
+	for i := range schedules {
+		if m := s.register(ctx, &schedules[i], cals, leader); m != nil {
+			misfires = append(misfires, *m)
 		}
-		entryID := s.cron.Schedule(sched, cron.FuncJob(func() {
-			s.runSchedule(scheduleID)
-		}))
-		s.entries[sc.ID] = entryID
+	}
 
-		next := s.cron.Entry(entryID).Next
-		if err := s.scheduleRepo.UpdateNextRun(ctx, sc.ID, &next); err != nil {
-			log.Printf("[scheduler] erro ao atualizar next_run_at do agendamento %d: %v", sc.ID, err)
+	s.mu.Unlock()
+
+	// Fora do lock: recuperar cria jobs e publica na fila, e runSchedule
+	// também precisa do mu.
+	for _, m := range misfires {
+		s.recoverMisfire(ctx, m)
+	}
+
+	return nil
+}
+
+// ReloadSchedule sincroniza UM agendamento com o cron runner: (re)registra se
+// está habilitado, remove se foi desabilitado ou deletado. É o caminho das
+// mudanças feitas via API e das notificadas por outras réplicas (ver
+// runChangeListener); os demais agendamentos não são tocados. Disparos
+// perdidos seguem a misfire_policy como no Reload.
+func (s *Scheduler) ReloadSchedule(ctx context.Context, id int) error {
+	s.mu.Lock()
+	sc, err := s.scheduleRepo.GetByID(ctx, id)
+	if err != nil && !errors.Is(err, repository.ErrScheduleNotFound) {
+		s.mu.Unlock()
+		return fmt.Errorf("erro ao buscar agendamento %d: %w", id, err)
+	}
+
+	_, registered := s.entries[id]
+	s.unregister(id)
+	if err != nil || !sc.IsEnabled {
+		s.mu.Unlock()
+		if registered {
+			log.Printf("[scheduler] agendamento %d removido", id)
 		}
-		log.Printf("[scheduler] agendamento %d registrado (próxima execução: %s)", sc.ID, next.Format("2006-01-02 15:04:05"))
+		return nil
 	}
+	m := s.register(ctx, sc, nil, s.isLeader())
+	s.mu.Unlock()
 
+	if m != nil {
+		s.recoverMisfire(ctx, *m)
+	}
 	return nil
 }
 
+// register registra um agendamento habilitado no cron runner e grava o
+// next_run_at. Devolve os disparos perdidos a recuperar — nil se não há ou se
+// esta réplica não é a líder (aí o next_run_at antigo fica pro futuro líder).
+// Chamar com s.mu travado.
+func (s *Scheduler) register(ctx context.Context, sc *models.Schedule, cals map[int]*calendar.Calendar, leader bool) *misfire {
+	scheduleID := sc.ID
+	// scheduleFor (não AddFunc/ParseStandard) pra (a) aceitar `L`, dias úteis
+	// e intervalos via Schedules customizadas, (b) fixar o fuso do agendamento
+	// na expressão, senão o disparo herda o fuso do runner, e (c) aplicar o
+	// calendário de feriados.
+	sched, loc, err := s.scheduleFor(ctx, sc, cals)
+	if err != nil {
+		log.Printf("[scheduler] agendamento %d inválido (%s): %v", sc.ID, describeSchedule(sc), err)
+		return nil
+	}
+	entryID := s.cron.Schedule(sched, cron.FuncJob(func() {
+		s.runSchedule(scheduleID)
+	}))
+	s.entries[sc.ID] = entryID
+
+	var m *misfire
+	now := time.Now().In(loc)
+	if sc.NextRunAt != nil && now.Sub(*sc.NextRunAt) > misfireGrace {
+		if !leader {
+			log.Printf("[scheduler] agendamento %d com disparo perdido em %s — preservado para a réplica líder", sc.ID, sc.NextRunAt.Format("2006-01-02 15:04:05"))
+			return nil
+		}
+		lookback := time.Duration(sc.MisfireMaxLookbackMinutes) * time.Minute
+		if fires := MissedFires(sched, sc.NextRunAt.In(loc), now, lookback, maxMisfireRuns); len(fires) > 0 {
+			m = &misfire{schedule: *sc, fires: fires}
+		}
+	}
+
+	next := s.cron.Entry(entryID).Next
+	if err := s.scheduleRepo.UpdateNextRun(ctx, sc.ID, &next); err != nil {
+		log.Printf("[scheduler] erro ao atualizar next_run_at do agendamento %d: %v", sc.ID, err)
+	}
+	log.Printf("[scheduler] agendamento %d registrado (próxima execução: %s)", sc.ID, next.In(loc).Format("2006-01-02 15:04:05 MST"))
+	return m
+}
+
+// unregister tira o agendamento do cron runner, se estiver registrado.
+// Chamar com s.mu travado.
+func (s *Scheduler) unregister(id int) {
+	if entryID, ok := s.entries[id]; ok {
+		s.cron.Remove(entryID)
+		delete(s.entries, id)
+	}
+}
+
+// recoverMisfire aplica a misfire_policy aos disparos perdidos de um
+// agendamento. Cada disparo recuperado é tratado como se tivesse acontecido no
+// horário original — {{today}}, {{prev_run}} etc. expandem relativos a ele.
+func (s *Scheduler) recoverMisfire(ctx context.Context, m misfire) {
+	sc := m.schedule
+	fires := m.fires
+
+	switch sc.MisfirePolicy {
+	case models.MisfireRunAll:
+	case models.MisfireRunOnce:
+		fires = fires[len(fires)-1:]
+	default:
+		log.Printf("[scheduler] agendamento %d: %d disparo(s) perdido(s) ignorado(s) (misfire_policy=skip)", sc.ID, len(fires))
+		return
+	}
+
+	log.Printf("[scheduler] agendamento %d: recuperando %d disparo(s) perdido(s) (misfire_policy=%s)", sc.ID, len(fires), sc.MisfirePolicy)
+	for _, fireTime := range fires {
+		if _, err := s.fire(ctx, &sc, fireTime, models.TriggerSchedule, nil); err != nil {
+			log.Printf("[scheduler] erro ao recuperar disparo de %s do agendamento %d: %v", fireTime.Format("2006-01-02 15:04:05"), sc.ID, err)
+		}
+	}
+}
+
+// isLeader é true sem LeaderChecker configurado (instância única).
+func (s *Scheduler) isLeader() bool {
+	return s.leader == nil || s.leader.IsLeader()
+}
+
 func (s *Scheduler) runSchedule(scheduleID int) {
+	if !s.isLeader() {
+		return
+	}
 	ctx := context.Background()
 
 	sc, err := s.scheduleRepo.GetByID(ctx, scheduleID)
@@ -146,78 +365,245 @@ func (s *Scheduler) runSchedule(scheduleID int) {
 		return
 	}
 
-	automation, err := s.automationRepo.GetByID(ctx, sc.AutomationID)
+	// Mesmo que o disparo falhe ou seja pulado pela overlap_policy, o
+	// next_run_at avança — senão o próximo Reload leria o tick como misfire.
+	if _, err := s.fire(ctx, sc, time.Now(), models.TriggerSchedule, nil); err != nil {
+		log.Printf("[scheduler] agendamento %d: %v", scheduleID, err)
+	}
+
+	// Atualiza next_run_at após disparar
+	s.mu.Lock()
+	if entryID, ok := s.entries[scheduleID]; ok {
+		next := s.cron.Entry(entryID).Next
+		if err := s.scheduleRepo.UpdateNextRun(ctx, scheduleID, &next); err != nil {
+			log.Printf("[scheduler] erro ao atualizar next_run_at do agendamento %d: %v", scheduleID, err)
+		}
+	}
+	s.mu.Unlock()
+}
+
+// fire cria e enfileira o job de UM disparo do agendamento. fireTime é o
+// instante do disparo — o tick atual ou um disparo perdido sendo recuperado —
+// e é a referência de todos os placeholders de data, sempre no fuso do
+// agendamento ({{today}} às 00:30 em Lisboa ainda é ontem em São Paulo).
+// trigger/userID vão pro job: TriggerSchedule sem usuário nos disparos do cron,
+// TriggerManual com o usuário no RunNow. Agendamento com FanOutParam dispara
+// um fan-out (fireFanOut) e devolve o job pai.
+//
+// Devolve (nil, nil) quando o disparo é suprimido — agendamento pausado,
+// janela de bloqueio ou overlap_policy —, com o motivo em schedule_events.
+func (s *Scheduler) fire(ctx context.Context, sc *models.Schedule, fireTime time.Time, trigger string, userID *int) (*models.Job, error) {
+	loc, _ := s.Location(sc.Timezone)
+	fireTime = fireTime.In(loc)
+
+	pause, err := s.ActivePause(ctx, sc, fireTime)
 	if err != nil {
-		log.Printf("[scheduler] automação %d não encontrada para agendamento %d: %v", sc.AutomationID, scheduleID, err)
-		return
+		return nil, err
+	}
+	if pause != nil {
+		s.recordEvent(ctx, pauseEvent(sc, fireTime, pause))
+		return nil, nil
 	}
 
-	params, err := parseParams(sc.Parameters)
+	automation, err := s.automationRepo.GetByID(ctx, sc.AutomationID)
 	if err != nil {
-		log.Printf("[scheduler] parâmetros inválidos no agendamento %d: %v", scheduleID, err)
-		return
+		return nil, fmt.Errorf("automação %d não encontrada: %w", sc.AutomationID, err)
 	}
 
-	// Expande placeholders de data ({{today}}, {{yesterday}}, {{prev_run+N}}…)
-	// antes de serializar — assim cada disparo do cron tem datas frescas
-	// relativas ao momento da execução, em vez da data salva no schedule.
-	// prevRun (execução agendada anterior) é calculado do próprio cron pra
-	// dar suporte a {{prev_run±N}}; zero se não der pra calcular.
-	now := time.Now().In(s.loc)
-	var prevRun time.Time
-	if sched, perr := ParseScheduleTZ(sc.CronExpression, s.locName); perr == nil {
-		// Trunca ao minuto: `now` é alguns ms DEPOIS do horário agendado, então
-		// sem isso o PrevFire devolveria o próprio disparo atual como "anterior".
-		// Truncado, ele devolve o disparo imediatamente anterior a este.
-		prevRun = PrevFire(sched, now.Truncate(time.Minute))
+	params, _, err := s.expandParams(ctx, sc, fireTime, make(map[int]*calendar.Calendar))
+	if err != nil {
+		return nil, err
+	}
+	// Mesmo preenchimento da execução manual: o que o agendamento não define
+	// vem do defaultParams da automação.
+	params, err = paramschema.ApplyDefaults(params, automation.DefaultParams)
+	if err != nil {
+		return nil, fmt.Errorf("automação %d com %w", automation.ID, err)
 	}
-	params = ExpandDatePlaceholders(params, now, prevRun)
 
 	paramsJSON, err := json.Marshal(params)
 	if err != nil {
-		log.Printf("[scheduler] erro ao serializar parâmetros do agendamento %d: %v", scheduleID, err)
-		return
+		return nil, fmt.Errorf("erro ao serializar parâmetros: %w", err)
+	}
+
+	overlap, err := s.checkOverlap(ctx, sc, fireTime)
+	if err != nil {
+		return nil, err
+	}
+	if overlap.skip {
+		return nil, nil
 	}
 
+	scheduleID := sc.ID
 	job := &models.Job{
 		AutomationID: automation.ID,
+		UserID:       userID,
 		Status:       "pending",
 		Parameters:   paramsJSON,
+		ScheduleID:   &scheduleID,
+		AfterJobID:   overlap.after,
+		Trigger:      trigger,
+	}
+	if sc.FanOutParam != nil {
+		return s.fireFanOut(ctx, sc, automation, job, params, fireTime, overlap)
 	}
 
 	if err := s.jobRepo.Create(ctx, job); err != nil {
-		log.Printf("[scheduler] erro ao criar job para agendamento %d: %v", scheduleID, err)
-		return
+		return nil, fmt.Errorf("erro ao criar job: %w", err)
 	}
+	s.finishOverlap(ctx, sc, overlap, job.ID)
 
-	queueName := automation.QueueName
-	if queueName == "" {
-		queueName = "automation_jobs"
+	// Retido: não publica agora — o runHeldReleaser publica quando o job
+	// anterior terminar.
+	if job.AfterJobID != nil {
+		log.Printf("[scheduler] job %s criado retido atrás do job %s — automação %q (agendamento %d, disparo de %s)",
+			job.ID, *job.AfterJobID, automation.Name, sc.ID, fireTime.Format("2006-01-02 15:04:05 MST"))
+		return job, nil
 	}
 
-	msg := queue.JobMessage{
-		JobID:        job.ID.String(),
-		AutomationID: automation.ID,
-		ScriptPath:   automation.ScriptPath,
-		Parameters:   params,
+	// Automação com limite de concorrência: o runConcurrencyDispatcher publica
+	// quando houver vaga.
+	if job.Held {
+		log.Printf("[scheduler] job %s criado aguardando vaga de concorrência — automação %q (agendamento %d, disparo de %s)",
+			job.ID, automation.Name, sc.ID, fireTime.Format("2006-01-02 15:04:05 MST"))
+		return job, nil
 	}
 
-	if err := s.queueClient.PublishJob(ctx, queueName, msg); err != nil {
-		log.Printf("[scheduler] erro ao enfileirar job para agendamento %d: %v", scheduleID, err)
-		return
+	if err := s.publish(ctx, automation, job, params); err != nil {
+		return nil, err
 	}
 
-	// Atualiza next_run_at após disparar
-	s.mu.Lock()
-	if entryID, ok := s.entries[scheduleID]; ok {
-		next := s.cron.Entry(entryID).Next
-		if err := s.scheduleRepo.UpdateNextRun(ctx, scheduleID, &next); err != nil {
-			log.Printf("[scheduler] erro ao atualizar next_run_at do agendamento %d: %v", scheduleID, err)
-		}
+	log.Printf("[scheduler] job %s criado — automação %q (agendamento %d, disparo de %s)",
+		job.ID, automation.Name, sc.ID, fireTime.Format("2006-01-02 15:04:05 MST"))
+	return job, nil
+}
+
+// ErrRunSkipped é devolvido por RunNow quando o disparo não cria job: o
+// agendamento está pausado/em janela de bloqueio ou a overlap_policy pulou.
+var ErrRunSkipped = errors.New("disparo suprimido")
+
+// RunNow dispara o agendamento agora, fora do cron, pelo mesmo caminho de um
+// tick: placeholders relativos a agora ({{prev_run}} = último tick do cron),
+// overlap_policy e job vinculado ao agendamento (scheduleId), com trigger
+// manual e o usuário que pediu. Vale também pra agendamento desabilitado, roda
+// em qualquer réplica e não mexe no next_run_at.
+//
+// Pausa e janela de bloqueio em vigor barram o disparo, como num tick.
+func (s *Scheduler) RunNow(ctx context.Context, sc *models.Schedule, userID *int) (*models.Job, error) {
+	loc, _ := s.Location(sc.Timezone)
+	now := time.Now().In(loc)
+
+	pause, err := s.ActivePause(ctx, sc, now)
+	if err != nil {
+		return nil, err
 	}
-	s.mu.Unlock()
+	if pause != nil {
+		return nil, fmt.Errorf("%w: agendamento pausado até %s", ErrRunSkipped, pause.Until.In(loc).Format("2006-01-02 15:04:05 MST"))
+	}
+
+	job, err := s.fire(ctx, sc, now, models.TriggerManual, userID)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, fmt.Errorf("%w: job anterior ainda ativo (overlap_policy=%s)", ErrRunSkipped, sc.OverlapPolicy)
+	}
+	return job, nil
+}
+
+// expandParams devolve os parâmetros do agendamento com os placeholders de
+// data expandidos para o disparo em fireTime (já no fuso do agendamento), e o
+// prevRun usado. É o mesmo cálculo pro disparo real e pro preview.
+//
+// Expandir antes de serializar faz cada disparo ter datas frescas relativas
+// ao momento do disparo, em vez da data salva no schedule. prevRun (execução
+// agendada anterior) é calculado do próprio cron pra dar suporte a
+// {{prev_run±N}}; zero se não der pra calcular. O calendário do agendamento
+// vale pros offsets em dias úteis ({{today-2bd}}).
+func (s *Scheduler) expandParams(ctx context.Context, sc *models.Schedule, fireTime time.Time, cals map[int]*calendar.Calendar) (map[string]interface{}, time.Time, error) {
+	params, err := parseParams(sc.Parameters)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("parâmetros inválidos: %w", err)
+	}
+
+	var prevRun time.Time
+	if sched, _, perr := s.scheduleFor(ctx, sc, cals); perr == nil {
+		// Trunca à resolução do agendamento: no tick, `fireTime` é alguns ms
+		// DEPOIS do horário agendado, então sem isso o PrevFire devolveria o
+		// próprio disparo atual como "anterior". Truncado, ele devolve o
+		// disparo imediatamente anterior a este.
+		prevRun = PrevFire(sched, fireTime.Truncate(fireResolution(sc)))
+	}
+	cal, err := s.calendarFor(ctx, sc, cals)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return ExpandDatePlaceholders(params, DateContext{Now: fireTime, PrevRun: prevRun, Calendar: cal}), prevRun, nil
+}
 
-	log.Printf("[scheduler] job %s criado — automação %q (agendamento %d)", job.ID, automation.Name, scheduleID)
+// fireResolution é a menor distância entre dois disparos do agendamento:
+// segundo quando a expressão tem o campo de segundos (ou é um @every, que o
+// robfig aceita em segundos), minuto no resto.
+func fireResolution(sc *models.Schedule) time.Duration {
+	if sc.Kind == models.ScheduleKindInterval {
+		return time.Minute
+	}
+	expr := strings.TrimSpace(sc.CronExpression)
+	if len(strings.Fields(expr)) == 6 || strings.HasPrefix(expr, "@every") {
+		return time.Second
+	}
+	return time.Minute
+}
+
+// maxPreviewFires limita quantos disparos o preview calcula por chamada.
+const maxPreviewFires = 50
+
+// Preview calcula os próximos `count` disparos do agendamento depois de
+// `from`, cada um com os parâmetros exatamente como o disparo real publicaria
+// e, se for o caso, a pausa que o suprimiria.
+// Não cria job nem mexe no cron — serve tanto pra agendamentos salvos quanto
+// pra um rascunho ainda não persistido.
+func (s *Scheduler) Preview(ctx context.Context, sc *models.Schedule, from time.Time, count int) ([]models.ScheduleFirePreview, error) {
+	if count <= 0 || count > maxPreviewFires {
+		return nil, fmt.Errorf("count deve estar entre 1 e %d", maxPreviewFires)
+	}
+
+	cals := make(map[int]*calendar.Calendar)
+	sched, loc, err := s.scheduleFor(ctx, sc, cals)
+	if err != nil {
+		return nil, err
+	}
+	windows, err := s.blackoutRepo.ListEffective(ctx, sc.ID, sc.AutomationID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]models.ScheduleFirePreview, 0, count)
+	t := from.In(loc)
+	for len(out) < count {
+		next := sched.Next(t)
+		if next.IsZero() {
+			break
+		}
+		next = next.In(loc)
+		params, prevRun, err := s.expandParams(ctx, sc, next, cals)
+		if err != nil {
+			return nil, err
+		}
+		item := models.ScheduleFirePreview{
+			FireAt:     next,
+			FireAtUTC:  next.UTC(),
+			Parameters: params,
+			Suppressed: s.activePause(sc, windows, next),
+		}
+		if !prevRun.IsZero() {
+			p := prevRun.In(loc)
+			item.PrevRun = &p
+		}
+		out = append(out, item)
+		t = next
+	}
+	return out, nil
 }
 
 func parseParams(raw json.RawMessage) (map[string]interface{}, error) {